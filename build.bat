@@ -7,6 +7,19 @@ echo.
 REM 创建输出目录
 if not exist "build" mkdir build
 
+REM 写入提交与构建时间 (tunnel-server version / tunnel-client version 中显示)
+set COMMIT=unknown
+for /f %%i in ('git rev-parse --short HEAD 2^>nul') do set COMMIT=%%i
+for /f %%i in ('powershell -NoProfile -Command "(Get-Date).ToUniversalTime().ToString('yyyy-MM-ddTHH:mm:ssZ')"') do set BUILD_DATE=%%i
+set LDFLAGS=-s -w -X tunnel/pkg/version.Commit=%COMMIT% -X tunnel/pkg/version.BuildDate=%BUILD_DATE%
+
+REM set EXPIRE_AT=2025-06-30T18:00:00+08:00 后运行可为 Server 写入到期时间
+set SERVER_LDFLAGS=%LDFLAGS%
+if not "%EXPIRE_AT%"=="" set SERVER_LDFLAGS=%LDFLAGS% -X main.buildExpireAt=%EXPIRE_AT%
+
+REM set FIPS=v1.0.0 后运行使用 Go 1.24+ 的 FIPS 140-3 模块快照构建并默认启用 (配合 -fips 运行)
+if not "%FIPS%"=="" set GOFIPS140=%FIPS%
+
 echo ========================================
 echo   Building Server
 echo ========================================
@@ -14,17 +27,17 @@ echo ========================================
 echo [1/3] Building Server for Windows AMD64...
 set GOOS=windows
 set GOARCH=amd64
-go build -ldflags="-s -w" -o build\tunnel-server_windows_amd64.exe .\cmd\server
+go build -ldflags="%SERVER_LDFLAGS%" -o build\tunnel-server_windows_amd64.exe .\cmd\server
 
 echo [2/3] Building Server for Linux AMD64...
 set GOOS=linux
 set GOARCH=amd64
-go build -ldflags="-s -w" -o build\tunnel-server_linux_amd64 .\cmd\server
+go build -ldflags="%SERVER_LDFLAGS%" -o build\tunnel-server_linux_amd64 .\cmd\server
 
 echo [3/3] Building Server for macOS AMD64...
 set GOOS=darwin
 set GOARCH=amd64
-go build -ldflags="-s -w" -o build\tunnel-server_darwin_amd64 .\cmd\server
+go build -ldflags="%SERVER_LDFLAGS%" -o build\tunnel-server_darwin_amd64 .\cmd\server
 
 echo.
 echo ========================================
@@ -34,17 +47,17 @@ echo ========================================
 echo [1/3] Building Client for Windows AMD64...
 set GOOS=windows
 set GOARCH=amd64
-go build -ldflags="-s -w" -o build\tunnel-client_windows_amd64.exe .\cmd\client
+go build -ldflags="%LDFLAGS%" -o build\tunnel-client_windows_amd64.exe .\cmd\client
 
 echo [2/3] Building Client for Linux AMD64...
 set GOOS=linux
 set GOARCH=amd64
-go build -ldflags="-s -w" -o build\tunnel-client_linux_amd64 .\cmd\client
+go build -ldflags="%LDFLAGS%" -o build\tunnel-client_linux_amd64 .\cmd\client
 
 echo [3/3] Building Client for macOS AMD64...
 set GOOS=darwin
 set GOARCH=amd64
-go build -ldflags="-s -w" -o build\tunnel-client_darwin_amd64 .\cmd\client
+go build -ldflags="%LDFLAGS%" -o build\tunnel-client_darwin_amd64 .\cmd\client
 
 echo.
 echo ========================================
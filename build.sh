@@ -8,21 +8,39 @@ echo
 # 创建输出目录
 mkdir -p build
 
+# 写入提交与构建时间 (tunnel-server version / tunnel-client version 中显示)
+COMMIT=$(git rev-parse --short HEAD 2>/dev/null || echo unknown)
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+LDFLAGS="-s -w -X tunnel/pkg/version.Commit=$COMMIT -X tunnel/pkg/version.BuildDate=$BUILD_DATE"
+
+# EXPIRE_AT=2025-06-30T18:00:00+08:00 ./build.sh 可为 Server 写入到期时间
+SERVER_LDFLAGS="$LDFLAGS"
+if [ -n "$EXPIRE_AT" ]; then
+    SERVER_LDFLAGS="$SERVER_LDFLAGS -X main.buildExpireAt=$EXPIRE_AT"
+    echo "Server 到期时间: $EXPIRE_AT"
+fi
+
+# FIPS=v1.0.0 ./build.sh 使用 Go 1.24+ 的 FIPS 140-3 模块快照构建并默认启用 (配合 -fips 运行)
+if [ -n "$FIPS" ]; then
+    export GOFIPS140=$FIPS
+    echo "FIPS 140-3 模块: GOFIPS140=$GOFIPS140"
+fi
+
 echo "========================================"
 echo "  Building Server"
 echo "========================================"
 
 echo "[1/4] Building Server for Windows AMD64..."
-GOOS=windows GOARCH=amd64 go build -ldflags="-s -w" -o build/tunnel-server_windows_amd64.exe ./cmd/server
+GOOS=windows GOARCH=amd64 go build -ldflags="$SERVER_LDFLAGS" -o build/tunnel-server_windows_amd64.exe ./cmd/server
 
 echo "[2/4] Building Server for Linux AMD64..."
-GOOS=linux GOARCH=amd64 go build -ldflags="-s -w" -o build/tunnel-server_linux_amd64 ./cmd/server
+GOOS=linux GOARCH=amd64 go build -ldflags="$SERVER_LDFLAGS" -o build/tunnel-server_linux_amd64 ./cmd/server
 
 echo "[3/4] Building Server for Linux ARM64..."
-GOOS=linux GOARCH=arm64 go build -ldflags="-s -w" -o build/tunnel-server_linux_arm64 ./cmd/server
+GOOS=linux GOARCH=arm64 go build -ldflags="$SERVER_LDFLAGS" -o build/tunnel-server_linux_arm64 ./cmd/server
 
 echo "[4/4] Building Server for macOS AMD64..."
-GOOS=darwin GOARCH=amd64 go build -ldflags="-s -w" -o build/tunnel-server_darwin_amd64 ./cmd/server
+GOOS=darwin GOARCH=amd64 go build -ldflags="$SERVER_LDFLAGS" -o build/tunnel-server_darwin_amd64 ./cmd/server
 
 echo
 echo "========================================"
@@ -30,16 +48,16 @@ echo "  Building Client"
 echo "========================================"
 
 echo "[1/4] Building Client for Windows AMD64..."
-GOOS=windows GOARCH=amd64 go build -ldflags="-s -w" -o build/tunnel-client_windows_amd64.exe ./cmd/client
+GOOS=windows GOARCH=amd64 go build -ldflags="$LDFLAGS" -o build/tunnel-client_windows_amd64.exe ./cmd/client
 
 echo "[2/4] Building Client for Linux AMD64..."
-GOOS=linux GOARCH=amd64 go build -ldflags="-s -w" -o build/tunnel-client_linux_amd64 ./cmd/client
+GOOS=linux GOARCH=amd64 go build -ldflags="$LDFLAGS" -o build/tunnel-client_linux_amd64 ./cmd/client
 
 echo "[3/4] Building Client for Linux ARM64..."
-GOOS=linux GOARCH=arm64 go build -ldflags="-s -w" -o build/tunnel-client_linux_arm64 ./cmd/client
+GOOS=linux GOARCH=arm64 go build -ldflags="$LDFLAGS" -o build/tunnel-client_linux_arm64 ./cmd/client
 
 echo "[4/4] Building Client for macOS AMD64..."
-GOOS=darwin GOARCH=amd64 go build -ldflags="-s -w" -o build/tunnel-client_darwin_amd64 ./cmd/client
+GOOS=darwin GOARCH=amd64 go build -ldflags="$LDFLAGS" -o build/tunnel-client_darwin_amd64 ./cmd/client
 
 echo
 echo "========================================"
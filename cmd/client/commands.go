@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"tunnel/pkg/cli"
+	"tunnel/pkg/logging"
+	"tunnel/pkg/version"
+)
+
+func clientCommands() []*cli.Command {
+	return []*cli.Command{
+		{Name: "run", Usage: "启动 Client (参数与不带子命令时相同)"},
+		{Name: "check", Usage: "校验参数或 -config 配置文件并检查监听地址，不启动服务"},
+		{Name: "config", Usage: "配置文件: gen <文件> 生成示例 | check <文件> 校验 | dump [参数] 输出生效配置", Sub: []*cli.Command{
+			{Name: "gen", Usage: "生成示例配置文件", Run: runConfigGen},
+			{Name: "check", Usage: "校验配置文件并检查监听地址", Run: runConfigCheck},
+			{Name: "dump", Usage: "输出合并参数、环境变量、配置文件与默认值后的生效配置 (JSON，密钥已遮蔽)"},
+		}},
+		{Name: "eventlog", Usage: "Windows 事件日志: install [事件源] 注册 | remove [事件源] 注销 (需管理员)", Sub: []*cli.Command{
+			{Name: "install", Usage: "注册事件源 (默认 TunnelClient)", Run: runEventLogInstall},
+			{Name: "remove", Usage: "注销事件源 (默认 TunnelClient)", Run: runEventLogRemove},
+		}},
+		{Name: "genpass", Usage: "生成随机密码", Run: runGenpass},
+		{Name: "genkey", Usage: "生成预共享密钥", Run: runGenkey},
+		{Name: "version", Usage: "显示版本、提交、构建时间与支持的传输/加密 (-json 输出 JSON)", Run: runVersion},
+		{Name: "completion", Usage: "输出 shell 补全脚本: bash | zsh", Run: runCompletion},
+	}
+}
+
+func subcommand(cmds []*cli.Command) bool {
+	if len(os.Args) < 2 {
+		return false
+	}
+	switch os.Args[1] {
+	case "check":
+		checkOnly = true
+		fallthrough
+	case "run":
+		os.Args = append(os.Args[:1:1], os.Args[2:]...)
+		return false
+	case "config":
+		if len(os.Args) > 2 && os.Args[2] == "dump" {
+			dumpOnly = true
+			os.Args = append(os.Args[:1:1], os.Args[3:]...)
+			return false
+		}
+	}
+	return cli.Dispatch(cmds, os.Args[1:])
+}
+
+func printCommands(cmds []*cli.Command) {
+	cli.PrintCommands(os.Stdout, cmds)
+}
+
+func runConfigGen(args []string) {
+	if len(args) != 1 {
+		fatalConfig("❌ 用法: tunnel-client config gen <文件>")
+	}
+	generateClientExampleConfig(args[0])
+}
+
+func runConfigCheck(args []string) {
+	if len(args) != 1 {
+		fatalConfig("❌ 用法: tunnel-client config check <文件>")
+	}
+	checkOnly = true
+	runFromConfig(args[0], false, false)
+}
+
+func eventSourceArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return "TunnelClient"
+}
+
+func runEventLogInstall(args []string) {
+	source := eventSourceArg(args)
+	if err := logging.InstallEventLog(source); err != nil {
+		log.Fatalf("❌ 注册事件源失败: %v", err)
+	}
+	log.Printf("✅ 事件源已注册: %s (启动时加 -eventlog %s)", source, source)
+}
+
+func runEventLogRemove(args []string) {
+	source := eventSourceArg(args)
+	if err := logging.RemoveEventLog(source); err != nil {
+		log.Fatalf("❌ 注销事件源失败: %v", err)
+	}
+	log.Printf("✅ 事件源已注销: %s", source)
+}
+
+func printConfig(cfg interface{}) {
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		log.Fatalf("❌ 序列化配置失败: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	fs.Parse(args)
+
+	info := version.Get()
+	if *asJSON {
+		out, _ := json.MarshalIndent(info, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Print(info)
+}
+
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		fatalConfig("❌ 用法: tunnel-client completion bash | zsh")
+	}
+	script, err := cli.Completion(args[0], clientCommands(), flag.CommandLine)
+	if err != nil {
+		fatalConfig("❌ %v", err)
+	}
+	fmt.Print(script)
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"tunnel/pkg/cli"
+	"tunnel/pkg/config"
+	"tunnel/pkg/logging"
+	"tunnel/pkg/provision"
+	"tunnel/pkg/server"
+	"tunnel/pkg/sessionlog"
+	"tunnel/pkg/version"
+)
+
+func serverCommands() []*cli.Command {
+	return []*cli.Command{
+		{Name: "run", Usage: "启动 Server (参数与不带子命令时相同)"},
+		{Name: "check", Usage: "校验参数或 -config 配置文件并检查监听地址，不启动服务"},
+		{Name: "config", Usage: "配置文件: gen <文件> 生成示例 | check <文件> 校验 | dump [参数] 输出生效配置", Sub: []*cli.Command{
+			{Name: "gen", Usage: "生成示例配置文件", Run: runConfigGen},
+			{Name: "check", Usage: "校验配置文件并检查监听地址", Run: runConfigCheck},
+			{Name: "dump", Usage: "输出合并参数、环境变量、配置文件与默认值后的生效配置 (JSON，密钥已遮蔽)"},
+		}},
+		{Name: "cert", Usage: "证书: gen 生成自签名证书", Sub: []*cli.Command{
+			{Name: "gen", Usage: "生成自签名证书与私钥", Run: runCertGen},
+		}},
+		{Name: "sessions", Usage: "经管理接口列出运行中 Server 的活动会话", Run: runSessions},
+		{Name: "maintenance", Usage: "经管理接口查看或切换维护模式: on | off | status", Run: runMaintenance},
+		{Name: "report", Usage: "汇总会话日志: 按客户端 IP、目标与日期统计流量 (CSV/JSON)", Run: runReport},
+		{Name: "eventlog", Usage: "Windows 事件日志: install [事件源] 注册 | remove [事件源] 注销 (需管理员)", Sub: []*cli.Command{
+			{Name: "install", Usage: "注册事件源 (默认 TunnelServer)", Run: runEventLogInstall},
+			{Name: "remove", Usage: "注销事件源 (默认 TunnelServer)", Run: runEventLogRemove},
+		}},
+		{Name: "genpass", Usage: "生成随机密码", Run: runGenpass},
+		{Name: "genkey", Usage: "生成预共享密钥", Run: runGenkey},
+		{Name: "provision", Usage: "生成 Server 与 Client 部署文件", Run: runProvision},
+		{Name: "proto", Usage: "协议描述与一致性测试: describe | check | serve", Run: runProto},
+		{Name: "version", Usage: "显示版本、提交、构建时间与支持的传输/加密 (-json 输出 JSON)", Run: runVersion},
+		{Name: "completion", Usage: "输出 shell 补全脚本: bash | zsh", Run: runCompletion},
+	}
+}
+
+func subcommand(cmds []*cli.Command) bool {
+	if len(os.Args) < 2 {
+		return false
+	}
+	switch os.Args[1] {
+	case "check":
+		checkOnly = true
+		fallthrough
+	case "run":
+		os.Args = append(os.Args[:1:1], os.Args[2:]...)
+		return false
+	case "config":
+		if len(os.Args) > 2 && os.Args[2] == "dump" {
+			dumpOnly = true
+			os.Args = append(os.Args[:1:1], os.Args[3:]...)
+			return false
+		}
+	}
+	return cli.Dispatch(cmds, os.Args[1:])
+}
+
+func printCommands(cmds []*cli.Command) {
+	cli.PrintCommands(os.Stdout, cmds)
+}
+
+func runConfigGen(args []string) {
+	if len(args) != 1 {
+		fatalConfig("❌ 用法: tunnel-server config gen <文件>")
+	}
+	generateServerExampleConfig(args[0])
+}
+
+func runConfigCheck(args []string) {
+	if len(args) != 1 {
+		fatalConfig("❌ 用法: tunnel-server config check <文件>")
+	}
+	checkOnly = true
+	runFromConfig(args[0], false, false)
+}
+
+func runCertGen(args []string) {
+	fs := flag.NewFlagSet("cert gen", flag.ExitOnError)
+	host := fs.String("host", "", "证书域名或 IP (必需)")
+	days := fs.Int("days", 365, "有效期 (天)")
+	certOut := fs.String("cert", "server.crt", "证书输出路径")
+	keyOut := fs.String("key", "server.key", "私钥输出路径 (权限 0600)")
+	force := fs.Bool("force", false, "覆盖已存在的文件")
+	fs.Parse(args)
+	if *host == "" {
+		fatalConfig("❌ 必须指定 -host")
+	}
+	if !*force {
+		for _, path := range []string{*certOut, *keyOut} {
+			if _, err := os.Stat(path); err == nil {
+				fatalConfig("❌ %s 已存在 (使用 -force 覆盖)", path)
+			}
+		}
+	}
+
+	certPEM, keyPEM, err := provision.SelfSigned(*host, *days)
+	if err != nil {
+		fatalConfig("❌ 生成证书失败: %v", err)
+	}
+	if err := os.WriteFile(*keyOut, keyPEM, 0600); err != nil {
+		log.Fatalf("❌ 写入私钥失败: %v", err)
+	}
+	if err := os.WriteFile(*certOut, certPEM, 0644); err != nil {
+		log.Fatalf("❌ 写入证书失败: %v", err)
+	}
+	log.Printf("✅ 自签名证书已生成: %s, %s (%s，%d 天)", *certOut, *keyOut, *host, *days)
+}
+
+func runSessions(args []string) {
+	fs := flag.NewFlagSet("sessions", flag.ExitOnError)
+	addr := fs.String("admin", "", "管理接口地址 (必需，例: 127.0.0.1:9090)")
+	token := fs.String("token", "", "管理接口令牌 (也可通过环境变量 "+config.EnvAdminToken+" 提供)")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	fs.Parse(args)
+	if *addr == "" {
+		fatalConfig("❌ 必须指定 -admin")
+	}
+	if *token == "" {
+		*token = os.Getenv(config.EnvAdminToken)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+*addr+"/sessions", nil)
+	if err != nil {
+		fatalConfig("❌ 无效的管理接口地址: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+*token)
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		log.Fatalf("❌ 请求管理接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("❌ 管理接口返回 %s", resp.Status)
+	}
+
+	var sessions []server.SessionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil {
+		log.Fatalf("❌ 解析会话列表失败: %v", err)
+	}
+	if *asJSON {
+		out, _ := json.MarshalIndent(sessions, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "开始时间\t时长\t来源\t传输\t目标\t上行\t下行")
+	for _, sess := range sessions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\n",
+			sess.Start.Local().Format("2006-01-02 15:04:05"),
+			time.Since(sess.Start).Round(time.Second),
+			sess.Peer, sess.Transport, sess.Target, sess.BytesUp, sess.BytesDown)
+	}
+	tw.Flush()
+	fmt.Printf("共 %d 个活动会话\n", len(sessions))
+}
+
+func runMaintenance(args []string) {
+	fs := flag.NewFlagSet("maintenance", flag.ExitOnError)
+	addr := fs.String("admin", "", "管理接口地址 (必需，例: 127.0.0.1:9090)")
+	token := fs.String("token", "", "管理接口令牌 (也可通过环境变量 "+config.EnvAdminToken+" 提供)")
+	fs.Parse(args)
+	if *addr == "" {
+		fatalConfig("❌ 必须指定 -admin")
+	}
+	if *token == "" {
+		*token = os.Getenv(config.EnvAdminToken)
+	}
+
+	method, query := http.MethodGet, ""
+	switch fs.Arg(0) {
+	case "", "status":
+	case "on":
+		method, query = http.MethodPost, "?enable=true"
+	case "off":
+		method, query = http.MethodPost, "?enable=false"
+	default:
+		fatalConfig("❌ 用法: tunnel-server maintenance [-admin 地址] on | off | status")
+	}
+
+	req, err := http.NewRequest(method, "http://"+*addr+"/maintenance"+query, nil)
+	if err != nil {
+		fatalConfig("❌ 无效的管理接口地址: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+*token)
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		log.Fatalf("❌ 请求管理接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("❌ 管理接口返回 %s", resp.Status)
+	}
+
+	var state struct {
+		Maintenance bool  `json:"maintenance"`
+		Sessions    int64 `json:"sessions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		log.Fatalf("❌ 解析响应失败: %v", err)
+	}
+	if state.Maintenance {
+		fmt.Printf("🚧 维护模式: 开启 (拒绝新会话，%d 个活动会话)\n", state.Sessions)
+	} else {
+		fmt.Printf("✅ 维护模式: 关闭 (%d 个活动会话)\n", state.Sessions)
+	}
+}
+
+func eventSourceArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return "TunnelServer"
+}
+
+func runEventLogInstall(args []string) {
+	source := eventSourceArg(args)
+	if err := logging.InstallEventLog(source); err != nil {
+		log.Fatalf("❌ 注册事件源失败: %v", err)
+	}
+	log.Printf("✅ 事件源已注册: %s (启动时加 -eventlog %s)", source, source)
+}
+
+func runEventLogRemove(args []string) {
+	source := eventSourceArg(args)
+	if err := logging.RemoveEventLog(source); err != nil {
+		log.Fatalf("❌ 注销事件源失败: %v", err)
+	}
+	log.Printf("✅ 事件源已注销: %s", source)
+}
+
+func printConfig(cfg interface{}) {
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		log.Fatalf("❌ 序列化配置失败: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	from := fs.String("from", "", "起始日期 (2006-01-02 或 RFC3339，含)")
+	to := fs.String("to", "", "结束日期 (2006-01-02 含当天，或 RFC3339 不含)")
+	format := fs.String("format", "json", "输出格式: json | csv")
+	output := fs.String("o", "", "输出文件 (默认 stdout)")
+	utc := fs.Bool("utc", false, "按 UTC 划分日期 (默认本地时区)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: tunnel-server report [参数] <会话日志文件 ...> (不指定文件或为 - 时读取 stdin，支持 .gz)")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *format != "json" && *format != "csv" {
+		fatalConfig("❌ 不支持的输出格式: %s (json | csv)", *format)
+	}
+
+	loc := time.Local
+	if *utc {
+		loc = time.UTC
+	}
+	start, err := parseReportTime(*from, loc, false)
+	if err != nil {
+		fatalConfig("❌ 无效的 -from: %v", err)
+	}
+	end, err := parseReportTime(*to, loc, true)
+	if err != nil {
+		fatalConfig("❌ 无效的 -to: %v", err)
+	}
+
+	agg := sessionlog.NewAggregator(start, end, loc)
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, path := range files {
+		if err := readSessionLog(agg, path); err != nil {
+			log.Fatalf("❌ 读取 %s 失败: %v", path, err)
+		}
+	}
+	report := agg.Report()
+	if report.Skipped > 0 {
+		log.Printf("⚠️ 跳过 %d 行无法解析的记录", report.Skipped)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("❌ 创建输出文件失败: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if *format == "csv" {
+		err = report.WriteCSV(out)
+	} else {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	}
+	if err != nil {
+		log.Fatalf("❌ 写入报告失败: %v", err)
+	}
+}
+
+func parseReportTime(s string, loc *time.Location, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func readSessionLog(agg *sessionlog.Aggregator, path string) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	if filepath.Ext(path) == ".gz" {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return agg.Read(r)
+}
+
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	fs.Parse(args)
+
+	info := version.Get()
+	if *asJSON {
+		out, _ := json.MarshalIndent(info, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Print(info)
+}
+
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		fatalConfig("❌ 用法: tunnel-server completion bash | zsh")
+	}
+	script, err := cli.Completion(args[0], serverCommands(), flag.CommandLine)
+	if err != nil {
+		fatalConfig("❌ %v", err)
+	}
+	fmt.Print(script)
+}
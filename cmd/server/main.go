@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -10,11 +13,41 @@ import (
 	"time"
 
 	"tunnel/pkg/acl"
+	"tunnel/pkg/admin"
+	"tunnel/pkg/clock"
+	"tunnel/pkg/cluster"
 	"tunnel/pkg/config"
+	"tunnel/pkg/crash"
+	"tunnel/pkg/crypto"
+	"tunnel/pkg/logging"
+	"tunnel/pkg/metrics"
+	"tunnel/pkg/netutil"
+	"tunnel/pkg/probe"
+	"tunnel/pkg/proto"
+	"tunnel/pkg/provision"
 	"tunnel/pkg/server"
+	"tunnel/pkg/sessionlog"
+	"tunnel/pkg/status"
 	"tunnel/pkg/transport"
 )
 
+var buildExpireAt string
+
+var statusJSON bool
+
+var insecurePassword bool
+
+var checkOnly bool
+
+var dumpOnly bool
+
+var eventLog *logging.EventLog
+
+const (
+	exitRuntime = 1
+	exitConfig  = 2
+)
+
 const banner = `
 ╔═══════════════════════════════════════════════════════════════╗
 ║   ____                            _____                  _    ║
@@ -32,26 +65,146 @@ const banner = `
 func main() {
 	listen := flag.String("listen", "", "监听地址 (例: 0.0.0.0:8888)")
 	target := flag.String("target", "", "目标地址 (例: 127.0.0.1:50050)")
-	password := flag.String("password", "SecureTunnel@2024", "加密密码")
+	allowDynamic := flag.Bool("allow-dynamic-targets", false, "允许 Client 指定连接目标 (SOCKS5/HTTPS 代理、UDP 中继需要)，默认只连接 -target")
+	dynamicAllow := flag.String("dynamic-target-allow", "", "动态目标允许访问的内网地址 (逗号分隔，支持 CIDR)，默认拒绝内网、回环、链路本地地址")
+	dynamicPorts := flag.String("dynamic-target-ports", "", "动态目标允许的端口范围 (如 \"80,443,8000-9000\")，默认全部")
+	password := flag.String("password", crypto.DefaultPassword, "加密密码 (可由 genpass 子命令生成)")
+	keyFile := flag.String("key-file", "", "预共享密钥文件 (base64 编码的 32 字节密钥，可由 genkey 子命令生成，设置后忽略 -password)")
 
 	enableWS := flag.Bool("ws", false, "启用 WebSocket 传输模式")
 	wsPath := flag.String("ws-path", "/ws", "WebSocket 路径")
 	wsTLS := flag.Bool("ws-tls", false, "启用 WebSocket TLS (wss://)")
 	wsCert := flag.String("ws-cert", "", "TLS 证书文件路径")
 	wsKey := flag.String("ws-key", "", "TLS 密钥文件路径")
+	wsAffinityCookie := flag.String("ws-affinity-cookie", "", "WebSocket/长轮询握手时下发的会话亲和 Cookie 名 (七层负载均衡按 Cookie 粘滞时使用)")
+	wsAffinityValue := flag.String("ws-affinity-value", "", "会话亲和 Cookie 值，标识本实例 (默认主机名)")
+	wsPingInterval := flag.Duration("ws-ping-interval", 30*time.Second, "WebSocket Ping 间隔 (0 表示不发送)")
+	wsPongMisses := flag.Int("ws-pong-misses", 3, "连续多少次未收到 Pong 后断开 WebSocket 连接 (-1 表示不检查)")
+	wsReadBuffer := flag.Int("ws-read-buffer", 32*1024, "WebSocket 读缓冲区大小 (字节)")
+	wsWriteBuffer := flag.Int("ws-write-buffer", 32*1024, "WebSocket 写缓冲区大小 (字节)")
+	trustedProxies := flag.String("trusted-proxies", "", "受信任的反向代理/CDN 地址 (逗号分隔的 IP 或 CIDR)，仅来自这些地址的 X-Forwarded-For / X-Real-IP 会被采信")
+	wsOrigins := flag.String("ws-allowed-origins", "", "允许的 WebSocket Origin (逗号分隔，支持 *.example.com，* 表示不检查)，默认要求与 Host 一致")
+	tlsMinVersion := flag.String("tls-min-version", "", "TLS 最低版本 (1.0/1.1/1.2/1.3)")
+	tlsMaxVersion := flag.String("tls-max-version", "", "TLS 最高版本 (1.0/1.1/1.2/1.3)")
+	tlsCiphers := flag.String("tls-ciphers", "", "TLS 加密套件 (逗号分隔，如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)")
+	tlsCurves := flag.String("tls-curves", "", "TLS 椭圆曲线偏好 (逗号分隔，如 X25519,P256)")
+	tlsALPN := flag.String("tls-alpn", "", "TLS ALPN 协议 (逗号分隔，如 http/1.1)")
+	tlsOCSP := flag.Bool("tls-ocsp", false, "启用 OCSP Stapling")
+	tlsReload := flag.Duration("tls-reload", time.Minute, "证书文件变更检查间隔 (0 表示不自动重载)")
+	dual := flag.Bool("dual", false, "双协议模式: 同一端口同时接受 WebSocket 与 TCP 隧道 (需配合 -ws)")
+	poll := flag.Bool("poll", false, "在 WebSocket 路径上同时接受 HTTP 长轮询客户端 (需配合 -ws)")
+	listenShards := flag.Int("listen-shards", 1, "使用 SO_REUSEPORT 打开的监听 socket 数 (各自独立 Accept，仅 Linux)")
+	listenTLS := flag.Bool("listen-tls", false, "TCP 模式监听端启用 TLS (使用 -ws-cert/-ws-key 证书及 -tls-* 参数)")
+	rawTLS := flag.Bool("raw-tls", false, "原始 TLS 模式: TLS 内直接承载目标的 TCP 字节流，无自定义分帧 (可用 socat/openssl 作为对端)")
+	rawTLSCA := flag.String("raw-tls-ca", "", "原始 TLS 模式校验客户端证书的 CA 文件 (必需)")
+	fips := flag.Bool("fips", false, "FIPS 模式: 帧加密改用 AES-256-GCM (HKDF-SHA256 派生)，需 -key-file 与 FIPS 140-3 模块 (GODEBUG=fips140=on)，两端需同时启用")
 
 	configFile := flag.String("config", "", "配置文件路径 (JSON/YAML)")
 	deleteConfig := flag.Bool("delete-config", false, "启动后删除配置文件")
 	secureDelete := flag.Bool("secure-delete", false, "安全删除配置文件 (覆写后删除)")
 	genConfig := flag.String("gen-config", "", "生成示例配置文件")
 
+	frameDebug := flag.Bool("frame-debug", false, "帧校验调试模式 (TCP 模式每帧附加 CRC32，两端需同时启用)")
+
+	maxConns := flag.Int("max-conns", 0, "最大并发连接数 (0 表示不限制)")
+
+	expireAt := flag.String("expire", "", "到期时间 (RFC3339 或 2006-01-02)，到期后拒绝启动，运行中到期则断开所有会话并退出")
+	dnsServer := flag.String("dns-server", "", "解析目标域名使用的 DNS 服务器 (例: 10.0.0.53:53，留空使用系统解析)")
+	dscp := flag.Int("dscp", 0, "连接目标时设置的 DSCP 值 (0-63，仅 Linux)")
+	fwmark := flag.Int("fwmark", 0, "连接目标时设置的 SO_MARK (仅 Linux，需要 CAP_NET_ADMIN)")
+
+	targetTLS := flag.Bool("target-tls", false, "以 TLS 连接默认目标 (目标为 HTTPS 监听器时使用)")
+	targetSNI := flag.String("target-sni", "", "连接目标使用的 TLS SNI (默认取目标主机名)")
+	targetALPN := flag.String("target-alpn", "", "连接目标使用的 TLS ALPN (逗号分隔)")
+	targetCert := flag.String("target-cert", "", "连接目标使用的客户端证书")
+	targetKey := flag.String("target-key", "", "连接目标使用的客户端私钥")
+	targetCA := flag.String("target-ca", "", "校验目标证书的 CA 文件")
+	targetSkipVerify := flag.Bool("target-skip-verify", false, "跳过目标证书校验 (自签名证书)")
+
+	crashDir := flag.String("crash-dir", "", "崩溃报告保存目录")
+	crashWebhook := flag.String("crash-webhook", "", "崩溃报告 Webhook 地址")
+	eventSource := flag.String("eventlog", "", "同时写入 Windows 事件日志的事件源名称 (仅 Windows，先以管理员运行 eventlog install 注册)")
+	flag.BoolVar(&statusJSON, "status-json", false, "启动成功后向 stdout 输出一行 JSON 状态 (模式、监听、传输、PID、配置哈希)")
+	flag.BoolVar(&insecurePassword, "insecure-allow-default", false, "允许使用内置默认密码或弱密码启动 (不安全，仅用于测试)")
+
 	aclEnable := flag.Bool("acl", false, "启用访问控制")
 	aclMode := flag.String("acl-mode", "whitelist", "ACL 模式: whitelist 或 blacklist")
 	aclWhitelist := flag.String("acl-whitelist", "", "白名单 (逗号分隔，支持 CIDR)")
 	aclBlacklist := flag.String("acl-blacklist", "", "黑名单 (逗号分隔，支持 CIDR)")
+	aclLogic := flag.String("acl-logic", "and", "请求特征规则与 IP 规则的组合方式: and 或 or")
+	aclJA3 := flag.String("acl-ja3", "", "允许的 JA3 指纹 (逗号分隔，仅 HTTP/WebSocket)")
+	aclJA4 := flag.String("acl-ja4", "", "允许的 JA4 指纹 (逗号分隔，仅 HTTP/WebSocket)")
+	aclUA := flag.String("acl-ua", "", "允许的 User-Agent (逗号分隔，支持 * 通配)")
+	aclHeaders := flag.String("acl-header", "", "必需的请求头 (逗号分隔，Name 或 Name=Value，值支持 * 通配)")
+	aclPaths := flag.String("acl-path", "", "允许的请求路径 (逗号分隔，支持 * 通配)")
+	aclPin := flag.Bool("acl-pin", false, "首个客户端认证成功后自动加入白名单并切换为白名单模式")
+	aclPinPrefix := flag.Int("acl-pin-prefix", 32, "自动白名单的 IPv4 前缀长度 (如 24 表示整个 /24)")
+	aclPinPrefix6 := flag.Int("acl-pin-prefix6", 128, "自动白名单的 IPv6 前缀长度")
+	aclKernel := flag.Bool("acl-kernel-filter", false, "在监听 socket 上安装内核过滤器，黑名单/封禁 IP 的 SYN 不进入 Accept (仅 Linux)")
+
+	guardEnable := flag.Bool("guard", false, "启用扫描探测识别与自动封禁")
+	handshakeTimeout := flag.Duration("handshake-timeout", 5*time.Second, "连接建立后等待首个握手帧的最长时间，超时断开并计为探测")
+	readTimeout := flag.Duration("read-timeout", 0, "会话空闲超时: 两个方向都没有数据超过该时间后断开 (0 为不限)")
+	writeTimeout := flag.Duration("write-timeout", netutil.DefaultWriteTimeout, "单次写入的最长阻塞时间，对端长时间不读取时断开 (0 为不限)")
+	dialTimeout := flag.Duration("dial-timeout", 10*time.Second, "连接目标 (含解析与目标 TLS 握手) 的超时")
+	udpIdleTimeout := flag.Duration("udp-idle-timeout", 5*time.Minute, "UDP 中继无数据超过该时间后关闭")
+	guardMaxFailures := flag.Int("guard-max-failures", 3, "握手失败多少次后封禁")
+	guardBan := flag.Duration("guard-ban", 30*time.Minute, "自动封禁时长")
+
+	probeLog := flag.String("probe-log", "", "探测流量日志文件 (JSON Lines，留空不记录)")
+	probeMaxBytes := flag.Int("probe-max-bytes", 256, "每条探测记录保存的最大载荷字节数")
+
+	quotaSession := flag.Int64("quota-session", 0, "单个会话最大流量 (字节，上下行合计，0 为不限)")
+	quotaDaily := flag.Int64("quota-daily", 0, "单个来源 IP 每日最大流量 (字节，0 为不限)")
+	peerMaxSessions := flag.Int("peer-max-sessions", 0, "单个来源 IP 最大并发会话数 (0 为不限)")
+	peerRate := flag.Int("peer-rate", 0, "单个来源 IP 每分钟最多新建会话数 (0 为不限)")
+
+	memoryLimit := flag.Int64("memory-limit", 0, "进程内存上限 (字节，接近时暂停接受新会话，0 为不限)")
+
+	schedule := flag.String("schedule", "", "服务时间窗口 (分号分隔，如 \"mon-fri 09:00-18:00;sat 10:00-12:00\")")
+
+	maxSessions := flag.Int("max-sessions", 0, "允许的隧道会话总数，用完后拒绝所有握手 (0 为不限)")
+	pinFirstClient := flag.Bool("pin-first-client", false, "仅接受首个成功握手的客户端 IP (等同于 -acl-pin)")
+
+	controlEnable := flag.Bool("control", false, "启用隧道内控制通道 (Client 可通过 -server-cmd 查看日志与统计)")
+	controlToken := flag.String("control-token", "", "控制通道令牌 (留空则仅凭隧道密码认证)")
+	controlLogLines := flag.Int("control-log-lines", 200, "控制通道可读取的最近日志行数")
+
+	clusterListen := flag.String("cluster-listen", "", "集群同步监听地址 (例: 0.0.0.0:7946，留空不启用)")
+	clusterPeers := flag.String("cluster-peers", "", "集群对等节点地址 (逗号分隔)")
+	clusterNode := flag.String("cluster-node", "", "集群节点名 (留空使用 主机名/监听地址)")
+	clusterKeyFile := flag.String("cluster-key-file", "", "集群同步专用密钥文件 (启用集群时必需，与隧道密钥不同，genkey 生成)")
+	clusterInterval := flag.Duration("cluster-interval", 5*time.Second, "集群状态同步间隔")
+	ntpServer := flag.String("ntp-server", "", "启动时查询该 NTP 服务器检查本机时钟 (例: pool.ntp.org，留空不检查)")
+	clockSkew := flag.Duration("clock-skew", clock.DefaultTolerance, "允许的时钟偏差，超过时告警 (NTP 检查与控制通道 Client 时钟比对)")
+
+	plainForward := flag.String("plain-forward", "", "明文 TCP 转发 (不加密，逗号分隔 [名称=]监听地址=目标地址，例: web=0.0.0.0:8080=10.0.0.5:80)")
+
+	batchDelay := flag.Duration("batch-delay", 0, "发往 Client 的小数据包合并等待时间 (建议 1ms-5ms，0 为不合并)")
+	batchSize := flag.Int("batch-size", crypto.DefaultBatchSize, "合并缓冲达到多少字节时立即发送")
+	relayEngine := flag.String("relay-engine", netutil.RelayGoroutine, "转发引擎: goroutine (每连接固定缓冲) 或 pooled (空闲连接不占用缓冲)")
+
+	routeScript := flag.String("route-script", "", "路由脚本路径 (每个会话连接目标前执行，stdin 输入会话 JSON，stdout 返回决策)")
+	routeScriptTimeout := flag.Duration("route-script-timeout", 2*time.Second, "路由脚本执行超时")
+
+	drainTimeout := flag.Duration("drain-timeout", 10*time.Minute, "热升级 (SIGUSR2) 后旧进程等待已有会话结束的最长时间 (0 为一直等待)")
+
+	sessionLog := flag.String("session-log", "", "会话元数据输出 (文件路径或 tcp://、udp://、unix:// 地址，留空不记录)")
+
+	metricsPush := flag.String("metrics-push", "", "指标推送地址 (host:port 或 http(s)://...，留空不推送)")
+	metricsProtocol := flag.String("metrics-protocol", "statsd", "指标推送协议: statsd 或 influx")
+	metricsNetwork := flag.String("metrics-network", "udp", "指标推送网络: udp 或 tcp")
+	metricsInterval := flag.Duration("metrics-interval", 10*time.Second, "指标推送间隔")
+	metricsPrefix := flag.String("metrics-prefix", "tunnel", "指标名前缀 (InfluxDB 中为 measurement)")
+
+	adminListen := flag.String("admin", "", "管理接口监听地址 (例: 127.0.0.1:9090，留空不启用)")
+	adminToken := flag.String("admin-token", "", "管理接口访问令牌 (Bearer)")
+	adminPprof := flag.Bool("admin-pprof", false, "在管理接口上启用 /debug/pprof 与 /debug/vars")
+	healthListen := flag.String("health", "", "健康检查监听地址 (/healthz 存活、/readyz 就绪，无需认证，留空不启用)")
+	adminKill := flag.Bool("admin-kill", false, "在管理接口上启用紧急关闭 POST /kill (断开所有会话、清除密钥并退出)")
 
 	flag.Usage = func() {
-		fmt.Println(banner)
+		fmt.Print(banner)
 		fmt.Println("使用方法:")
 		fmt.Println()
 		fmt.Println("  ═══════════════════════════════════════════════════════════════")
@@ -83,6 +236,48 @@ func main() {
 		fmt.Println("  ACL 黑名单:")
 		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -acl -acl-mode blacklist -acl-blacklist \"192.168.1.100,10.0.0.0/8\"")
 		fmt.Println()
+		fmt.Println("  仅允许指定 TLS 指纹与 User-Agent 的 WebSocket 客户端:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:443 -target 127.0.0.1:50050 -password mypass -ws -ws-tls -ws-cert cert.pem -ws-key key.pem -acl -acl-mode blacklist -acl-ja3 ad8e2ddea9ec0a77edc063c36fc6cecc -acl-ua \"Go-http-client/*\"")
+		fmt.Println()
+		fmt.Println("  首个客户端认证后自动锁定到其 /24 网段:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -acl-pin -acl-pin-prefix 24")
+		fmt.Println()
+		fmt.Println("  扫描探测识别与自动封禁:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -guard -guard-max-failures 3 -guard-ban 30m")
+		fmt.Println()
+		fmt.Println("  记录探测流量:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -probe-log probes.jsonl")
+		fmt.Println()
+		fmt.Println("  限制单会话 1GB、单 IP 每日 10GB 流量:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -quota-session 1073741824 -quota-daily 10737418240")
+		fmt.Println()
+		fmt.Println("  仅在工作日 9:00-18:00 接受会话:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -schedule \"mon-fri 09:00-18:00\"")
+		fmt.Println()
+		fmt.Println("  一次性投递，只允许首个客户端使用一次:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -max-sessions 1 -pin-first-client")
+		fmt.Println()
+		fmt.Println("  行动结束后自动失效:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -expire 2025-06-30T18:00:00+08:00")
+		fmt.Println()
+		fmt.Println("  导出会话元数据到 SIEM:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -session-log tcp://10.0.0.5:5170")
+		fmt.Println()
+		fmt.Println("  推送指标到 StatsD:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -metrics-push 10.0.0.5:8125")
+		fmt.Println()
+		fmt.Println("  管理接口 (含 pprof):")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -admin 127.0.0.1:9090 -admin-token secret -admin-pprof")
+		fmt.Println()
+		fmt.Println("  TCP 模式外层套 TLS:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:443 -target 127.0.0.1:50050 -password mypass -listen-tls -ws-cert cert.pem -ws-key key.pem")
+		fmt.Println()
+		fmt.Println("  以 TLS 连接目标 (目标为 HTTPS 监听器):")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:443 -password mypass -target-tls -target-sni www.example.com -target-skip-verify")
+		fmt.Println()
+		fmt.Println("  保存崩溃报告:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8888 -target 127.0.0.1:50050 -password mypass -crash-dir /var/log/tunnel")
+		fmt.Println()
 		fmt.Println("  ═══════════════════════════════════════════════════════════════")
 		fmt.Println("  WebSocket 模式 (流量伪装，更隐蔽)")
 		fmt.Println("  ═══════════════════════════════════════════════════════════════")
@@ -93,13 +288,34 @@ func main() {
 		fmt.Println("  WebSocket TLS 模式:")
 		fmt.Println("    tunnel-server -listen 0.0.0.0:443 -target 127.0.0.1:50050 -password mypass -ws -ws-path /chat -ws-tls -ws-cert cert.pem -ws-key key.pem")
 		fmt.Println()
+		fmt.Println("  WebSocket TLS 参数调整:")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:443 -target 127.0.0.1:50050 -password mypass -ws -ws-tls -ws-cert cert.pem -ws-key key.pem -tls-min-version 1.2 -tls-curves X25519,P256 -tls-alpn http/1.1")
+		fmt.Println()
+		fmt.Println("  双协议模式 (同端口接受 WebSocket 与 TCP 客户端):")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:8443 -target 127.0.0.1:50050 -password mypass -ws -dual")
+		fmt.Println()
+		fmt.Println("  HTTP 长轮询 (适用于剥离 Upgrade 头的代理):")
+		fmt.Println("    tunnel-server -listen 0.0.0.0:443 -target 127.0.0.1:50050 -password mypass -ws -ws-path /chat -poll -ws-tls -ws-cert cert.pem -ws-key key.pem")
+		fmt.Println()
+		fmt.Println("子命令:")
+		printCommands(serverCommands())
+		fmt.Println()
 		fmt.Println("参数说明:")
 		flag.PrintDefaults()
 	}
 
+	if subcommand(serverCommands()) {
+		return
+	}
 	flag.Parse()
 
-	fmt.Println(banner)
+	containerMode()
+	if !statusJSON && !dumpOnly && !logging.InContainer() {
+		fmt.Print(banner)
+	}
+
+	crash.Install(crash.Config{Dir: *crashDir, Webhook: *crashWebhook})
+	eventLogMode(*eventSource)
 
 	if *genConfig != "" {
 		generateServerExampleConfig(*genConfig)
@@ -111,15 +327,59 @@ func main() {
 		return
 	}
 
+	secretsFromEnv(map[string]*string{
+		"password":      password,
+		"admin-token":   adminToken,
+		"control-token": controlToken,
+	}, map[string]string{
+		"password":      config.EnvPassword,
+		"admin-token":   config.EnvAdminToken,
+		"control-token": config.EnvControlToken,
+	})
+
 	wsConfig := transport.DefaultWSConfig()
 	wsConfig.Path = *wsPath
 	wsConfig.EnableTLS = *wsTLS
 	wsConfig.TLSCert = *wsCert
 	wsConfig.TLSKey = *wsKey
+	wsConfig.TLSMinVersion = *tlsMinVersion
+	wsConfig.TLSMaxVersion = *tlsMaxVersion
+	wsConfig.TLSCipherSuites = splitAndTrim(*tlsCiphers)
+	wsConfig.TLSCurves = splitAndTrim(*tlsCurves)
+	wsConfig.TLSALPN = splitAndTrim(*tlsALPN)
+	wsConfig.TLSOCSPStapling = *tlsOCSP
+	wsConfig.TLSReloadInterval = *tlsReload
+	wsConfig.AffinityCookie = *wsAffinityCookie
+	wsConfig.AffinityValue = *wsAffinityValue
+	wsConfig.AllowedOrigins = splitAndTrim(*wsOrigins)
+	wsConfig.PingInterval = *wsPingInterval
+	if *wsPongMisses != 0 {
+		wsConfig.PongMisses = *wsPongMisses
+	}
+	if *wsReadBuffer > 0 {
+		wsConfig.ReadBufferSize = *wsReadBuffer
+	}
+	if *wsWriteBuffer > 0 {
+		wsConfig.WriteBufferSize = *wsWriteBuffer
+	}
 
 	aclConfig := acl.Config{
 		Enable: *aclEnable,
 		Mode:   *aclMode,
+		Logic:  *aclLogic,
+		Match: acl.Match{
+			JA3:       splitAndTrim(*aclJA3),
+			JA4:       splitAndTrim(*aclJA4),
+			UserAgent: splitAndTrim(*aclUA),
+			Headers:   parseHeaders(*aclHeaders),
+			Paths:     splitAndTrim(*aclPaths),
+		},
+		Pin: acl.PinConfig{
+			Enable:   *aclPin || *pinFirstClient,
+			PrefixV4: *aclPinPrefix,
+			PrefixV6: *aclPinPrefix6,
+		},
+		KernelFilter: *aclKernel,
 	}
 	if *aclWhitelist != "" {
 		aclConfig.Whitelist = splitAndTrim(*aclWhitelist)
@@ -128,7 +388,239 @@ func main() {
 		aclConfig.Blacklist = splitAndTrim(*aclBlacklist)
 	}
 
-	runServer(*listen, *target, *password, *enableWS, wsConfig, aclConfig)
+	guardConfig := server.GuardConfig{
+		Enable:      *guardEnable,
+		MaxFailures: *guardMaxFailures,
+		BanDuration: *guardBan,
+	}
+
+	probeConfig := probe.Config{
+		Enable:   *probeLog != "",
+		Path:     *probeLog,
+		MaxBytes: *probeMaxBytes,
+	}
+
+	sessionLogConfig := sessionlog.Config{
+		Enable: *sessionLog != "",
+		Output: *sessionLog,
+	}
+
+	controlConfig := server.ControlConfig{
+		Enable:   *controlEnable,
+		Token:    *controlToken,
+		LogLines: *controlLogLines,
+	}
+
+	usageConfig := server.UsageConfig{
+		MaxSessions: *maxSessions,
+	}
+
+	quotaConfig := server.QuotaConfig{
+		SessionBytes: *quotaSession,
+		DailyBytes:   *quotaDaily,
+	}
+
+	peerLimitConfig := server.PeerLimitConfig{
+		MaxSessions: *peerMaxSessions,
+		PerMinute:   *peerRate,
+	}
+
+	clusterConfig := cluster.Config{
+		Enable:   *clusterListen != "",
+		Listen:   *clusterListen,
+		Peers:    splitAndTrim(*clusterPeers),
+		Node:     *clusterNode,
+		KeyFile:  *clusterKeyFile,
+		Interval: *clusterInterval,
+	}
+
+	pushConfig := metrics.PushConfig{
+		Enable:   *metricsPush != "",
+		Protocol: *metricsProtocol,
+		Address:  *metricsPush,
+		Network:  *metricsNetwork,
+		Interval: *metricsInterval,
+		Prefix:   *metricsPrefix,
+	}
+
+	adminConfig := admin.Config{
+		Enable:      *adminListen != "",
+		Listen:      *adminListen,
+		Token:       *adminToken,
+		EnablePprof: *adminPprof,
+		EnableKill:  *adminKill,
+	}
+
+	targetTLSConfig := server.TargetTLSConfig{
+		Enable:     *targetTLS,
+		ServerName: *targetSNI,
+		ALPN:       splitAndTrim(*targetALPN),
+		CertFile:   *targetCert,
+		KeyFile:    *targetKey,
+		CAFile:     *targetCA,
+		SkipVerify: *targetSkipVerify,
+	}
+
+	runServer(server.Config{
+		ListenAddr:          *listen,
+		TargetAddr:          *target,
+		AllowDynamicTargets: *allowDynamic,
+		DynamicTargetAllow:  splitAndTrim(*dynamicAllow),
+		DynamicTargetPorts:  *dynamicPorts,
+		Password:            *password,
+		KeyFile:             *keyFile,
+		EnableWS:            *enableWS,
+		WSConfig:            wsConfig,
+		TrustedProxies:      splitAndTrim(*trustedProxies),
+		DualProtocol:        *dual,
+		EnablePoll:          *poll,
+		ListenTLS:           *listenTLS,
+		RawTLS:              *rawTLS,
+		RawTLSClientCA:      *rawTLSCA,
+		FIPS:                *fips,
+		ListenShards:        *listenShards,
+		FrameDebug:          *frameDebug,
+		MaxConnections:      *maxConns,
+		DNSServer:           *dnsServer,
+		TargetMark:          netutil.SocketMark{DSCP: *dscp, Mark: *fwmark},
+		ExpireAt:            parseExpiry(buildExpireAt, *expireAt),
+		TargetTLS:           targetTLSConfig,
+		ACLConfig:           aclConfig,
+		GuardConfig:         guardConfig,
+		ProbeConfig:         probeConfig,
+		SessionLog:          sessionLogConfig,
+		Quota:               quotaConfig,
+		PeerLimit:           peerLimitConfig,
+		MemoryLimit:         *memoryLimit,
+		Schedule:            splitSchedule(*schedule),
+		Usage:               usageConfig,
+		Control:             controlConfig,
+		Cluster:             clusterConfig,
+		Clock:               clock.Config{NTPServer: *ntpServer, Tolerance: *clockSkew},
+		PlainForwards:       parsePlainForwards(*plainForward),
+		Batch:               crypto.BatchConfig{Delay: *batchDelay, Size: *batchSize},
+		RelayEngine:         *relayEngine,
+		RouteScript:         server.ScriptConfig{Path: *routeScript, Timeout: *routeScriptTimeout},
+		DrainTimeout:        *drainTimeout,
+		HandshakeTimeout:    *handshakeTimeout,
+		ReadTimeout:         *readTimeout,
+		WriteTimeout:        *writeTimeout,
+		DialTimeout:         *dialTimeout,
+		UDPIdleTimeout:      *udpIdleTimeout,
+		MetricsPush:         pushConfig,
+		AdminConfig:         adminConfig,
+		HealthListen:        *healthListen,
+	})
+}
+
+func runProvision(args []string) {
+	fs := flag.NewFlagSet("provision", flag.ExitOnError)
+	var spec provision.Spec
+	fs.StringVar(&spec.Domain, "domain", "", "Server 对外域名或 IP (必需，写入证书与 Client 配置)")
+	fs.StringVar(&spec.Target, "target", "", "目标地址 (必需，例: 127.0.0.1:50050)")
+	fs.StringVar(&spec.Transport, "transport", provision.TransportWSS, "传输方式: tcp | tls | ws | wss | poll")
+	fs.StringVar(&spec.Listen, "listen", "", "Server 监听地址 (默认 TLS 为 0.0.0.0:443，否则 0.0.0.0:8888)")
+	fs.StringVar(&spec.ClientListen, "client-listen", "", "Client 本地监听地址 (默认 127.0.0.1:443)")
+	fs.StringVar(&spec.Password, "password", "", "加密密码 (留空随机生成)")
+	fs.StringVar(&spec.WSPath, "ws-path", "", "WebSocket 路径 (默认 /ws)")
+	fs.StringVar(&spec.CertFile, "cert", "", "使用已有证书 (留空生成自签名证书)")
+	fs.StringVar(&spec.KeyFile, "key", "", "使用已有私钥")
+	fs.IntVar(&spec.CertDays, "cert-days", 365, "自签名证书有效期 (天)")
+	fs.StringVar(&spec.InstallDir, "install-dir", "/etc/tunnel", "目标主机上的配置与证书目录")
+	fs.StringVar(&spec.BinDir, "bin-dir", "/usr/local/bin", "目标主机上的可执行文件目录")
+	fs.StringVar(&spec.OutDir, "out", "", "输出目录 (默认 provision-<domain>)")
+	fs.BoolVar(&spec.Force, "force", false, "输出目录已存在时覆盖")
+	fs.Parse(args)
+
+	result, err := provision.Generate(spec)
+	if err != nil {
+		fatalConfig("❌ 生成部署文件失败: %v", err)
+	}
+	for _, file := range result.Files {
+		log.Printf("[Provision] 📄 %s", file)
+	}
+	log.Printf("[Provision] ✅ Server 文件: %s，Client 配置包: %s", result.ServerDir, result.Bundle)
+}
+
+func runProto(args []string) {
+	if len(args) == 0 {
+		fatalConfig("❌ 用法: tunnel-server proto describe | check | serve [参数]")
+	}
+
+	switch args[0] {
+	case "describe":
+		out, err := json.MarshalIndent(proto.Describe(), "", "  ")
+		if err != nil {
+			log.Fatalf("❌ 生成协议描述失败: %v", err)
+		}
+		fmt.Println(string(out))
+
+	case "check":
+		fs := flag.NewFlagSet("proto check", flag.ExitOnError)
+		var cfg proto.CheckConfig
+		fs.StringVar(&cfg.Server, "server", "", "被测 Server 地址 (必需)")
+		fs.StringVar(&cfg.Password, "password", crypto.DefaultPassword, "加密密码")
+		fs.StringVar(&cfg.Transport, "transport", proto.TransportTCP, "传输方式: tcp | tls | ws | wss | poll")
+		fs.StringVar(&cfg.WSPath, "ws-path", "/ws", "WebSocket / 轮询路径")
+		fs.BoolVar(&cfg.SkipVerify, "skip-verify", false, "跳过 TLS 证书验证")
+		fs.BoolVar(&cfg.FrameDebug, "frame-debug", false, "帧尾附加 CRC32 (需与被测 Server 一致)")
+		fs.StringVar(&cfg.Target, "target", "", "被测 Server 可访问的回显地址 (留空在本机启动)")
+		fs.DurationVar(&cfg.Timeout, "timeout", 10*time.Second, "单项检查超时")
+		asJSON := fs.Bool("json", false, "以 JSON 输出结果")
+		fs.Parse(args[1:])
+		if cfg.Server == "" {
+			fatalConfig("❌ 必须指定 -server")
+		}
+
+		results, err := proto.Check(context.Background(), cfg)
+		if err != nil {
+			log.Fatalf("❌ 一致性测试失败: %v", err)
+		}
+
+		failed := 0
+		for _, r := range results {
+			if !r.Passed {
+				failed++
+			}
+		}
+		if *asJSON {
+			out, _ := json.MarshalIndent(results, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			for _, r := range results {
+				mark := "✅"
+				if !r.Passed {
+					mark = "❌"
+				}
+				fmt.Printf("%s %-20s %8s  %s\n", mark, r.Name, r.Duration.Round(time.Millisecond), r.Detail)
+			}
+			fmt.Printf("%d/%d 通过\n", len(results)-failed, len(results))
+		}
+		if failed > 0 {
+			os.Exit(exitRuntime)
+		}
+
+	case "serve":
+		fs := flag.NewFlagSet("proto serve", flag.ExitOnError)
+		var cfg proto.ServeConfig
+		fs.StringVar(&cfg.Listen, "listen", "127.0.0.1:8888", "监听地址")
+		fs.StringVar(&cfg.Password, "password", crypto.DefaultPassword, "加密密码")
+		fs.StringVar(&cfg.Transport, "transport", proto.TransportTCP, "传输方式: tcp | tls | ws | wss | poll")
+		fs.StringVar(&cfg.WSPath, "ws-path", "/ws", "WebSocket / 轮询路径")
+		fs.StringVar(&cfg.CertFile, "cert", "", "TLS 证书 (tls / wss)")
+		fs.StringVar(&cfg.KeyFile, "key", "", "TLS 私钥 (tls / wss)")
+		fs.BoolVar(&cfg.FrameDebug, "frame-debug", false, "帧尾附加 CRC32")
+		fs.Parse(args[1:])
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := proto.Serve(ctx, cfg); err != nil {
+			log.Fatalf("❌ 一致性测试 Server 启动失败: %v", err)
+		}
+
+	default:
+		fatalConfig("❌ 未知子命令 %q (describe | check | serve)", args[0])
+	}
 }
 
 func generateServerExampleConfig(path string) {
@@ -144,11 +636,26 @@ func runFromConfig(configPath string, deleteConf, secureDelete bool) {
 
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
-		log.Fatalf("❌ 加载配置文件失败: %v", err)
+		fatalConfig("❌ 加载配置文件失败: %v", err)
+	}
+	if err := cfg.ApplySecretEnv(); err != nil {
+		fatalConfig("❌ 读取密钥失败: %v", err)
 	}
 
+	if cfg.Server.Crash.Dir != "" || cfg.Server.Crash.Webhook != "" {
+		crash.Install(crash.Config{
+			Dir:      cfg.Server.Crash.Dir,
+			Webhook:  cfg.Server.Crash.Webhook,
+			LogLines: cfg.Server.Crash.LogLines,
+		})
+	}
+	eventLogMode(cfg.Server.EventLog)
+
 	if cfg.Mode != "" && cfg.Mode != "server" {
-		log.Fatalf("❌ 配置文件中的 mode 不是 server，请使用 tunnel-client")
+		fatalConfig("❌ 配置文件中的 mode 不是 server，请使用 tunnel-client")
+	}
+	if cfg.Server.InsecureAllowDefault {
+		insecurePassword = true
 	}
 
 	if deleteConf || secureDelete {
@@ -174,54 +681,413 @@ func runFromConfig(configPath string, deleteConf, secureDelete bool) {
 	wsConfig.EnableTLS = cfg.Server.WSTLS
 	wsConfig.TLSCert = cfg.Server.WSCert
 	wsConfig.TLSKey = cfg.Server.WSKey
+	wsConfig.TLSMinVersion = cfg.Server.TLS.MinVersion
+	wsConfig.TLSMaxVersion = cfg.Server.TLS.MaxVersion
+	wsConfig.TLSCipherSuites = cfg.Server.TLS.CipherSuites
+	wsConfig.TLSCurves = cfg.Server.TLS.Curves
+	wsConfig.TLSALPN = cfg.Server.TLS.ALPN
+	wsConfig.TLSOCSPStapling = cfg.Server.TLS.OCSPStapling
+	wsConfig.AffinityCookie = cfg.Server.WSAffinityCookie
+	wsConfig.AffinityValue = cfg.Server.WSAffinityValue
+	wsConfig.AllowedOrigins = cfg.Server.WSAllowedOrigins
+	wsConfig.PingInterval = cfg.Server.WSPingInterval.Or(wsConfig.PingInterval)
+	if cfg.Server.WSPongMisses != 0 {
+		wsConfig.PongMisses = cfg.Server.WSPongMisses
+	}
+	if cfg.Server.WSReadBuffer > 0 {
+		wsConfig.ReadBufferSize = cfg.Server.WSReadBuffer
+	}
+	if cfg.Server.WSWriteBuffer > 0 {
+		wsConfig.WriteBufferSize = cfg.Server.WSWriteBuffer
+	}
+	wsConfig.TLSReloadInterval = cfg.Server.TLS.ReloadInterval.Or(wsConfig.TLSReloadInterval)
 
 	aclConfig := acl.Config{
 		Enable:    cfg.Server.ACL.Enable,
 		Mode:      cfg.Server.ACL.Mode,
 		Whitelist: cfg.Server.ACL.Whitelist,
 		Blacklist: cfg.Server.ACL.Blacklist,
+		Logic:     cfg.Server.ACL.Logic,
+		Match: acl.Match{
+			JA3:       cfg.Server.ACL.Match.JA3,
+			JA4:       cfg.Server.ACL.Match.JA4,
+			UserAgent: cfg.Server.ACL.Match.UserAgent,
+			Headers:   cfg.Server.ACL.Match.Headers,
+			Paths:     cfg.Server.ACL.Match.Paths,
+		},
+		Default: cfg.Server.ACL.Default,
+		GeoIP:   cfg.Server.ACL.GeoIP,
+		Pin: acl.PinConfig{
+			Enable:   cfg.Server.ACL.Pin.Enable || cfg.Server.Usage.PinFirstClient,
+			PrefixV4: cfg.Server.ACL.Pin.PrefixV4,
+			PrefixV6: cfg.Server.ACL.Pin.PrefixV6,
+		},
+		KernelFilter: cfg.Server.ACL.KernelFilter,
+	}
+	for _, r := range cfg.Server.ACL.Rules {
+		aclConfig.Rules = append(aclConfig.Rules, acl.Rule{
+			Name:      r.Name,
+			Action:    r.Action,
+			IPs:       r.IPs,
+			Countries: r.Countries,
+			Days:      r.Days,
+			Hours:     r.Hours,
+			Transport: r.Transport,
+			Match: acl.Match{
+				JA3:       r.JA3,
+				JA4:       r.JA4,
+				UserAgent: r.UserAgent,
+				Headers:   r.Headers,
+				Paths:     r.Paths,
+			},
+		})
 	}
 
-	runServer(cfg.Server.Listen, cfg.Server.Target, cfg.Server.Password,
-		cfg.Server.EnableWS, wsConfig, aclConfig)
-}
+	guardConfig := server.GuardConfig{
+		Enable:      cfg.Server.Guard.Enable,
+		MaxFailures: cfg.Server.Guard.MaxFailures,
+		BanDuration: cfg.Server.Guard.BanDuration.Duration,
+	}
+
+	probeConfig := probe.Config{
+		Enable:   cfg.Server.Probe.Enable,
+		Path:     cfg.Server.Probe.Path,
+		MaxBytes: cfg.Server.Probe.MaxBytes,
+	}
+
+	sessionLogConfig := sessionlog.Config{
+		Enable: cfg.Server.SessionLog.Enable,
+		Output: cfg.Server.SessionLog.Output,
+	}
+
+	controlConfig := server.ControlConfig{
+		Enable:   cfg.Server.Control.Enable,
+		Token:    cfg.Server.Control.Token,
+		LogLines: cfg.Server.Control.LogLines,
+	}
+
+	usageConfig := server.UsageConfig{
+		MaxSessions: cfg.Server.Usage.MaxSessions,
+	}
+
+	quotaConfig := server.QuotaConfig{
+		SessionBytes: cfg.Server.Quota.SessionBytes,
+		DailyBytes:   cfg.Server.Quota.DailyBytes,
+	}
+
+	peerLimitConfig := server.PeerLimitConfig{
+		MaxSessions: cfg.Server.PeerLimit.MaxSessions,
+		PerMinute:   cfg.Server.PeerLimit.PerMinute,
+	}
+
+	batchConfig := crypto.BatchConfig{Size: cfg.Server.BatchSize, Delay: cfg.Server.BatchDelay.Duration}
+	if batchConfig.Size <= 0 {
+		batchConfig.Size = crypto.DefaultBatchSize
+	}
+
+	scriptConfig := server.ScriptConfig{Path: cfg.Server.RouteScript, Timeout: cfg.Server.RouteScriptTimeout.Duration}
+
+	clusterConfig := cluster.Config{
+		Enable:   cfg.Server.Cluster.Enable,
+		Listen:   cfg.Server.Cluster.Listen,
+		Peers:    cfg.Server.Cluster.Peers,
+		Node:     cfg.Server.Cluster.Node,
+		KeyFile:  cfg.Server.Cluster.KeyFile,
+		Interval: cfg.Server.Cluster.Interval.Duration,
+	}
+
+	var plainForwards []server.PlainForward
+	for _, fwd := range cfg.Server.PlainForwards {
+		plainForwards = append(plainForwards, server.PlainForward{Name: fwd.Name, Listen: fwd.Listen, Target: fwd.Target})
+	}
 
-func runServer(listen, target, password string, enableWS bool, wsConfig transport.WSConfig, aclConfig acl.Config) {
-	if listen == "" {
-		log.Fatal("❌ 请指定监听地址 (-listen)")
+	pushConfig := metrics.PushConfig{
+		Enable:   cfg.Server.Metrics.Push.Enable,
+		Protocol: cfg.Server.Metrics.Push.Protocol,
+		Address:  cfg.Server.Metrics.Push.Address,
+		Network:  cfg.Server.Metrics.Push.Network,
+		Prefix:   cfg.Server.Metrics.Push.Prefix,
+		Interval: cfg.Server.Metrics.Push.Interval.Duration,
 	}
-	if target == "" {
-		log.Fatal("❌ 请指定目标地址 (-target)，例如 CobaltStrike TeamServer 地址")
+
+	adminConfig := admin.Config{
+		Enable:      cfg.Server.Admin.Enable,
+		Listen:      cfg.Server.Admin.Listen,
+		Token:       cfg.Server.Admin.Token,
+		EnablePprof: cfg.Server.Admin.Pprof,
+		EnableKill:  cfg.Server.Admin.Kill,
 	}
 
-	cfg := server.Config{
-		ListenAddr:   listen,
-		TargetAddr:   target,
-		Password:     password,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		EnableWS:     enableWS,
-		WSConfig:     wsConfig,
-		ACLConfig:    aclConfig,
+	targetTLSConfig := server.TargetTLSConfig{
+		Enable:     cfg.Server.TargetTLS.Enable,
+		ServerName: cfg.Server.TargetTLS.ServerName,
+		ALPN:       cfg.Server.TargetTLS.ALPN,
+		CertFile:   cfg.Server.TargetTLS.Cert,
+		KeyFile:    cfg.Server.TargetTLS.Key,
+		CAFile:     cfg.Server.TargetTLS.CA,
+		SkipVerify: cfg.Server.TargetTLS.SkipVerify,
 	}
 
+	runServer(server.Config{
+		ListenAddr:          cfg.Server.Listen,
+		TargetAddr:          cfg.Server.Target,
+		AllowDynamicTargets: cfg.Server.AllowDynamicTargets,
+		DynamicTargetAllow:  cfg.Server.DynamicTargetAllow,
+		DynamicTargetPorts:  cfg.Server.DynamicTargetPorts,
+		Password:            cfg.Server.Password,
+		KeyFile:             cfg.Server.KeyFile,
+		EnableWS:            cfg.Server.EnableWS,
+		WSConfig:            wsConfig,
+		TrustedProxies:      cfg.Server.TrustedProxies,
+		DualProtocol:        cfg.Server.DualProtocol,
+		EnablePoll:          cfg.Server.EnablePoll,
+		ListenTLS:           cfg.Server.ListenTLS,
+		RawTLS:              cfg.Server.RawTLS,
+		RawTLSClientCA:      cfg.Server.RawTLSCA,
+		FIPS:                cfg.Server.FIPS,
+		ListenShards:        cfg.Server.ListenShards,
+		FrameDebug:          cfg.Server.FrameDebug,
+		MaxConnections:      cfg.Server.MaxConnections,
+		DNSServer:           cfg.Server.DNSServer,
+		TargetMark:          netutil.SocketMark{DSCP: cfg.Server.DSCP, Mark: cfg.Server.FWMark},
+		ExpireAt:            parseExpiry(buildExpireAt, cfg.Server.ExpireAt),
+		TargetTLS:           targetTLSConfig,
+		ACLConfig:           aclConfig,
+		GuardConfig:         guardConfig,
+		ProbeConfig:         probeConfig,
+		SessionLog:          sessionLogConfig,
+		Quota:               quotaConfig,
+		PeerLimit:           peerLimitConfig,
+		MemoryLimit:         cfg.Server.MemoryLimit,
+		Schedule:            cfg.Server.Schedule,
+		Usage:               usageConfig,
+		Control:             controlConfig,
+		Cluster:             clusterConfig,
+		Clock:               clock.Config{NTPServer: cfg.Server.Clock.NTPServer, Tolerance: cfg.Server.Clock.Tolerance.Duration},
+		PlainForwards:       plainForwards,
+		Batch:               batchConfig,
+		RelayEngine:         cfg.Server.RelayEngine,
+		HealthListen:        cfg.Server.Health,
+		RouteScript:         scriptConfig,
+		DrainTimeout:        cfg.Server.DrainTimeout.Or(10 * time.Minute),
+		HandshakeTimeout:    cfg.Server.HandshakeTimeout.Duration,
+		ReadTimeout:         cfg.Server.ReadTimeout.Duration,
+		WriteTimeout:        cfg.Server.WriteTimeout.Or(netutil.DefaultWriteTimeout),
+		DialTimeout:         cfg.Server.DialTimeout.Duration,
+		UDPIdleTimeout:      cfg.Server.UDPIdleTimeout.Duration,
+		MetricsPush:         pushConfig,
+		AdminConfig:         adminConfig,
+	})
+}
+
+func runServer(cfg server.Config) {
+	if cfg.ListenAddr == "" {
+		fatalConfig("❌ 请指定监听地址 (-listen)")
+	}
+	if cfg.TargetAddr == "" {
+		fatalConfig("❌ 请指定目标地址 (-target)，例如 CobaltStrike TeamServer 地址")
+	}
+	if cfg.FIPS && cfg.KeyFile == "" {
+		fatalConfig("❌ FIPS 模式需要预共享密钥文件 (-key-file)，不支持由密码派生密钥")
+	}
+	if !cfg.RawTLS && cfg.KeyFile == "" {
+		checkPassword(cfg.Password)
+	}
+
+	masked := cfg
+	masked.Password = ""
+	masked.AdminConfig.Token = ""
+	masked.Control.Token = ""
+	crash.SetConfigHash(masked)
+
 	srv, err := server.New(cfg)
 	if err != nil {
-		log.Fatalf("❌ 创建 Server 失败: %v", err)
+		fatalConfig("❌ 创建 Server 失败: %v", err)
+	}
+	if dumpOnly {
+		printConfig(srv.EffectiveConfig())
+		return
+	}
+	if checkOnly {
+		if err := srv.CheckPorts(); err != nil {
+			fatalConfig("❌ %v", err)
+		}
+		log.Println("✅ 配置检查通过，所有监听地址可用")
+		return
 	}
 
 	go func() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-		<-sigChan
-		log.Println("\n⏹️ 正在关闭 Server...")
-		srv.Stop()
-		os.Exit(0)
+		upgradeChan := make(chan os.Signal, 1)
+		if len(upgradeSignals) > 0 {
+			signal.Notify(upgradeChan, upgradeSignals...)
+		}
+
+		draining := false
+		for {
+			select {
+			case sig := <-sigChan:
+				if sig == syscall.SIGTERM && !draining {
+					draining = true
+					log.Println("⏹️ 收到 SIGTERM，停止接受新连接并等待会话结束 (再次发送信号立即退出)")
+					go func() {
+						srv.Drain()
+						srv.Stop()
+						os.Exit(0)
+					}()
+					continue
+				}
+				log.Println("\n⏹️ 正在关闭 Server...")
+				srv.Stop()
+			case <-upgradeChan:
+				if err := srv.Upgrade(); err != nil {
+					log.Printf("[Server] ❌ 热升级失败，继续由当前进程提供服务: %v", err)
+					continue
+				}
+				srv.Drain()
+				srv.Stop()
+			case <-srv.Killed():
+			}
+			os.Exit(0)
+		}
 	}()
 
-	if err := srv.Start(); err != nil {
+	if statusJSON {
+		go printStatus(srv)
+	}
+
+	if err := srv.Start(context.Background()); err != nil {
+		crash.Exit("server", err)
 		log.Fatalf("❌ Server 启动失败: %v", err)
 	}
+	select {}
+}
+
+func printStatus(srv *server.Server) {
+	<-srv.Ready()
+	st := srv.Status()
+	st.ConfigHash = crash.ConfigHash()
+	if err := status.Print(st); err != nil {
+		log.Printf("⚠️ 输出启动状态失败: %v", err)
+	}
+}
+
+func parseExpiry(values ...string) time.Time {
+	var earliest time.Time
+	for _, value := range values {
+		value = trimSpace(value)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t, err = time.ParseInLocation("2006-01-02", value, time.Local)
+		}
+		if err != nil {
+			fatalConfig("❌ 无效的到期时间 %q，应为 RFC3339 (2025-06-30T18:00:00+08:00) 或日期 (2025-06-30)", value)
+		}
+		if earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+	}
+	return earliest
+}
+
+func runGenpass(args []string) {
+	fs := flag.NewFlagSet("genpass", flag.ExitOnError)
+	length := fs.Int("length", crypto.DefaultGeneratedLength, "密码长度 (最少 12)")
+	fs.Parse(args)
+
+	password, err := crypto.GeneratePassword(*length)
+	if err != nil {
+		log.Fatalf("❌ 生成密码失败: %v", err)
+	}
+	fmt.Println(password)
+}
+
+func runGenkey(args []string) {
+	fs := flag.NewFlagSet("genkey", flag.ExitOnError)
+	out := fs.String("out", "", "写入密钥文件 (权限 0600，留空输出到 stdout)")
+	force := fs.Bool("force", false, "覆盖已存在的密钥文件")
+	fs.Parse(args)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		log.Fatalf("❌ 生成密钥失败: %v", err)
+	}
+	if *out == "" {
+		fmt.Println(crypto.EncodeKey(key))
+		return
+	}
+	if _, err := os.Stat(*out); err == nil && !*force {
+		fatalConfig("❌ 密钥文件 %s 已存在 (使用 -force 覆盖)", *out)
+	}
+	if err := crypto.WriteKeyFile(*out, key); err != nil {
+		log.Fatalf("❌ 写入密钥文件失败: %v", err)
+	}
+	log.Printf("[Key] 🔑 密钥已写入 %s，两端使用 -key-file (配置文件中为 key_file) 加载同一文件", *out)
+}
+
+func fatalConfig(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	os.Exit(exitConfig)
+}
+
+func checkPassword(password string) {
+	err := crypto.CheckPassword(password)
+	if err == nil {
+		return
+	}
+	if insecurePassword {
+		log.Printf("[Config] ⚠️ %v，已按 -insecure-allow-default 继续启动", err)
+		return
+	}
+	if errors.Is(err, crypto.ErrDefaultPassword) {
+		fatalConfig("❌ 密码为内置默认值 %s，任何拿到本程序的人都能解密流量。请用 -password 指定强密码 (可由 tunnel-server genpass 生成)，或加 -insecure-allow-default 强制启动", crypto.DefaultPassword)
+	}
+	fatalConfig("❌ 密码强度不足 (%v)，请使用 tunnel-server genpass 生成强密码，或加 -insecure-allow-default 强制启动", err)
+}
+
+func containerMode() {
+	if !logging.InContainer() {
+		return
+	}
+	log.SetFlags(0)
+	out := os.Stdout
+	if dumpOnly {
+		out = os.Stderr
+	}
+	crash.SetLogOutput(logging.NewJSONWriter(out))
+}
+
+func eventLogMode(source string) {
+	if source == "" || eventLog != nil {
+		return
+	}
+	ev, err := logging.OpenEventLog(source)
+	if err != nil {
+		fatalConfig("❌ 打开 Windows 事件日志失败: %v", err)
+	}
+	eventLog = ev
+	crash.TeeLogOutput(ev)
+}
+
+func secretsFromEnv(secrets map[string]*string, envs map[string]string) {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, dst := range secrets {
+		if set[name] {
+			continue
+		}
+		value, ok, err := config.LookupSecret(envs[name])
+		if err != nil {
+			fatalConfig("❌ 读取密钥失败: %v", err)
+		}
+		if ok {
+			*dst = value
+		}
+	}
 }
 
 func splitAndTrim(s string) []string {
@@ -238,6 +1104,16 @@ func splitAndTrim(s string) []string {
 	return parts
 }
 
+func splitSchedule(s string) []string {
+	var windows []string
+	for _, part := range splitString(s, ";") {
+		if part = trimSpace(part); part != "" {
+			windows = append(windows, part)
+		}
+	}
+	return windows
+}
+
 func splitString(s, sep string) []string {
 	result := make([]string, 0)
 	start := 0
@@ -262,3 +1138,46 @@ func trimSpace(s string) string {
 	}
 	return s[start:end]
 }
+
+func parsePlainForwards(s string) []server.PlainForward {
+	var forwards []server.PlainForward
+	for _, item := range splitAndTrim(s) {
+		var parts []string
+		start := 0
+		for i := 0; i <= len(item); i++ {
+			if i == len(item) || item[i] == '=' {
+				parts = append(parts, trimSpace(item[start:i]))
+				start = i + 1
+			}
+		}
+		var fwd server.PlainForward
+		switch len(parts) {
+		case 2:
+			fwd.Listen, fwd.Target = parts[0], parts[1]
+		case 3:
+			fwd.Name, fwd.Listen, fwd.Target = parts[0], parts[1], parts[2]
+		}
+		if fwd.Listen == "" || fwd.Target == "" {
+			fatalConfig("❌ 无效的明文转发: %s (格式: [名称=]监听地址=目标地址)", item)
+		}
+		forwards = append(forwards, fwd)
+	}
+	return forwards
+}
+
+func parseHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, item := range splitAndTrim(s) {
+		name, value := item, ""
+		for i := 0; i < len(item); i++ {
+			if item[i] == '=' {
+				name, value = trimSpace(item[:i]), trimSpace(item[i+1:])
+				break
+			}
+		}
+		if name != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package main
+
+import "os"
+
+var upgradeSignals []os.Signal
@@ -6,6 +6,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Mode string
@@ -15,6 +16,11 @@ const (
 	ModeBlacklist Mode = "blacklist"
 )
 
+const (
+	RuleBanned  = "banned"
+	RuleInvalid = "invalid_ip"
+)
+
 type ACL struct {
 	mu        sync.RWMutex
 	enabled   bool
@@ -23,6 +29,18 @@ type ACL struct {
 	blacklist []*net.IPNet
 	whiteIPs  []net.IP
 	blackIPs  []net.IP
+	banned    map[string]time.Time
+	match     Match
+	logic     string
+	rules     *policy
+	policy    *policy
+	geo       *GeoIP
+	pin       PinConfig
+	pinned    string
+	changed   chan struct{}
+
+	deniedMu sync.Mutex
+	denied   map[string]int64
 }
 
 type Config struct {
@@ -30,18 +48,75 @@ type Config struct {
 	Mode      string
 	Whitelist []string
 	Blacklist []string
+	Logic     string
+	Match     Match
+
+	Rules   []Rule
+	Default string
+	GeoIP   string
+
+	Pin PinConfig
+
+	KernelFilter bool
 }
 
 func New(cfg Config) (*ACL, error) {
 	acl := &ACL{
 		enabled: cfg.Enable,
 		mode:    Mode(cfg.Mode),
+		banned:  make(map[string]time.Time),
+		changed: make(chan struct{}, 1),
+	}
+
+	pin, err := normalizePin(cfg.Pin)
+	if err != nil {
+		return nil, err
+	}
+	if pin.Enable && len(cfg.Rules) > 0 {
+		return nil, fmt.Errorf("acl pin cannot be combined with ordered rules")
+	}
+	acl.pin = pin
+	if pin.Enable {
+		log.Printf("[ACL] 📌 首个认证客户端将被锁定 (IPv4 /%d，IPv6 /%d)", pin.PrefixV4, pin.PrefixV6)
 	}
 
 	if !cfg.Enable {
+		acl.rebuild()
 		return acl, nil
 	}
 
+	logic, err := parseLogic(cfg.Logic)
+	if err != nil {
+		return nil, err
+	}
+	acl.logic = logic
+	acl.match = normalizeMatch(cfg.Match)
+
+	if len(cfg.Rules) > 0 {
+		rules, err := compileRules(cfg.Rules, cfg.Default)
+		if err != nil {
+			return nil, err
+		}
+		acl.rules = rules
+	}
+
+	if cfg.GeoIP != "" {
+		geo, err := LoadGeoIP(cfg.GeoIP)
+		if err != nil {
+			return nil, err
+		}
+		acl.geo = geo
+		log.Printf("[ACL] 🌍 已加载 GeoIP 数据: %d 条", geo.Len())
+	}
+
+	if acl.rules != nil && acl.geo == nil {
+		for _, r := range acl.rules.rules {
+			if r.countries != nil {
+				return nil, fmt.Errorf("rule %s matches countries but no geoip file is configured", r.name)
+			}
+		}
+	}
+
 	for _, item := range cfg.Whitelist {
 		if err := acl.addToWhitelist(item); err != nil {
 			return nil, fmt.Errorf("invalid whitelist entry '%s': %w", item, err)
@@ -54,14 +129,24 @@ func New(cfg Config) (*ACL, error) {
 		}
 	}
 
+	acl.rebuild()
+
+	if acl.rules != nil {
+		log.Printf("[ACL] ✅ 初始化完成，规则: %d 条，默认动作: %s", len(acl.rules.rules), actionName(acl.rules.allow))
+		return acl, nil
+	}
+
 	log.Printf("[ACL] ✅ 初始化完成，模式: %s，白名单: %d 条，黑名单: %d 条",
 		acl.mode, len(acl.whitelist)+len(acl.whiteIPs), len(acl.blacklist)+len(acl.blackIPs))
+	if !acl.match.empty() {
+		log.Printf("[ACL] 🧬 启用请求特征匹配 (与 IP 规则组合方式: %s)", acl.logic)
+	}
 
 	return acl, nil
 }
 
 func (a *ACL) addToWhitelist(item string) error {
-	item = strings.TrimSpace(item)
+	item = trimBrackets(strings.TrimSpace(item))
 	if item == "" {
 		return nil
 	}
@@ -83,7 +168,7 @@ func (a *ACL) addToWhitelist(item string) error {
 }
 
 func (a *ACL) addToBlacklist(item string) error {
-	item = strings.TrimSpace(item)
+	item = trimBrackets(strings.TrimSpace(item))
 	if item == "" {
 		return nil
 	}
@@ -105,88 +190,118 @@ func (a *ACL) addToBlacklist(item string) error {
 }
 
 func (a *ACL) IsAllowed(addr string) bool {
-	if !a.enabled {
-		return true
-	}
+	return a.decide(Request{Addr: addr}, TransportTCP)
+}
 
-	ip := extractIP(addr)
-	if ip == nil {
-		log.Printf("[ACL] ⚠️ 无法解析 IP 地址: %s", addr)
-		return false
-	}
+func (a *ACL) IsRequestAllowed(req Request) bool {
+	return a.decide(req, TransportHTTP)
+}
 
-	a.mu.RLock()
-	defer a.mu.RUnlock()
+func (a *ACL) Evaluate(req Request, transport string) (bool, string) {
+	allowed, rule, _ := a.evaluate(req, transport)
+	return allowed, rule
+}
 
-	switch a.mode {
-	case ModeWhitelist:
-		allowed := a.isInWhitelist(ip)
-		if !allowed {
-			log.Printf("[ACL] 🚫 拒绝访问 (不在白名单): %s", addr)
-		}
-		return allowed
+func (a *ACL) decide(req Request, transport string) bool {
+	allowed, rule, reason := a.evaluate(req, transport)
+	if !allowed {
+		log.Printf("[ACL] 🚫 拒绝访问 (%s): %s", reason, req.Addr)
+		a.countDenied(rule)
+	}
+	return allowed
+}
 
-	case ModeBlacklist:
-		blocked := a.isInBlacklist(ip)
-		if blocked {
-			log.Printf("[ACL] 🚫 拒绝访问 (在黑名单中): %s", addr)
-		}
-		return !blocked
+func (a *ACL) countDenied(rule string) {
+	a.deniedMu.Lock()
+	defer a.deniedMu.Unlock()
+	if a.denied == nil {
+		a.denied = make(map[string]int64)
+	}
+	a.denied[rule]++
+}
 
-	default:
-		return true
+func (a *ACL) Denied() map[string]int64 {
+	a.deniedMu.Lock()
+	defer a.deniedMu.Unlock()
+	denied := make(map[string]int64, len(a.denied))
+	for rule, n := range a.denied {
+		denied[rule] = n
 	}
+	return denied
 }
 
-func (a *ACL) isInWhitelist(ip net.IP) bool {
-	for _, wip := range a.whiteIPs {
-		if wip.Equal(ip) {
-			return true
-		}
+func (a *ACL) evaluate(req Request, transport string) (bool, string, string) {
+	if a.IsBanned(req.Addr) {
+		return false, RuleBanned, "已封禁"
 	}
 
-	for _, ipNet := range a.whitelist {
-		if ipNet.Contains(ip) {
-			return true
-		}
+	a.mu.RLock()
+	enabled, p, geo := a.enabled, a.policy, a.geo
+	a.mu.RUnlock()
+
+	if !enabled {
+		return true, "", ""
 	}
 
-	return false
-}
+	ip := extractIP(req.Addr)
+	if ip == nil {
+		return false, RuleInvalid, "无法解析 IP 地址"
+	}
 
-func (a *ACL) isInBlacklist(ip net.IP) bool {
-	for _, bip := range a.blackIPs {
-		if bip.Equal(ip) {
-			return true
+	var country string
+	var looked bool
+	lookup := func() string {
+		if !looked {
+			country, looked = geo.Country(ip), true
 		}
+		return country
 	}
 
-	for _, ipNet := range a.blacklist {
-		if ipNet.Contains(ip) {
-			return true
+	now := time.Now()
+	for _, r := range p.rules {
+		if r.matches(req, transport, ip, lookup, now) {
+			return r.allow, r.name, r.reason
 		}
 	}
+	return p.allow, "default", p.defaultReason
+}
 
-	return false
+func (a *ACL) rebuild() {
+	defer a.notify()
+	if a.rules != nil {
+		a.policy = a.rules
+		return
+	}
+	a.policy = a.legacyPolicy()
+}
+
+func actionName(allow bool) string {
+	if allow {
+		return ActionAllow
+	}
+	return ActionDeny
 }
 
 func (a *ACL) AddWhitelist(item string) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	defer a.rebuild()
 	return a.addToWhitelist(item)
 }
 
 func (a *ACL) AddBlacklist(item string) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	defer a.rebuild()
 	return a.addToBlacklist(item)
 }
 
 func (a *ACL) RemoveWhitelist(item string) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	defer a.rebuild()
 
-	item = strings.TrimSpace(item)
+	item = trimBrackets(strings.TrimSpace(item))
 	if strings.Contains(item, "/") {
 		_, target, err := net.ParseCIDR(item)
 		if err != nil {
@@ -215,8 +330,9 @@ func (a *ACL) RemoveWhitelist(item string) {
 func (a *ACL) RemoveBlacklist(item string) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	defer a.rebuild()
 
-	item = strings.TrimSpace(item)
+	item = trimBrackets(strings.TrimSpace(item))
 	if strings.Contains(item, "/") {
 		_, target, err := net.ParseCIDR(item)
 		if err != nil {
@@ -242,16 +358,128 @@ func (a *ACL) RemoveBlacklist(item string) {
 	}
 }
 
+func (a *ACL) Ban(addr string, duration time.Duration) {
+	ip := extractIP(addr)
+	if ip == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.banned == nil {
+		a.banned = make(map[string]time.Time)
+	}
+	a.banned[ip.String()] = time.Now().Add(duration)
+	a.notify()
+	log.Printf("[ACL] ⛔ 封禁 IP: %s，时长: %v", ip, duration)
+}
+
+func (a *ACL) Unban(addr string) {
+	ip := extractIP(addr)
+	if ip == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.banned[ip.String()]; ok {
+		delete(a.banned, ip.String())
+		a.notify()
+	}
+}
+
+func (a *ACL) IsBanned(addr string) bool {
+	ip := extractIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	a.mu.RLock()
+	expiry, ok := a.banned[ip.String()]
+	a.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	if time.Now().After(expiry) {
+		a.Unban(addr)
+		return false
+	}
+	return true
+}
+
+func (a *ACL) SweepBans() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now, expired := time.Now(), 0
+	for ip, expiry := range a.banned {
+		if !now.Before(expiry) {
+			delete(a.banned, ip)
+			expired++
+		}
+	}
+	if expired > 0 {
+		a.notify()
+	}
+	return expired
+}
+
+func (a *ACL) Bans() map[string]time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	now := time.Now()
+	bans := make(map[string]time.Time, len(a.banned))
+	for ip, expiry := range a.banned {
+		if now.Before(expiry) {
+			bans[ip] = expiry
+		}
+	}
+	return bans
+}
+
+func (a *ACL) MergeBans(bans map[string]time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.banned == nil {
+		a.banned = make(map[string]time.Time)
+	}
+
+	now, added := time.Now(), 0
+	for ip, expiry := range bans {
+		if !now.Before(expiry) {
+			continue
+		}
+		current, ok := a.banned[ip]
+		if !ok || now.After(current) {
+			added++
+		}
+		if !ok || expiry.After(current) {
+			a.banned[ip] = expiry
+		}
+	}
+	if added > 0 {
+		a.notify()
+	}
+	return added
+}
+
 func (a *ACL) SetMode(mode Mode) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	a.mode = mode
+	a.rebuild()
 }
 
 func (a *ACL) SetEnabled(enabled bool) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	a.enabled = enabled
+	a.notify()
 }
 
 func (a *ACL) Stats() map[string]interface{} {
@@ -263,9 +491,22 @@ func (a *ACL) Stats() map[string]interface{} {
 		"mode":            a.mode,
 		"whitelist_count": len(a.whitelist) + len(a.whiteIPs),
 		"blacklist_count": len(a.blacklist) + len(a.blackIPs),
+		"banned_count":    len(a.banned),
+		"match_enabled":   !a.match.empty(),
+		"logic":           a.logic,
+		"rules_count":     len(a.policy.rules),
+		"geoip_entries":   a.geo.Len(),
+		"pinned":          a.pinned,
 	}
 }
 
+func trimBrackets(item string) string {
+	if strings.HasPrefix(item, "[") && strings.HasSuffix(item, "]") {
+		return item[1 : len(item)-1]
+	}
+	return item
+}
+
 func extractIP(addr string) net.IP {
 	if ip := net.ParseIP(addr); ip != nil {
 		return ip
@@ -280,7 +521,11 @@ func extractIP(addr string) net.IP {
 }
 
 func NewDisabled() *ACL {
-	return &ACL{
+	acl := &ACL{
 		enabled: false,
+		banned:  make(map[string]time.Time),
+		changed: make(chan struct{}, 1),
 	}
+	acl.rebuild()
+	return acl
 }
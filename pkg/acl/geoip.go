@@ -0,0 +1,120 @@
+package acl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+type geoRange struct {
+	start   net.IP
+	end     net.IP
+	country string
+}
+
+type GeoIP struct {
+	ranges []geoRange
+}
+
+func LoadGeoIP(path string) (*GeoIP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoip file: %w", err)
+	}
+	defer f.Close()
+
+	g := &GeoIP{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, ",")
+		for i := range fields {
+			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), `"`)
+		}
+
+		var r geoRange
+		switch len(fields) {
+		case 2:
+			_, ipNet, err := net.ParseCIDR(fields[0])
+			if err != nil {
+				if line == 1 {
+					continue
+				}
+				return nil, fmt.Errorf("geoip line %d: %w", line, err)
+			}
+			r = geoRange{start: ipNet.IP.To16(), end: lastIP(ipNet), country: fields[1]}
+		case 3:
+			start, end := net.ParseIP(fields[0]), net.ParseIP(fields[1])
+			if start == nil || end == nil {
+				if line == 1 {
+					continue
+				}
+				return nil, fmt.Errorf("geoip line %d: invalid range", line)
+			}
+			r = geoRange{start: start.To16(), end: end.To16(), country: fields[2]}
+		default:
+			return nil, fmt.Errorf("geoip line %d: expected cidr,country or start,end,country", line)
+		}
+
+		r.country = strings.ToUpper(r.country)
+		g.ranges = append(g.ranges, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read geoip file: %w", err)
+	}
+
+	sort.Slice(g.ranges, func(i, j int) bool {
+		return bytes.Compare(g.ranges[i].start, g.ranges[j].start) < 0
+	})
+	return g, nil
+}
+
+func (g *GeoIP) Country(ip net.IP) string {
+	if g == nil || ip == nil {
+		return ""
+	}
+	ip = ip.To16()
+
+	i := sort.Search(len(g.ranges), func(i int) bool {
+		return bytes.Compare(g.ranges[i].start, ip) > 0
+	})
+	if i == 0 {
+		return ""
+	}
+	r := g.ranges[i-1]
+	if bytes.Compare(ip, r.end) <= 0 {
+		return r.country
+	}
+	return ""
+}
+
+func (g *GeoIP) Len() int {
+	if g == nil {
+		return 0
+	}
+	return len(g.ranges)
+}
+
+func lastIP(ipNet *net.IPNet) net.IP {
+	ip := ipNet.IP.To16()
+	mask := ipNet.Mask
+	if len(mask) == net.IPv4len {
+		mask = append(net.CIDRMask(96, 128)[:12], mask...)
+	}
+
+	last := make(net.IP, net.IPv6len)
+	for i := range ip {
+		last[i] = ip[i] | ^mask[i]
+	}
+	return last
+}
@@ -0,0 +1,148 @@
+package acl
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	LogicAnd = "and"
+	LogicOr  = "or"
+)
+
+type Match struct {
+	JA3       []string
+	JA4       []string
+	UserAgent []string
+	Headers   map[string]string
+	Paths     []string
+}
+
+type Request struct {
+	Addr   string
+	JA3    string
+	JA4    string
+	Header http.Header
+	Path   string
+}
+
+func (m Match) empty() bool {
+	return len(m.JA3) == 0 && len(m.JA4) == 0 && len(m.UserAgent) == 0 && len(m.Headers) == 0 && len(m.Paths) == 0
+}
+
+func normalizeMatch(m Match) Match {
+	out := Match{
+		JA3:       lowerAll(m.JA3),
+		JA4:       lowerAll(m.JA4),
+		UserAgent: trimAll(m.UserAgent),
+		Paths:     trimAll(m.Paths),
+	}
+	if len(m.Headers) > 0 {
+		out.Headers = make(map[string]string, len(m.Headers))
+		for name, value := range m.Headers {
+			out.Headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+	}
+	return out
+}
+
+func parseLogic(logic string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(logic)) {
+	case "", LogicAnd:
+		return LogicAnd, nil
+	case LogicOr:
+		return LogicOr, nil
+	}
+	return "", fmt.Errorf("invalid acl logic: %s", logic)
+}
+
+func (m Match) check(req Request) bool {
+	if len(m.JA3) > 0 || len(m.JA4) > 0 {
+		if !contains(m.JA3, strings.ToLower(req.JA3)) && !contains(m.JA4, strings.ToLower(req.JA4)) {
+			return false
+		}
+	}
+
+	if len(m.UserAgent) > 0 && !matchAny(m.UserAgent, req.Header.Get("User-Agent")) {
+		return false
+	}
+
+	for name, pattern := range m.Headers {
+		values, ok := req.Header[name]
+		if !ok {
+			return false
+		}
+		if pattern != "" && pattern != "*" && !matchAny([]string{pattern}, strings.Join(values, ", ")) {
+			return false
+		}
+	}
+
+	if len(m.Paths) > 0 && !matchAny(m.Paths, req.Path) {
+		return false
+	}
+
+	return true
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if wildcardMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func wildcardMatch(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
+
+func contains(list []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(items []string) []string {
+	var out []string
+	for _, item := range items {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func trimAll(items []string) []string {
+	var out []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package acl
+
+import (
+	"fmt"
+	"log"
+	"net"
+)
+
+type PinConfig struct {
+	Enable   bool
+	PrefixV4 int
+	PrefixV6 int
+}
+
+func normalizePin(cfg PinConfig) (PinConfig, error) {
+	if !cfg.Enable {
+		return cfg, nil
+	}
+	if cfg.PrefixV4 == 0 {
+		cfg.PrefixV4 = 32
+	}
+	if cfg.PrefixV6 == 0 {
+		cfg.PrefixV6 = 128
+	}
+	if cfg.PrefixV4 < 1 || cfg.PrefixV4 > 32 {
+		return cfg, fmt.Errorf("invalid pin prefix for IPv4: %d", cfg.PrefixV4)
+	}
+	if cfg.PrefixV6 < 1 || cfg.PrefixV6 > 128 {
+		return cfg, fmt.Errorf("invalid pin prefix for IPv6: %d", cfg.PrefixV6)
+	}
+	return cfg, nil
+}
+
+func (a *ACL) PinClient(addr string) {
+	ip := extractIP(addr)
+	if ip == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.pin.Enable || a.pinned != "" {
+		return
+	}
+
+	var ipNet *net.IPNet
+	if ip4 := ip.To4(); ip4 != nil {
+		mask := net.CIDRMask(a.pin.PrefixV4, 32)
+		ipNet = &net.IPNet{IP: ip4.Mask(mask), Mask: mask}
+	} else {
+		mask := net.CIDRMask(a.pin.PrefixV6, 128)
+		ipNet = &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	}
+
+	a.whitelist = append(a.whitelist, ipNet)
+	a.mode = ModeWhitelist
+	a.enabled = true
+	a.pinned = ipNet.String()
+	a.rebuild()
+
+	log.Printf("[ACL] 📌 已锁定到首个认证客户端: %s，切换为白名单模式", a.pinned)
+}
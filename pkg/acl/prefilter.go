@@ -0,0 +1,50 @@
+package acl
+
+import (
+	"net"
+	"time"
+)
+
+type Prefilter struct {
+	Drop       []*net.IPNet
+	Allow      []*net.IPNet
+	DropOthers bool
+}
+
+func (a *ACL) Changes() <-chan struct{} {
+	return a.changed
+}
+
+func (a *ACL) notify() {
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (a *ACL) Prefilter() Prefilter {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var f Prefilter
+	now := time.Now()
+	for ip, expiry := range a.banned {
+		if now.Before(expiry) {
+			f.Drop = appendHosts(f.Drop, []net.IP{net.ParseIP(ip)})
+		}
+	}
+
+	if !a.enabled || a.rules != nil {
+		return f
+	}
+
+	ipOnly := a.match.empty() || a.logic == LogicAnd
+	switch {
+	case a.mode == ModeWhitelist && ipOnly:
+		f.Allow = appendHosts(append([]*net.IPNet(nil), a.whitelist...), a.whiteIPs)
+		f.DropOthers = true
+	case a.mode == ModeBlacklist && ipOnly:
+		f.Drop = appendHosts(append(f.Drop, a.blacklist...), a.blackIPs)
+	}
+	return f
+}
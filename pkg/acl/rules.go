@@ -0,0 +1,243 @@
+package acl
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+
+	TransportTCP  = "tcp"
+	TransportHTTP = "http"
+)
+
+type Rule struct {
+	Name      string
+	Action    string
+	IPs       []string
+	Countries []string
+	Days      []string
+	Hours     string
+	Transport string
+	Match
+}
+
+type rule struct {
+	name      string
+	reason    string
+	allow     bool
+	nets      []*net.IPNet
+	hasIPs    bool
+	countries map[string]bool
+	window    *Window
+	transport string
+	match     Match
+}
+
+type policy struct {
+	rules         []*rule
+	allow         bool
+	defaultReason string
+}
+
+func compileRules(rules []Rule, defaultAction string) (*policy, error) {
+	p := &policy{defaultReason: "默认策略"}
+	switch strings.ToLower(strings.TrimSpace(defaultAction)) {
+	case "", ActionDeny:
+	case ActionAllow:
+		p.allow = true
+	default:
+		return nil, fmt.Errorf("invalid default action: %s", defaultAction)
+	}
+
+	for i, r := range rules {
+		compiled, err := compileRule(r)
+		if err != nil {
+			name := r.Name
+			if name == "" {
+				name = "#" + strconv.Itoa(i+1)
+			}
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		if compiled.name == "" {
+			compiled.name = "#" + strconv.Itoa(i+1)
+		}
+		compiled.reason = "规则 " + compiled.name
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+func compileRule(r Rule) (*rule, error) {
+	compiled := &rule{
+		name:      strings.TrimSpace(r.Name),
+		transport: strings.ToLower(strings.TrimSpace(r.Transport)),
+		match:     normalizeMatch(r.Match),
+	}
+
+	switch strings.ToLower(strings.TrimSpace(r.Action)) {
+	case ActionAllow:
+		compiled.allow = true
+	case ActionDeny:
+	default:
+		return nil, fmt.Errorf("invalid action: %s", r.Action)
+	}
+
+	switch compiled.transport {
+	case "", TransportTCP, TransportHTTP:
+	default:
+		return nil, fmt.Errorf("invalid transport: %s", r.Transport)
+	}
+
+	for _, item := range r.IPs {
+		ipNet, err := parseNet(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip '%s': %w", item, err)
+		}
+		if ipNet != nil {
+			compiled.nets = append(compiled.nets, ipNet)
+			compiled.hasIPs = true
+		}
+	}
+
+	for _, country := range r.Countries {
+		if country = strings.ToUpper(strings.TrimSpace(country)); country != "" {
+			if compiled.countries == nil {
+				compiled.countries = make(map[string]bool)
+			}
+			compiled.countries[country] = true
+		}
+	}
+
+	window, err := ParseWindow(r.Days, r.Hours)
+	if err != nil {
+		return nil, err
+	}
+	compiled.window = window
+
+	return compiled, nil
+}
+
+func parseNet(item string) (*net.IPNet, error) {
+	item = strings.TrimSpace(item)
+	if item == "" {
+		return nil, nil
+	}
+	if strings.Contains(item, "/") {
+		_, ipNet, err := net.ParseCIDR(item)
+		return ipNet, err
+	}
+
+	ip := net.ParseIP(item)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address")
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func (r *rule) matches(req Request, transport string, ip net.IP, country func() string, now time.Time) bool {
+	if r.transport != "" && r.transport != transport {
+		return false
+	}
+
+	if r.hasIPs {
+		if ip == nil || !containsIP(r.nets, ip) {
+			return false
+		}
+	}
+
+	if r.countries != nil && !r.countries[country()] {
+		return false
+	}
+
+	if !r.window.Contains(now) {
+		return false
+	}
+
+	if !r.match.empty() {
+		if transport != TransportHTTP {
+			return false
+		}
+		if !r.match.check(req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *ACL) legacyPolicy() *policy {
+	var listed []*net.IPNet
+	if a.mode == ModeWhitelist {
+		listed = append(listed, a.whitelist...)
+		listed = appendHosts(listed, a.whiteIPs)
+	} else {
+		listed = append(listed, a.blacklist...)
+		listed = appendHosts(listed, a.blackIPs)
+	}
+
+	ipRule := func(name string, allow bool, transport string, match Match) *rule {
+		return &rule{name: name, reason: name, allow: allow, nets: listed, hasIPs: true, transport: transport, match: match}
+	}
+	matchRule := &rule{name: "请求特征", reason: "请求特征", allow: true, transport: TransportHTTP, match: a.match}
+
+	p := &policy{}
+	switch {
+	case a.mode == ModeWhitelist && a.match.empty():
+		p.rules = []*rule{ipRule("白名单", true, "", Match{})}
+		p.defaultReason = "不在白名单"
+	case a.mode == ModeWhitelist && a.logic == LogicOr:
+		p.rules = []*rule{ipRule("白名单", true, "", Match{}), matchRule}
+		p.defaultReason = "IP 与请求特征均不符合"
+	case a.mode == ModeWhitelist:
+		p.rules = []*rule{
+			ipRule("白名单", true, TransportHTTP, a.match),
+			ipRule("白名单", true, TransportTCP, Match{}),
+		}
+		p.defaultReason = "不在白名单或请求特征不匹配"
+	case a.mode == ModeBlacklist && a.match.empty():
+		p.rules = []*rule{ipRule("在黑名单中", false, "", Match{})}
+		p.allow = true
+	case a.mode == ModeBlacklist && a.logic == LogicOr:
+		p.rules = []*rule{matchRule, ipRule("在黑名单中", false, "", Match{})}
+		p.allow = true
+	case a.mode == ModeBlacklist:
+		p.rules = []*rule{
+			ipRule("在黑名单中", false, "", Match{}),
+			matchRule,
+			{name: "TCP", reason: "TCP", allow: true, transport: TransportTCP},
+		}
+		p.defaultReason = "请求特征不匹配"
+	default:
+		p.allow = true
+	}
+	return p
+}
+
+func appendHosts(nets []*net.IPNet, ips []net.IP) []*net.IPNet {
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			nets = append(nets, &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)})
+		} else {
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+		}
+	}
+	return nets
+}
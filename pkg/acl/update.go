@@ -0,0 +1,102 @@
+package acl
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+func (a *ACL) Allow(item string) error {
+	item, err := a.prepareUpdate(item)
+	if err != nil {
+		return err
+	}
+
+	a.Unban(item)
+	a.RemoveBlacklist(item)
+	a.RemoveWhitelist(item)
+	if err := a.AddWhitelist(item); err != nil {
+		return err
+	}
+	log.Printf("[ACL] ➕ 加入白名单: %s", item)
+	return nil
+}
+
+func (a *ACL) Deny(item string) error {
+	item, err := a.prepareUpdate(item)
+	if err != nil {
+		return err
+	}
+
+	a.RemoveWhitelist(item)
+	a.RemoveBlacklist(item)
+	if err := a.AddBlacklist(item); err != nil {
+		return err
+	}
+	log.Printf("[ACL] ➖ 加入黑名单: %s", item)
+	return nil
+}
+
+func (a *ACL) Remove(item string) error {
+	item, err := a.prepareUpdate(item)
+	if err != nil {
+		return err
+	}
+
+	a.RemoveWhitelist(item)
+	a.RemoveBlacklist(item)
+	log.Printf("[ACL] 🗑️ 从名单中移除: %s", item)
+	return nil
+}
+
+func (a *ACL) prepareUpdate(item string) (string, error) {
+	a.mu.RLock()
+	enabled, rules := a.enabled, a.rules
+	a.mu.RUnlock()
+
+	if !enabled {
+		return "", fmt.Errorf("acl is disabled")
+	}
+	if rules != nil {
+		return "", fmt.Errorf("acl uses ordered rules, edit the rules config instead")
+	}
+
+	item = strings.TrimSpace(item)
+	if strings.Contains(item, "/") {
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return "", fmt.Errorf("invalid entry '%s': %w", item, err)
+		}
+		return ipNet.String(), nil
+	}
+
+	ip := extractIP(item)
+	if ip == nil {
+		return "", fmt.Errorf("invalid entry '%s': invalid IP address", item)
+	}
+	return ip.String(), nil
+}
+
+func (a *ACL) Entries() map[string]interface{} {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return map[string]interface{}{
+		"enabled":   a.enabled,
+		"mode":      a.mode,
+		"whitelist": entryStrings(a.whitelist, a.whiteIPs),
+		"blacklist": entryStrings(a.blacklist, a.blackIPs),
+	}
+}
+
+func entryStrings(nets []*net.IPNet, ips []net.IP) []string {
+	out := make([]string, 0, len(nets)+len(ips))
+	for _, ipNet := range nets {
+		out = append(out, ipNet.String())
+	}
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Window struct {
+	days     map[time.Weekday]bool
+	from, to int
+	hasHours bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func ParseWindow(days []string, hours string) (*Window, error) {
+	w := &Window{}
+	for _, day := range days {
+		if err := w.addDays(day); err != nil {
+			return nil, err
+		}
+	}
+
+	if hours = strings.TrimSpace(hours); hours != "" {
+		from, to, ok := strings.Cut(hours, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid hours: %s", hours)
+		}
+		var err error
+		if w.from, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.to, err = parseClock(to); err != nil {
+			return nil, err
+		}
+		w.hasHours = true
+	}
+
+	if w.days == nil && !w.hasHours {
+		return nil, nil
+	}
+	return w, nil
+}
+
+func ParseSchedule(spec string) (*Window, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid schedule '%s': expected \"<days> [HH:MM-HH:MM]\"", spec)
+	}
+
+	var days []string
+	if fields[0] != "*" {
+		days = strings.Split(fields[0], ",")
+	}
+	var hours string
+	if len(fields) == 2 {
+		hours = fields[1]
+	}
+
+	w, err := ParseWindow(days, hours)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule '%s': %w", spec, err)
+	}
+	if w == nil {
+		w = &Window{}
+	}
+	return w, nil
+}
+
+func (w *Window) Contains(now time.Time) bool {
+	if w == nil {
+		return true
+	}
+
+	day := now.Weekday()
+	minute := now.Hour()*60 + now.Minute()
+	if w.hasHours && w.from > w.to && minute < w.to {
+		day = (day + 6) % 7
+	}
+	if w.days != nil && !w.days[day] {
+		return false
+	}
+	if w.hasHours && !inWindow(minute, w.from, w.to) {
+		return false
+	}
+	return true
+}
+
+func (w *Window) addDays(spec string) error {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" {
+		return nil
+	}
+	if w.days == nil {
+		w.days = make(map[time.Weekday]bool)
+	}
+
+	from, to, isRange := strings.Cut(spec, "-")
+	start, ok := weekdays[from]
+	if !ok {
+		return fmt.Errorf("invalid day: %s", spec)
+	}
+	if !isRange {
+		w.days[start] = true
+		return nil
+	}
+
+	end, ok := weekdays[to]
+	if !ok {
+		return fmt.Errorf("invalid day: %s", spec)
+	}
+	for day := start; ; day = (day + 1) % 7 {
+		w.days[day] = true
+		if day == end {
+			return nil
+		}
+	}
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func inWindow(minute, from, to int) bool {
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"tunnel/pkg/netutil"
+)
+
+type Config struct {
+	Enable      bool
+	Listen      string
+	Token       string
+	EnablePprof bool
+	EnableKill  bool
+}
+
+type Server struct {
+	config Config
+	mux    *http.ServeMux
+	srv    *http.Server
+	ln     net.Listener
+}
+
+func New(config Config) (*Server, error) {
+	if config.Listen == "" {
+		return nil, fmt.Errorf("admin listen address is required")
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("admin token is required")
+	}
+
+	a := &Server{
+		config: config,
+		mux:    http.NewServeMux(),
+	}
+
+	if config.EnablePprof {
+		a.mux.HandleFunc("/debug/pprof/", pprof.Index)
+		a.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		a.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		a.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		a.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		a.mux.Handle("/debug/vars", expvar.Handler())
+	}
+
+	return a, nil
+}
+
+func (a *Server) Handle(pattern string, handler http.Handler) {
+	a.mux.Handle(pattern, handler)
+}
+
+func (a *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	a.mux.HandleFunc(pattern, handler)
+}
+
+func (a *Server) HandleJSON(pattern string, source func() interface{}) {
+	a.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, source())
+	})
+}
+
+func (a *Server) PublishVar(name string, source func() interface{}) {
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(source))
+	}
+}
+
+func (a *Server) Start() error {
+	ln, err := netutil.Listen(a.config.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen admin: %w", err)
+	}
+	a.ln = ln
+
+	a.srv = &http.Server{
+		Handler:           a.authenticate(a.mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("[Admin] 🔧 管理接口已启动: http://%s", ln.Addr())
+	if a.config.EnablePprof {
+		log.Printf("[Admin] 🩺 已启用 /debug/pprof 与 /debug/vars")
+	}
+	if a.config.EnableKill {
+		log.Printf("[Admin] 🛑 已启用紧急关闭接口 POST /kill")
+	}
+
+	go func() {
+		if err := a.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[Admin] ⚠️ 管理接口异常退出: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (a *Server) Addr() string {
+	if a.ln == nil {
+		return a.config.Listen
+	}
+	return a.ln.Addr().String()
+}
+
+func (a *Server) Stop() error {
+	if a.srv != nil {
+		return a.srv.Close()
+	}
+	return nil
+}
+
+func (a *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.config.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tunnel-admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const exitUsage = 2
+
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string)
+	Sub   []*Command
+}
+
+func find(cmds []*Command, name string) *Command {
+	for _, cmd := range cmds {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func Dispatch(cmds []*Command, args []string) bool {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return false
+	}
+	cmd := find(cmds, args[0])
+	if cmd == nil {
+		return false
+	}
+	run(cmd, args[0], args[1:])
+	return true
+}
+
+func run(cmd *Command, path string, args []string) {
+	if len(cmd.Sub) == 0 {
+		cmd.Run(args)
+		return
+	}
+	if len(args) > 0 {
+		if sub := find(cmd.Sub, args[0]); sub != nil {
+			run(sub, path+" "+args[0], args[1:])
+			return
+		}
+	}
+	if cmd.Run != nil {
+		cmd.Run(args)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "❌ 用法: %s %s <子命令>\n", Program(), path)
+	PrintCommands(os.Stderr, cmd.Sub)
+	os.Exit(exitUsage)
+}
+
+func Program() string {
+	return filepath.Base(os.Args[0])
+}
+
+func PrintCommands(w io.Writer, cmds []*Command) {
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "  %-18s %s\n", cmd.Name, cmd.Usage)
+	}
+}
+
+func Completion(shell string, cmds []*Command, flags *flag.FlagSet) (string, error) {
+	prog := Program()
+	fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(prog)
+
+	var names []string
+	flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	sort.Strings(names)
+
+	var b strings.Builder
+	switch shell {
+	case "bash":
+	case "zsh":
+		b.WriteString("autoload -U +X compinit && compinit\n")
+		b.WriteString("autoload -U +X bashcompinit && bashcompinit\n")
+	default:
+		return "", fmt.Errorf("unsupported shell %q (bash, zsh)", shell)
+	}
+
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    if [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", commandNames(cmds))
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    if [[ $COMP_CWORD -eq 2 ]]; then\n")
+	b.WriteString("        case \"${COMP_WORDS[1]}\" in\n")
+	for _, cmd := range cmds {
+		if len(cmd.Sub) > 0 {
+			fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", cmd.Name, commandNames(cmd.Sub))
+		}
+	}
+	b.WriteString("        esac\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -o filenames -F %s %s\n", fn, prog)
+	return b.String(), nil
+}
+
+func commandNames(cmds []*Command) string {
+	names := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name)
+	}
+	return strings.Join(names, " ")
+}
@@ -3,8 +3,10 @@ package client
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
@@ -12,245 +14,648 @@ import (
 	"sync"
 	"time"
 
+	"tunnel/pkg/clock"
+	"tunnel/pkg/control"
+	"tunnel/pkg/crash"
 	"tunnel/pkg/crypto"
+	"tunnel/pkg/health"
+	"tunnel/pkg/netutil"
+	"tunnel/pkg/socks5"
 	"tunnel/pkg/transport"
+	"tunnel/pkg/upstream"
 )
 
+const defaultDialTimeout = 10 * time.Second
+
 type Config struct {
+	Name         string
 	ListenAddr   string
 	ServerAddr   string
+	ServerAddrs  []string
 	TargetAddr   string
 	Password     string
+	KeyFile      string
+	Key          []byte
 	EnableHTTPS  bool
+	EnableSOCKS5 bool
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	DialTimeout  time.Duration
 
 	EnableWS bool
 	WSConfig transport.WSConfig
+
+	EnablePoll bool
+
+	ServerTLS           bool
+	ServerTLSSNI        string
+	ServerTLSSkipVerify bool
+
+	RawTLS     bool
+	RawTLSCert string
+	RawTLSKey  string
+
+	FIPS bool
+
+	FrameDebug bool
+
+	MaxConnections int
+
+	Forwards []Forward
+
+	ControlSocket string
+
+	ServerToken string
+	ServerLink  bool
+
+	DiscoverKey string
+
+	EdgeRotation bool
+
+	ServerMark netutil.SocketMark
+
+	Batch crypto.BatchConfig
+
+	RelayEngine string
+
+	HealthListen string
+	Sidecar      bool
+
+	UpstreamProxy string
+
+	DNSOverrides map[string]string
+
+	Routes       []RouteRule
+	DefaultRoute string
+	BypassProxy  string
+
+	Clock clock.Config
 }
 
 type Client struct {
 	config   Config
 	cipher   *crypto.AESCipher
-	ln       net.Listener
 	wsClient *transport.WSClient
+	poll     *transport.PollClient
+	tls      *tls.Config
+	proxy    *upstream.Dialer
+	bypass   *upstream.Dialer
+	edges    *edgeDialer
+	dialer   *net.Dialer
+	router   *router
+	paths    *pathSelector
+	control  *control.Server
+	health   *health.Server
+	discover string
+	started  time.Time
+
+	mu       sync.Mutex
+	forwards map[string]*forward
+	link     *controlLink
+	ctx      context.Context
+	cancel   context.CancelFunc
+	ready    chan struct{}
+
+	upstream   upstreamHealth
+	rediscover chan struct{}
 }
 
 func New(config Config) (*Client, error) {
-	cipher, err := crypto.NewAESCipher(config.Password)
+	if config.EnableHTTPS && config.EnableSOCKS5 {
+		return nil, fmt.Errorf("https and socks5 modes are mutually exclusive")
+	}
+	if config.ServerTLS && (config.EnableWS || config.EnablePoll) {
+		return nil, fmt.Errorf("server tls is for TCP mode, use WebSocket TLS instead")
+	}
+	if err := config.ServerMark.Validate(); err != nil {
+		return nil, err
+	}
+	if err := netutil.ValidateRelayEngine(config.RelayEngine); err != nil {
+		return nil, err
+	}
+	if config.Sidecar && config.HealthListen == "" {
+		return nil, fmt.Errorf("sidecar mode requires a health listen address")
+	}
+	if config.RawTLS {
+		if config.EnableWS || config.EnablePoll || config.EnableHTTPS || config.EnableSOCKS5 {
+			return nil, fmt.Errorf("raw tls mode only supports plain TCP forwarding")
+		}
+		if config.ServerLink || config.Sidecar {
+			return nil, fmt.Errorf("raw tls mode has no control channel for server link or sidecar probes")
+		}
+		if config.RawTLSCert == "" || config.RawTLSKey == "" {
+			return nil, fmt.Errorf("raw tls mode requires a client certificate and key")
+		}
+		config.ServerTLS = true
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaultDialTimeout
+	}
+	config.WSConfig.DialTimeout = config.DialTimeout
+
+	var cipher *crypto.AESCipher
+	var err error
+	switch {
+	case config.FIPS && len(config.Key) > 0:
+		cipher, err = crypto.NewFIPSCipher(config.Key)
+	case config.FIPS:
+		cipher, err = crypto.NewFIPSCipherFromFile(config.KeyFile)
+	case len(config.Key) > 0:
+		cipher, err = crypto.NewAESCipherFromKey(config.Key)
+	default:
+		cipher, err = crypto.NewCipher(config.Password, config.KeyFile)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
+	if cipher.FIPS() {
+		log.Printf("[Client] 🔐 FIPS 模式: AES-256-GCM，HKDF-SHA256 派生密钥 (模块: %s)", crypto.FIPSModule())
+	}
 
+	servers := append([]string{config.ServerAddr}, config.ServerAddrs...)
+	discover, ok := discoverName(config.ServerAddr)
+	if ok {
+		addrs, err := discoverServers(discover, config.DiscoverKey)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(addrs, config.ServerAddrs...)
+	}
+	for i, addr := range servers {
+		if addr == "" {
+			continue
+		}
+		normalized, err := netutil.NormalizeAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server address: %w", err)
+		}
+		servers[i] = normalized
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	client := &Client{
-		config: config,
-		cipher: cipher,
+		config:   config,
+		cipher:   cipher,
+		paths:    newPathSelector(servers, config.ServerMark),
+		dialer:   &net.Dialer{Timeout: config.DialTimeout, Control: config.ServerMark.Control()},
+		discover: discover,
+		forwards: make(map[string]*forward),
+		ctx:      ctx,
+		cancel:   cancel,
+		ready:    make(chan struct{}),
+
+		rediscover: make(chan struct{}, 1),
+	}
+	if config.Sidecar {
+		client.upstream.onFailure = client.reresolve
 	}
 
-	if config.EnableWS {
+	if len(config.DNSOverrides) > 0 {
+		overrides := make(map[string]string, len(config.DNSOverrides))
+		for domain, value := range config.DNSOverrides {
+			overrides[strings.ToLower(strings.TrimSuffix(domain, "."))] = value
+		}
+		client.config.DNSOverrides = overrides
+	}
+
+	router, err := newRouter(config.Routes, config.DefaultRoute)
+	if err != nil {
+		return nil, err
+	}
+	client.router = router
+
+	if config.BypassProxy != "" {
+		bypass, err := upstream.NewDialer(config.BypassProxy)
+		if err != nil {
+			return nil, err
+		}
+		client.bypass = bypass
+	}
+
+	if config.UpstreamProxy != "" {
+		proxy, err := upstream.NewDialer(config.UpstreamProxy)
+		if err != nil {
+			return nil, err
+		}
+		proxy.SetControl(config.ServerMark.Control())
+		client.proxy = proxy
+		client.paths.dial = proxy.Dial
+	}
+
+	if config.ServerTLS {
+		client.tls = &tls.Config{
+			ServerName:         config.ServerTLSSNI,
+			InsecureSkipVerify: config.ServerTLSSkipVerify,
+		}
+	}
+	if config.RawTLS {
+		cert, err := tls.LoadX509KeyPair(config.RawTLSCert, config.RawTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load raw tls client certificate: %w", err)
+		}
+		client.tls.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.EdgeRotation {
+		if !config.EnableWS && !config.EnablePoll {
+			return nil, fmt.Errorf("edge rotation requires WebSocket or long-polling mode")
+		}
+		if client.proxy == nil {
+			client.edges = newEdgeDialer(config.ServerMark, config.DialTimeout)
+		}
+	}
+
+	if config.EnablePoll {
+		client.poll = transport.NewPollClient(config.WSConfig)
+		if client.proxy != nil {
+			client.poll.SetDialer(client.proxy.DialContext)
+		} else if client.edges != nil {
+			client.poll.SetDialer(client.edges.DialContext)
+		} else if config.ServerMark.Enabled() {
+			client.poll.SetDialer(client.dialer.DialContext)
+		}
+	} else if config.EnableWS {
 		client.wsClient = transport.NewWSClient(config.WSConfig, cipher)
+		if client.proxy != nil {
+			client.wsClient.SetDialer(client.proxy.Dial)
+		} else if client.edges != nil {
+			client.wsClient.SetDialer(client.edges.Dial)
+		} else if config.ServerMark.Enabled() {
+			client.wsClient.SetDialer(client.dialer.Dial)
+		}
 	}
 
 	return client, nil
 }
 
-func (c *Client) Start() error {
-	ln, err := net.Listen("tcp", c.config.ListenAddr)
-	if err != nil {
-		return fmt.Errorf("failed to listen: %w", err)
-	}
-	c.ln = ln
-
-	if c.config.EnableWS {
-		log.Printf("[Client] 🌐 WebSocket 模式启动成功，监听地址: %s", c.config.ListenAddr)
+func (c *Client) Start(ctx context.Context) error {
+	c.started = time.Now()
+	stop := context.AfterFunc(ctx, func() { c.Stop() })
+	defer stop()
+
+	if c.config.EnablePoll {
+		log.Printf("[Client] 🔁 HTTP 长轮询模式")
+	} else if c.config.EnableWS {
+		log.Printf("[Client] 🌐 WebSocket 模式")
+	} else if c.config.RawTLS {
+		log.Printf("[Client] 🔓 原始 TLS 模式 (无自定义分帧，Server 端可以是 socat/openssl)")
+	} else if c.tls != nil {
+		log.Printf("[Client] 🔒 TCP 模式 (TLS)")
 	} else {
-		log.Printf("[Client] 🚀 TCP 模式启动成功，监听地址: %s", c.config.ListenAddr)
+		log.Printf("[Client] 🚀 TCP 模式")
+	}
+	log.Printf("[Client] 🔗 Server 地址: %s", strings.Join(c.paths.order(), ", "))
+	if c.config.Clock.NTPServer != "" {
+		go clock.Check("Client", c.config.Clock)
+	}
+	if c.discover != "" {
+		log.Printf("[Client] 🔎 Server 列表来自 DNS: %s (每 %v 刷新)", c.discover, discoverInterval)
+		go c.refreshDiscovery(c.discover)
+	}
+	if c.config.Batch.Delay > 0 {
+		log.Printf("[Client] 📨 小包合并: 等待 %v，缓冲 %d 字节", c.config.Batch.Delay, c.config.Batch.Size)
+	}
+	if c.config.RelayEngine == netutil.RelayPooled {
+		log.Printf("[Client] ♻️ 转发引擎: pooled (空闲连接不占用读缓冲)")
+	}
+	if c.config.ServerMark.Enabled() {
+		log.Printf("[Client] 🏷️ Server 连接标记: DSCP %d，fwmark %d", c.config.ServerMark.DSCP, c.config.ServerMark.Mark)
+	}
+	if c.proxy != nil {
+		log.Printf("[Client] 🧱 通过上游代理连接 Server: %s", c.proxy)
+		if c.config.EdgeRotation {
+			log.Printf("[Client] ⚠️ 已配置上游代理，边缘节点轮换不生效 (由代理解析域名)")
+		}
+	}
+	if len(c.router.routes) > 0 || c.router.fallback != RouteTunnel {
+		log.Printf("[Client] 🛣️ 分流规则 %d 条，默认路由: %s", len(c.router.routes), c.router.fallback)
+	}
+	c.paths.start()
+	if c.config.FrameDebug && (!c.config.EnableWS || c.config.EnablePoll) {
+		log.Printf("[Client] 🩺 帧校验调试模式已启用 (两端需同时启用)")
 	}
-	log.Printf("[Client] 🔗 Server 地址: %s", c.config.ServerAddr)
 	if c.config.TargetAddr != "" {
 		log.Printf("[Client] 🎯 默认目标: %s", c.config.TargetAddr)
 	}
 
-	for {
-		conn, err := ln.Accept()
+	if c.config.MaxConnections > 0 {
+		log.Printf("[Client] 🚦 每个监听的最大并发连接数: %d", c.config.MaxConnections)
+	}
+
+	if c.config.HealthListen != "" {
+		h, err := health.Start(c.config.HealthListen, "Client", c.Liveness, c.Readiness)
 		if err != nil {
-			if strings.Contains(err.Error(), "use of closed network connection") {
-				return nil
-			}
-			log.Printf("[Client] ⚠️ Accept 错误: %v", err)
-			continue
+			return err
+		}
+		c.health = h
+	}
+
+	if c.config.ListenAddr != "" {
+		if err := c.AddForward(c.config.Name, c.config.ListenAddr, c.config.TargetAddr); err != nil {
+			return err
+		}
+		log.Printf("[Client] ✅ 启动成功，监听地址: %s", c.config.ListenAddr)
+	}
+	for _, f := range c.config.Forwards {
+		if err := c.AddForward(f.Name, f.Listen, f.Target); err != nil {
+			c.Stop()
+			return err
+		}
+	}
+
+	if c.config.ControlSocket != "" {
+		ctl, err := control.Listen(c.config.ControlSocket, c.handleControl)
+		if err != nil {
+			c.Stop()
+			return err
 		}
+		c.control = ctl
+	}
+	close(c.ready)
 
-		go c.handleConnection(conn)
+	if c.config.ServerLink {
+		go c.maintainControl()
+	}
+	if c.config.Sidecar {
+		go c.watchUpstream()
 	}
+
+	<-c.ctx.Done()
+	return nil
 }
 
 func (c *Client) Stop() error {
-	if c.ln != nil {
-		return c.ln.Close()
+	c.cancel()
+	c.paths.stop()
+	if c.control != nil {
+		c.control.Close()
+	}
+	if c.health != nil {
+		c.health.Close()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for listen, f := range c.forwards {
+		f.ln.Close()
+		delete(c.forwards, listen)
 	}
 	return nil
 }
 
-func (c *Client) handleConnection(ownerConn net.Conn) {
-	defer ownerConn.Close()
+func (c *Client) handleConnection(ctx context.Context, conn net.Conn, f *forward) {
+	defer crash.Recover("client.conn")
+	defer conn.Close()
+	ctx, cancel := netutil.CloseOnDone(ctx, conn)
+	defer cancel()
+	ownerConn := &countingConn{Conn: conn}
+	defer func() {
+		f.bytes.Add(ownerConn.total())
+	}()
 	ownerAddr := ownerConn.RemoteAddr().String()
-	log.Printf("[Client] 📥 新连接来自: %s", ownerAddr)
+	logf(ctx, "📥 新连接来自: %s", ownerAddr)
+
+	if c.config.RawTLS {
+		c.handleRawTLS(ctx, ownerConn, ownerAddr)
+		return
+	}
 
 	var targetAddr string
 	var initialData []byte
 
 	if c.config.EnableHTTPS {
-		target, data, err := c.handleHTTPSConnect(ownerConn)
+		target, data, err := c.handleHTTPSConnect(ctx, ownerConn)
 		if err != nil {
-			log.Printf("[Client] ❌ HTTPS CONNECT 处理失败: %v", err)
+			logf(ctx, "❌ HTTPS CONNECT 处理失败: %v", err)
 			return
 		}
 		targetAddr = target
 		initialData = data
+	} else if c.config.EnableSOCKS5 {
+		cmd, target, err := c.handleSOCKS5(ctx, ownerConn)
+		if err != nil {
+			logf(ctx, "❌ SOCKS5 握手失败: %v", err)
+			return
+		}
+		if cmd == socks5.CmdUDPAssociate {
+			c.handleUDPAssociate(ctx, ownerConn, ownerAddr)
+			return
+		}
+		targetAddr = target
 	} else {
-		if c.config.TargetAddr == "" {
+		if f.target == "" {
 			targetAddr = "USE_DEFAULT"
 		} else {
-			targetAddr = c.config.TargetAddr
+			targetAddr = f.target
 		}
 	}
 
-	if c.config.EnableWS {
-		c.handleWSConnection(ownerConn, ownerAddr, targetAddr, initialData)
-	} else {
-		c.handleTCPConnection(ownerConn, ownerAddr, targetAddr, initialData)
+	targetAddr, err := c.rewriteTarget(targetAddr)
+	if err != nil {
+		logf(ctx, "❌ %v", err)
+		return
+	}
+
+	switch c.router.match(targetAddr) {
+	case RouteDirect:
+		c.handleDirect(ctx, ownerConn, ownerAddr, targetAddr, initialData, false)
+	case RouteProxy:
+		if c.bypass == nil {
+			logf(ctx, "❌ 路由规则要求经旁路代理，但未配置 bypass_proxy: %s", targetAddr)
+			return
+		}
+		c.handleDirect(ctx, ownerConn, ownerAddr, targetAddr, initialData, true)
+	case RouteReject:
+		logf(ctx, "🚫 路由规则拒绝: %s -> %s", ownerAddr, targetAddr)
+	default:
+		c.handleSession(ctx, ownerConn, ownerAddr, targetAddr, initialData)
+	}
+}
+
+type session interface {
+	ReadEncrypted() ([]byte, error)
+	WriteEncrypted(data []byte) error
+	SetReadDeadline(t time.Time) error
+	SetWriteTimeout(timeout time.Duration)
+	Close() error
+}
+
+func (c *Client) mode() string {
+	switch {
+	case c.config.EnablePoll:
+		return "长轮询"
+	case c.config.EnableWS:
+		return "WebSocket"
+	default:
+		return "TCP"
 	}
 }
 
-func (c *Client) handleWSConnection(ownerConn net.Conn, ownerAddr, targetAddr string, initialData []byte) {
-	wsConn, err := c.wsClient.Connect(c.config.ServerAddr)
+func (c *Client) openSession(ctx context.Context, targetAddr string) (session, string, error) {
+	var sess session
+	serverAddr, err := c.paths.connect(func(addr string) error {
+		if c.wsClient != nil {
+			wsConn, err := c.wsClient.Connect(ctx, addr)
+			if err != nil {
+				return err
+			}
+			sess = wsConn
+			return nil
+		}
+
+		serverConn, err := c.dialServer(ctx, addr)
+		if err != nil {
+			return err
+		}
+		cryptoConn := crypto.NewCryptoConn(serverConn, c.cipher)
+		cryptoConn.SetDebug(c.config.FrameDebug)
+		sess = cryptoConn
+		return nil
+	})
 	if err != nil {
-		log.Printf("[Client] ❌ 连接 WebSocket Server 失败: %v", err)
-		return
+		c.upstream.record(err)
+		return nil, "", fmt.Errorf("连接 %s Server 失败: %w", c.mode(), err)
 	}
-	defer wsConn.Close()
 
-	if err := wsConn.WriteEncrypted([]byte(targetAddr)); err != nil {
-		log.Printf("[Client] ❌ 发送目标地址失败: %v", err)
-		return
+	if err := sess.WriteEncrypted([]byte(targetAddr)); err != nil {
+		sess.Close()
+		c.upstream.record(err)
+		return nil, "", fmt.Errorf("发送目标地址失败: %w", err)
 	}
 
-	response, err := wsConn.ReadEncrypted()
+	response, err := sess.ReadEncrypted()
 	if err != nil {
-		log.Printf("[Client] ❌ 读取 Server 响应失败: %v", err)
-		return
+		sess.Close()
+		c.upstream.record(err)
+		return nil, "", fmt.Errorf("读取 Server 响应失败: %w", err)
 	}
+	c.upstream.record(nil)
 
 	if !strings.HasPrefix(string(response), "OK") {
-		log.Printf("[Client] ❌ Server 返回错误: %s", string(response))
+		sess.Close()
+		return nil, "", fmt.Errorf("Server 返回错误: %s", string(response))
+	}
+
+	return sess, serverAddr, nil
+}
+
+func (c *Client) handleSession(ctx context.Context, ownerConn *countingConn, ownerAddr, targetAddr string, initialData []byte) {
+	sess, serverAddr, err := c.openSession(ctx, targetAddr)
+	if err != nil {
+		logf(ctx, "❌ %v", err)
 		return
 	}
+	defer sess.Close()
+	defer c.paths.observe(serverAddr, time.Now(), ownerConn)
 
-	log.Printf("[Client] ✅ WebSocket 隧道建立成功: %s -> %s", ownerAddr, targetAddr)
+	logf(ctx, "✅ %s 隧道建立成功: %s -> %s", c.mode(), ownerAddr, targetAddr)
 
 	if len(initialData) > 0 {
-		if err := wsConn.WriteEncrypted(initialData); err != nil {
-			log.Printf("[Client] ❌ 发送初始数据失败: %v", err)
+		if err := sess.WriteEncrypted(initialData); err != nil {
+			logf(ctx, "❌ 发送初始数据失败: %v", err)
 			return
 		}
 	}
 
+	timeouts := c.timeouts()
+	sess.SetWriteTimeout(timeouts.Write)
+	state := &relayState{idle: timeouts.Idle(sess, ownerConn)}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
+	closeBoth := func() {
+		ownerConn.Close()
+		sess.Close()
+	}
+
 	go func() {
 		defer wg.Done()
-		buf := make([]byte, 32*1024)
-		for {
-			n, err := ownerConn.Read(buf)
-			if err != nil {
-				if err != io.EOF {
-					log.Printf("[Client] 读取 Owner 数据错误: %v", err)
-				}
-				return
-			}
-			if err := wsConn.WriteEncrypted(buf[:n]); err != nil {
-				log.Printf("[Client] 写入 WebSocket 数据错误: %v", err)
-				return
-			}
-		}
+		defer closeBoth()
+		defer crash.Recover("client.forward")
+		c.forwardToServer(ctx, ownerConn, sess, state)
 	}()
 
 	go func() {
 		defer wg.Done()
-		for {
-			data, err := wsConn.ReadEncrypted()
-			if err != nil {
-				if err != io.EOF {
-					log.Printf("[Client] 读取 WebSocket 数据错误: %v", err)
-				}
-				return
-			}
-			if _, err := ownerConn.Write(data); err != nil {
-				log.Printf("[Client] 写入 Owner 数据错误: %v", err)
-				return
-			}
-		}
+		defer closeBoth()
+		defer crash.Recover("client.forward")
+		c.forwardFromServer(ctx, sess, ownerConn, state)
 	}()
 
 	wg.Wait()
-	log.Printf("[Client] 🔌 WebSocket 连接关闭: %s", ownerAddr)
+	logf(ctx, "🔌 %s 连接关闭 (%s): %s", c.mode(), state.closeReason(), ownerAddr)
 }
 
-func (c *Client) handleTCPConnection(ownerConn net.Conn, ownerAddr, targetAddr string, initialData []byte) {
-	serverConn, err := net.DialTimeout("tcp", c.config.ServerAddr, 10*time.Second)
-	if err != nil {
-		log.Printf("[Client] ❌ 连接 Server 失败: %v", err)
-		return
-	}
-	defer serverConn.Close()
+type relayState struct {
+	idle *netutil.Idle
 
-	cryptoConn := crypto.NewCryptoConn(serverConn, c.cipher)
+	once   sync.Once
+	reason string
+}
 
-	if err := cryptoConn.WriteEncrypted([]byte(targetAddr)); err != nil {
-		log.Printf("[Client] ❌ 发送目标地址失败: %v", err)
-		return
-	}
+func (r *relayState) end(reason string) {
+	r.once.Do(func() {
+		r.reason = reason
+	})
+}
 
-	response, err := cryptoConn.ReadEncrypted()
-	if err != nil {
-		log.Printf("[Client] ❌ 读取 Server 响应失败: %v", err)
-		return
-	}
+func (r *relayState) closeReason() string {
+	r.end("unknown")
+	return r.reason
+}
 
-	if !strings.HasPrefix(string(response), "OK") {
-		log.Printf("[Client] ❌ Server 返回错误: %s", string(response))
-		return
+func readCloseReason(err error, eof string) string {
+	switch {
+	case errors.Is(err, crypto.ErrFrameDesync):
+		return "frame_desync"
+	case errors.Is(err, crypto.ErrDecrypt):
+		return "decrypt_error"
+	case errors.Is(err, transport.ErrPongTimeout):
+		return "pong_timeout"
+	case netutil.IsTimeout(err):
+		return "idle_timeout"
+	case errors.Is(err, net.ErrClosed):
+		return "closed"
 	}
+	return eof
+}
 
-	log.Printf("[Client] ✅ TCP 隧道建立成功: %s -> %s", ownerAddr, targetAddr)
-
-	if len(initialData) > 0 {
-		if err := cryptoConn.WriteEncrypted(initialData); err != nil {
-			log.Printf("[Client] ❌ 发送初始数据失败: %v", err)
-			return
-		}
+func (c *Client) dialServer(ctx context.Context, addr string) (net.Conn, error) {
+	if c.poll != nil {
+		return c.poll.Dial(ctx, addr)
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	var conn net.Conn
+	var err error
+	if c.proxy != nil {
+		conn, err = c.proxy.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = c.dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil || c.tls == nil {
+		return conn, err
+	}
 
-	go func() {
-		defer wg.Done()
-		c.forwardToServer(ownerConn, cryptoConn)
-	}()
+	tlsConfig := c.tls
+	if tlsConfig.ServerName == "" {
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = host
+	}
 
-	go func() {
-		defer wg.Done()
-		c.forwardFromServer(cryptoConn, ownerConn)
-	}()
+	ctx, cancel := context.WithTimeout(ctx, c.config.DialTimeout)
+	defer cancel()
 
-	wg.Wait()
-	log.Printf("[Client] 🔌 TCP 连接关闭: %s", ownerAddr)
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("tls handshake failed: %w", err)
+	}
+	return tlsConn, nil
 }
 
-func (c *Client) handleHTTPSConnect(conn net.Conn) (string, []byte, error) {
+func (c *Client) handleHTTPSConnect(ctx context.Context, conn net.Conn) (string, []byte, error) {
 	reader := bufio.NewReader(conn)
 
 	req, err := http.ReadRequest(reader)
@@ -262,63 +667,80 @@ func (c *Client) handleHTTPSConnect(conn net.Conn) (string, []byte, error) {
 	var initialData []byte
 
 	if req.Method == "CONNECT" {
-		targetAddr = req.Host
-		if !strings.Contains(targetAddr, ":") {
-			targetAddr += ":443"
-		}
+		targetAddr = netutil.WithDefaultPort(req.Host, "443")
 
 		response := "HTTP/1.1 200 Connection Established\r\n\r\n"
 		if _, err := conn.Write([]byte(response)); err != nil {
 			return "", nil, fmt.Errorf("failed to send CONNECT response: %w", err)
 		}
 
-		log.Printf("[Client] 🔒 HTTPS CONNECT: %s", targetAddr)
+		logf(ctx, "🔒 HTTPS CONNECT: %s", targetAddr)
 	} else {
-		targetAddr = req.Host
-		if !strings.Contains(targetAddr, ":") {
-			targetAddr += ":80"
-		}
+		targetAddr = netutil.WithDefaultPort(req.Host, "80")
 
 		var buf bytes.Buffer
 		req.Write(&buf)
 		initialData = buf.Bytes()
 
-		log.Printf("[Client] 🌐 HTTP Request: %s %s", req.Method, targetAddr)
+		logf(ctx, "🌐 HTTP Request: %s %s", req.Method, targetAddr)
 	}
 
 	return targetAddr, initialData, nil
 }
 
-func (c *Client) forwardToServer(src net.Conn, dst *crypto.CryptoConn) {
-	buf := make([]byte, 32*1024)
+func (c *Client) timeouts() netutil.Timeouts {
+	return netutil.Timeouts{Read: c.config.ReadTimeout, Write: c.config.WriteTimeout}
+}
+
+func (c *Client) forwardToServer(ctx context.Context, src net.Conn, dst session, state *relayState) {
+	out := crypto.NewBatcher(dst, c.config.Batch)
+	defer out.Close()
+
+	in := netutil.NewRelayReader(src, c.config.RelayEngine)
+	defer in.Close()
+
 	for {
-		n, err := src.Read(buf)
+		data, err := in.Next()
 		if err != nil {
-			if err != io.EOF {
-				log.Printf("[Client] 读取 Owner 数据错误: %v", err)
+			state.end(readCloseReason(err, "owner_closed"))
+			if netutil.IsTimeout(err) {
+				logf(ctx, "⏱️ 会话空闲超过 %v，关闭连接", c.config.ReadTimeout)
+			} else if !netutil.IsClosed(err) {
+				logf(ctx, "读取 Owner 数据错误: %v", err)
 			}
 			return
 		}
+		state.idle.Touch()
 
-		if err := dst.WriteEncrypted(buf[:n]); err != nil {
-			log.Printf("[Client] 写入 Server 数据错误: %v", err)
+		if err := out.WriteEncrypted(data); err != nil {
+			state.end("server_write_error")
+			logf(ctx, "写入 Server 数据错误: %v", err)
 			return
 		}
 	}
 }
 
-func (c *Client) forwardFromServer(src *crypto.CryptoConn, dst net.Conn) {
+func (c *Client) forwardFromServer(ctx context.Context, src session, dst net.Conn, state *relayState) {
 	for {
 		data, err := src.ReadEncrypted()
 		if err != nil {
-			if err != io.EOF {
-				log.Printf("[Client] 读取 Server 数据错误: %v", err)
+			state.end(readCloseReason(err, "server_closed"))
+			if errors.Is(err, crypto.ErrFrameDesync) {
+				logf(ctx, "❌ 帧失步，已关闭连接: %v", err)
+				dst.Close()
+			} else if netutil.IsTimeout(err) {
+				logf(ctx, "⏱️ 会话空闲超过 %v，关闭连接", c.config.ReadTimeout)
+			} else if !transport.IsNormalClose(err) {
+				logf(ctx, "读取 Server 数据错误: %v", err)
 			}
 			return
 		}
+		state.idle.Touch()
 
+		c.timeouts().ArmWrite(dst)
 		if _, err := dst.Write(data); err != nil {
-			log.Printf("[Client] 写入 Owner 数据错误: %v", err)
+			state.end("owner_write_error")
+			logf(ctx, "写入 Owner 数据错误: %v", err)
 			return
 		}
 	}
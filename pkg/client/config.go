@@ -0,0 +1,116 @@
+package client
+
+import (
+	"fmt"
+
+	"tunnel/pkg/clock"
+	"tunnel/pkg/config"
+	"tunnel/pkg/crypto"
+	"tunnel/pkg/keyring"
+	"tunnel/pkg/netutil"
+	"tunnel/pkg/transport"
+)
+
+func FromConfig(c config.ClientConfig) (Config, error) {
+	wsConfig := transport.DefaultWSConfig()
+	wsConfig.Path = c.WSPath
+	wsConfig.EnableTLS = c.WSTLS
+	wsConfig.SkipVerify = c.WSSkipVerify
+	wsConfig.Affinity = c.WSAffinity
+	wsConfig.Origin = c.WSOrigin
+	wsConfig.PingInterval = c.WSPingInterval.Or(wsConfig.PingInterval)
+	if c.WSPongMisses != 0 {
+		wsConfig.PongMisses = c.WSPongMisses
+	}
+	if c.WSReadBuffer > 0 {
+		wsConfig.ReadBufferSize = c.WSReadBuffer
+	}
+	if c.WSWriteBuffer > 0 {
+		wsConfig.WriteBufferSize = c.WSWriteBuffer
+	}
+
+	batchConfig := crypto.BatchConfig{Size: c.BatchSize, Delay: c.BatchDelay.Duration}
+	if batchConfig.Size <= 0 {
+		batchConfig.Size = crypto.DefaultBatchSize
+	}
+
+	routeRules := make([]RouteRule, 0, len(c.Routes))
+	for _, r := range c.Routes {
+		routeRules = append(routeRules, RouteRule{Match: r.Match, Action: r.Action, Priority: r.Priority})
+	}
+
+	forwards := make([]Forward, 0, len(c.Forwards))
+	for _, f := range c.Forwards {
+		forwards = append(forwards, Forward{Name: f.Name, Listen: f.Listen, Target: f.Target})
+	}
+
+	cfg := Config{
+		Name:                c.Name,
+		ListenAddr:          c.Listen,
+		ServerAddr:          c.Server,
+		ServerAddrs:         c.Servers,
+		TargetAddr:          c.Target,
+		Password:            c.Password,
+		KeyFile:             c.KeyFile,
+		EnableHTTPS:         c.EnableHTTPS,
+		EnableSOCKS5:        c.EnableSOCKS5,
+		EnableWS:            c.EnableWS,
+		WSConfig:            wsConfig,
+		EnablePoll:          c.EnablePoll,
+		ServerTLS:           c.ServerTLS,
+		ServerTLSSNI:        c.ServerTLSSNI,
+		ServerTLSSkipVerify: c.ServerTLSSkipVerify,
+		RawTLS:              c.RawTLS,
+		RawTLSCert:          c.RawTLSCert,
+		RawTLSKey:           c.RawTLSKey,
+		FIPS:                c.FIPS,
+		FrameDebug:          c.FrameDebug,
+		ReadTimeout:         c.ReadTimeout.Duration,
+		WriteTimeout:        c.WriteTimeout.Or(netutil.DefaultWriteTimeout),
+		DialTimeout:         c.DialTimeout.Duration,
+		MaxConnections:      c.MaxConnections,
+		Forwards:            forwards,
+		ControlSocket:       c.ControlSocket,
+		ServerToken:         c.ServerToken,
+		ServerLink:          c.ServerLink,
+		DiscoverKey:         c.DiscoverKey,
+		EdgeRotation:        c.WSEdgeRotate,
+		ServerMark:          netutil.SocketMark{DSCP: c.DSCP, Mark: c.FWMark},
+		Batch:               batchConfig,
+		RelayEngine:         c.RelayEngine,
+		HealthListen:        c.Health,
+		Sidecar:             c.Sidecar,
+		UpstreamProxy:       c.UpstreamProxy,
+		DNSOverrides:        c.DNSOverrides,
+		Routes:              routeRules,
+		DefaultRoute:        c.DefaultRoute,
+		BypassProxy:         c.BypassProxy,
+		Clock:               clock.Config{NTPServer: c.Clock.NTPServer, Tolerance: c.Clock.Tolerance.Duration},
+	}
+	if c.Keyring.Name != "" {
+		if err := cfg.LoadKeyring(c.Keyring.Name, c.Keyring.Kind); err != nil {
+			return Config{}, err
+		}
+	}
+	return cfg, nil
+}
+
+func (c *Config) LoadKeyring(name, kind string) error {
+	if !keyring.ValidKind(kind) {
+		return fmt.Errorf("invalid keyring kind %q (password, key)", kind)
+	}
+	secret, err := keyring.Get(name)
+	if err != nil {
+		return err
+	}
+	if kind == keyring.KindKey {
+		key, err := crypto.ParseKey(secret)
+		if err != nil {
+			return fmt.Errorf("keyring %q: %w", name, err)
+		}
+		c.Key = key
+		return nil
+	}
+	c.Password = secret
+	return nil
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	discoverScheme   = "dns://"
+	discoverService  = "tunnel"
+	discoverVersion  = "v=tunnel1"
+	discoverInterval = 5 * time.Minute
+)
+
+func discoverName(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, discoverScheme) {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, discoverScheme), "."), true
+}
+
+func discoverServers(name, key string) ([]string, error) {
+	if key == "" {
+		if _, records, err := net.LookupSRV(discoverService, "tcp", name); err == nil && len(records) > 0 {
+			addrs := make([]string, 0, len(records))
+			for _, r := range records {
+				addrs = append(addrs, net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))))
+			}
+			return addrs, nil
+		}
+	}
+
+	records, err := net.LookupTXT(name)
+	if err != nil {
+		return nil, fmt.Errorf("discover %s failed: %w", name, err)
+	}
+
+	var lastErr error
+	for _, record := range records {
+		addrs, err := parseDiscoveryTXT(record, key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if addrs != nil {
+			return addrs, nil
+		}
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("discover %s failed: %w", name, lastErr)
+	}
+	return nil, fmt.Errorf("discover %s failed: no usable SRV or TXT records", name)
+}
+
+func parseDiscoveryTXT(record, key string) ([]string, error) {
+	fields := strings.Fields(record)
+	if len(fields) == 0 || fields[0] != discoverVersion {
+		return nil, nil
+	}
+
+	var servers, sig string
+	for _, field := range fields[1:] {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "servers":
+			servers = value
+		case "sig":
+			sig = value
+		}
+	}
+	if servers == "" {
+		return nil, fmt.Errorf("txt record has no servers")
+	}
+
+	if key != "" {
+		want := signDiscovery(servers, key)
+		got, err := base64.StdEncoding.DecodeString(sig)
+		if err != nil || !hmac.Equal(got, want) {
+			return nil, fmt.Errorf("txt record signature mismatch")
+		}
+	}
+
+	var addrs []string
+	for _, addr := range strings.Split(servers, ",") {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid server in txt record '%s': %w", addr, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+func signDiscovery(servers, key string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(servers))
+	return mac.Sum(nil)
+}
+
+func (c *Client) refreshDiscovery(name string) {
+	ticker := time.NewTicker(discoverInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.rediscover:
+		}
+
+		addrs, err := discoverServers(name, c.config.DiscoverKey)
+		if err != nil {
+			log.Printf("[Client] ⚠️ 刷新 Server 列表失败，继续使用当前列表: %v", err)
+			continue
+		}
+		if c.paths.update(append(addrs, c.config.ServerAddrs...)) {
+			log.Printf("[Client] 🔎 Server 列表已更新 (%s): %s", name, strings.Join(addrs, ", "))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+const resolveLocal = "local"
+
+func (c *Client) rewriteTarget(target string) (string, error) {
+	if len(c.config.DNSOverrides) == 0 || target == "USE_DEFAULT" {
+		return target, nil
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || net.ParseIP(host) != nil {
+		return target, nil
+	}
+
+	override, ok := lookupOverride(c.config.DNSOverrides, host)
+	if !ok {
+		return target, nil
+	}
+
+	if override == resolveLocal {
+		addrs, err := net.LookupHost(host)
+		if err != nil {
+			return "", fmt.Errorf("local resolve %s failed: %w", host, err)
+		}
+		if len(addrs) == 0 {
+			return "", fmt.Errorf("local resolve %s returned no addresses", host)
+		}
+		override = addrs[0]
+	}
+
+	log.Printf("[Client] 🔀 DNS 覆盖: %s -> %s", host, override)
+	return net.JoinHostPort(override, port), nil
+}
+
+func lookupOverride(overrides map[string]string, host string) (string, bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if v, ok := overrides[host]; ok {
+		return v, true
+	}
+
+	for {
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return "", false
+		}
+		host = host[i+1:]
+		if v, ok := overrides["*."+host]; ok {
+			return v, true
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"tunnel/pkg/netutil"
+)
+
+const (
+	edgeResolveTTL   = time.Minute
+	edgeMaxAttempts  = 3
+	edgeCooldownBase = 30 * time.Second
+	edgeCooldownMax  = 10 * time.Minute
+)
+
+type edge struct {
+	ip       string
+	failures int
+	until    time.Time
+}
+
+type edgeSet struct {
+	edges    []*edge
+	next     int
+	resolved time.Time
+}
+
+type edgeDialer struct {
+	mu      sync.Mutex
+	hosts   map[string]*edgeSet
+	control func(network, address string, c syscall.RawConn) error
+	timeout time.Duration
+}
+
+func newEdgeDialer(mark netutil.SocketMark, timeout time.Duration) *edgeDialer {
+	return &edgeDialer{hosts: make(map[string]*edgeSet), control: mark.Control(), timeout: timeout}
+}
+
+func (d *edgeDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *edgeDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		dialer := net.Dialer{Control: d.control}
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	candidates, err := d.pick(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, ip := range candidates {
+		dialer := net.Dialer{Timeout: d.timeout, Control: d.control}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		d.report(host, ip, err)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+func (d *edgeDialer) pick(ctx context.Context, host string) ([]string, error) {
+	d.mu.Lock()
+	set := d.hosts[host]
+	stale := set == nil || time.Since(set.resolved) > edgeResolveTTL
+	d.mu.Unlock()
+
+	if stale {
+		ips, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil && set == nil {
+			return nil, fmt.Errorf("resolve %s failed: %w", host, err)
+		}
+		if err == nil && len(ips) == 0 && set == nil {
+			return nil, fmt.Errorf("resolve %s returned no addresses", host)
+		}
+		if err == nil && len(ips) > 0 {
+			set = d.refresh(host, ips)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	var ready, cooling []string
+	for i := range set.edges {
+		e := set.edges[(set.next+i)%len(set.edges)]
+		if now.Before(e.until) {
+			cooling = append(cooling, e.ip)
+		} else {
+			ready = append(ready, e.ip)
+		}
+	}
+	set.next = (set.next + 1) % len(set.edges)
+
+	candidates := append(ready, cooling...)
+	if len(candidates) > edgeMaxAttempts {
+		candidates = candidates[:edgeMaxAttempts]
+	}
+	return candidates, nil
+}
+
+func (d *edgeDialer) flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, set := range d.hosts {
+		set.resolved = time.Time{}
+	}
+}
+
+func (d *edgeDialer) refresh(host string, ips []string) *edgeSet {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	old := make(map[string]*edge)
+	if set := d.hosts[host]; set != nil {
+		for _, e := range set.edges {
+			old[e.ip] = e
+		}
+	}
+
+	set := &edgeSet{resolved: time.Now()}
+	for _, ip := range ips {
+		if e, ok := old[ip]; ok {
+			set.edges = append(set.edges, e)
+		} else {
+			set.edges = append(set.edges, &edge{ip: ip})
+		}
+	}
+	if prev := d.hosts[host]; prev != nil {
+		set.next = prev.next % len(set.edges)
+	} else {
+		log.Printf("[Client] 🌐 %s 解析到 %d 个边缘节点，按连接轮换", host, len(set.edges))
+	}
+	d.hosts[host] = set
+	return set
+}
+
+func (d *edgeDialer) report(host, ip string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	set := d.hosts[host]
+	if set == nil {
+		return
+	}
+	for _, e := range set.edges {
+		if e.ip != ip {
+			continue
+		}
+		if err == nil {
+			e.failures, e.until = 0, time.Time{}
+			return
+		}
+		e.failures++
+		cooldown := edgeCooldownBase << (e.failures - 1)
+		if cooldown > edgeCooldownMax || cooldown <= 0 {
+			cooldown = edgeCooldownMax
+		}
+		e.until = time.Now().Add(cooldown)
+		log.Printf("[Client] ⚠️ 边缘节点 %s (%s) 连接失败 %d 次，%v 内优先使用其他节点: %v", ip, host, e.failures, cooldown, err)
+		return
+	}
+}
+
+func (d *edgeDialer) Stats() []map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var out []map[string]interface{}
+	now := time.Now()
+	for host, set := range d.hosts {
+		for _, e := range set.edges {
+			out = append(out, map[string]interface{}{
+				"host":     host,
+				"ip":       e.ip,
+				"failures": e.failures,
+				"cooling":  now.Before(e.until),
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,271 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"tunnel/pkg/control"
+	"tunnel/pkg/netutil"
+)
+
+type Forward struct {
+	Name   string
+	Listen string
+	Target string
+}
+
+type forward struct {
+	name    string
+	listen  string
+	target  string
+	ln      *netutil.LimitListener
+	created time.Time
+	total   atomic.Int64
+	bytes   atomic.Int64
+
+	oneShot bool
+	used    atomic.Bool
+}
+
+type ForwardInfo struct {
+	Name     string `json:"name,omitempty"`
+	Listen   string `json:"listen"`
+	Target   string `json:"target"`
+	Created  string `json:"created"`
+	Active   int64  `json:"active_connections"`
+	Total    int64  `json:"total_connections"`
+	Rejected int64  `json:"rejected_connections"`
+	Bytes    int64  `json:"bytes"`
+	OneShot  bool   `json:"one_shot,omitempty"`
+}
+
+func (f *forward) tag() string {
+	if f.name == "" {
+		return "Client"
+	}
+	return "Client:" + f.name
+}
+
+type forwardKey struct{}
+
+func logf(ctx context.Context, format string, args ...interface{}) {
+	tag := "Client"
+	if f, ok := ctx.Value(forwardKey{}).(*forward); ok {
+		tag = f.tag()
+	}
+	log.Printf("[%s] "+format, append([]interface{}{tag}, args...)...)
+}
+
+func (c *Client) AddForward(name, listen, target string) error {
+	_, err := c.addForward(name, listen, target, false)
+	return err
+}
+
+func (c *Client) addForward(name, listen, target string, oneShot bool) (*forward, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := netutil.NormalizeAddr(listen); err != nil {
+		return nil, fmt.Errorf("invalid listen address: %w", err)
+	}
+	if target != "" {
+		normalized, err := netutil.NormalizeAddr(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target address: %w", err)
+		}
+		target = normalized
+	}
+
+	if _, exists := c.forwards[listen]; exists {
+		return nil, fmt.Errorf("listener %s already exists", listen)
+	}
+	if name != "" {
+		for _, f := range c.forwards {
+			if f.name == name {
+				return nil, fmt.Errorf("listener name %q already used by %s", name, f.listen)
+			}
+		}
+	}
+
+	ln, err := netutil.Listen(listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	f := &forward{
+		name:    name,
+		listen:  listen,
+		target:  target,
+		created: time.Now(),
+		oneShot: oneShot,
+	}
+	f.ln = netutil.NewLimitListener(ln, c.config.MaxConnections, f.tag())
+	c.forwards[listen] = f
+
+	go c.serve(f)
+
+	if target != "" {
+		log.Printf("[%s] ➕ 新增监听: %s -> %s", f.tag(), listen, target)
+	} else {
+		log.Printf("[%s] ➕ 新增监听: %s", f.tag(), listen)
+	}
+	return f, nil
+}
+
+func (c *Client) CheckPorts() error {
+	addrs := []string{c.config.ListenAddr, c.config.HealthListen}
+	for _, f := range c.config.Forwards {
+		addrs = append(addrs, f.Listen)
+	}
+	return netutil.CheckListen(addrs...)
+}
+
+func (c *Client) RemoveForward(listen string) error {
+	c.mu.Lock()
+	f, ok := c.forwards[listen]
+	delete(c.forwards, listen)
+	c.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("listener %s not found", listen)
+	}
+	f.used.Store(true)
+
+	log.Printf("[%s] ➖ 移除监听: %s", f.tag(), listen)
+	return f.ln.Close()
+}
+
+func (c *Client) Forwards() []ForwardInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	infos := make([]ForwardInfo, 0, len(c.forwards))
+	for _, f := range c.forwards {
+		infos = append(infos, ForwardInfo{
+			Name:     f.name,
+			Listen:   f.listen,
+			Target:   f.target,
+			Created:  f.created.Format(time.RFC3339),
+			Active:   f.ln.Open(),
+			Total:    f.total.Load(),
+			Rejected: f.ln.Rejected(),
+			Bytes:    f.bytes.Load(),
+			OneShot:  f.oneShot,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Listen < infos[j].Listen
+	})
+	return infos
+}
+
+func (c *Client) Stats() map[string]interface{} {
+	forwards := c.Forwards()
+
+	var active, total, bytes int64
+	perForward := make(map[string]interface{}, len(forwards))
+	for _, f := range forwards {
+		active += f.Active
+		total += f.Total
+		bytes += f.Bytes
+
+		key := f.Name
+		if key == "" {
+			key = f.Listen
+		}
+		perForward[key] = map[string]interface{}{
+			"active_connections":   f.Active,
+			"total_connections":    f.Total,
+			"rejected_connections": f.Rejected,
+			"bytes":                f.Bytes,
+		}
+	}
+
+	stats := map[string]interface{}{
+		"listeners":          len(forwards),
+		"active_connections": active,
+		"total_connections":  total,
+		"bytes":              bytes,
+		"forwards":           perForward,
+		"uptime_seconds":     int64(time.Since(c.started).Seconds()),
+	}
+	if c.edges != nil {
+		stats["edges"] = c.edges.Stats()
+	}
+	return stats
+}
+
+func (c *Client) serve(f *forward) {
+	var backoff netutil.Backoff
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			if netutil.IsClosed(err) {
+				return
+			}
+			netutil.HandleAcceptError(f.tag(), err, &backoff)
+			continue
+		}
+		backoff.Reset()
+
+		if f.oneShot {
+			if !f.used.CompareAndSwap(false, true) {
+				conn.Close()
+				return
+			}
+			c.retireForward(f)
+			log.Printf("[%s] 🔂 一次性监听已接受连接 %s，监听已关闭: %s", f.tag(), conn.RemoteAddr(), f.listen)
+		}
+
+		f.total.Add(1)
+		go c.handleConnection(context.WithValue(c.ctx, forwardKey{}, f), conn, f)
+	}
+}
+
+func (c *Client) handleControl(req control.Request) control.Response {
+	switch req.Command {
+	case control.CommandList:
+		return control.Success(c.Forwards())
+	case control.CommandStats:
+		return control.Success(c.Stats())
+	case control.CommandVersion:
+		return control.Success(c.Version())
+	case control.CommandConfig:
+		return control.Success(c.EffectiveConfig())
+	case control.CommandAdd:
+		if req.Listen == "" {
+			return control.Failure(fmt.Errorf("listen address is required"))
+		}
+		if err := c.AddForward(req.Name, req.Listen, req.Target); err != nil {
+			return control.Failure(err)
+		}
+		return control.Success(nil)
+	case control.CommandOnce:
+		if req.Listen == "" {
+			return control.Failure(fmt.Errorf("listen address is required"))
+		}
+		wait := defaultOneShotWait
+		if req.Wait != "" {
+			d, err := time.ParseDuration(req.Wait)
+			if err != nil {
+				return control.Failure(fmt.Errorf("invalid wait: %w", err))
+			}
+			wait = d
+		}
+		if err := c.AddOneShot(req.Name, req.Listen, req.Target, wait); err != nil {
+			return control.Failure(err)
+		}
+		return control.Success(nil)
+	case control.CommandRemove:
+		if err := c.RemoveForward(req.Listen); err != nil {
+			return control.Failure(err)
+		}
+		return control.Success(nil)
+	default:
+		return control.Failure(fmt.Errorf("unknown command: %s", req.Command))
+	}
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"log"
+	"time"
+)
+
+const defaultOneShotWait = 5 * time.Minute
+
+func (c *Client) AddOneShot(name, listen, target string, wait time.Duration) error {
+	f, err := c.addForward(name, listen, target, true)
+	if err != nil {
+		return err
+	}
+
+	if wait > 0 {
+		log.Printf("[%s] 🔂 一次性监听: 接受一个连接后关闭，%v 内无连接自动关闭: %s", f.tag(), wait, listen)
+		time.AfterFunc(wait, func() {
+			if c.ctx.Err() == nil && f.used.CompareAndSwap(false, true) {
+				c.retireForward(f)
+				log.Printf("[%s] ⌛ 一次性监听 %v 内无连接，已关闭: %s", f.tag(), wait, listen)
+			}
+		})
+	} else {
+		log.Printf("[%s] 🔂 一次性监听: 接受一个连接后关闭: %s", f.tag(), listen)
+	}
+	return nil
+}
+
+func (c *Client) retireForward(f *forward) {
+	c.mu.Lock()
+	if c.forwards[f.listen] == f {
+		delete(c.forwards, f.listen)
+	}
+	c.mu.Unlock()
+	f.ln.Close()
+}
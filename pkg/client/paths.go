@@ -0,0 +1,293 @@
+package client
+
+import (
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tunnel/pkg/netutil"
+)
+
+const (
+	pathProbeInterval = 30 * time.Second
+	pathProbeTimeout  = 5 * time.Second
+	pathEWMAWeight    = 0.3
+	pathSwitchRatio   = 0.8
+	pathGoodputMin    = 256 * 1024
+	pathCostBytes     = 64 * 1024
+)
+
+type path struct {
+	addr    string
+	rtt     time.Duration
+	goodput float64
+	up      bool
+}
+
+func (p *path) cost() time.Duration {
+	if !p.up {
+		return time.Duration(1<<63 - 1)
+	}
+	cost := p.rtt
+	if p.goodput > 0 {
+		cost += time.Duration(float64(pathCostBytes) / p.goodput * float64(time.Second))
+	}
+	return cost
+}
+
+type pathSelector struct {
+	mu      sync.Mutex
+	paths   []*path
+	current int
+	dial    func(network, addr string) (net.Conn, error)
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newPathSelector(addrs []string, mark netutil.SocketMark) *pathSelector {
+	dialer := &net.Dialer{Timeout: pathProbeTimeout, Control: mark.Control()}
+	s := &pathSelector{
+		dial: dialer.Dial,
+		done: make(chan struct{}),
+	}
+	s.paths = buildPaths(addrs, nil)
+	return s
+}
+
+func buildPaths(addrs []string, existing []*path) []*path {
+	known := make(map[string]*path, len(existing))
+	for _, p := range existing {
+		known[p.addr] = p
+	}
+
+	var paths []*path
+	seen := make(map[string]bool)
+	for _, addr := range addrs {
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		if p, ok := known[addr]; ok {
+			paths = append(paths, p)
+		} else {
+			paths = append(paths, &path{addr: addr, up: true})
+		}
+	}
+	return paths
+}
+
+func (s *pathSelector) update(addrs []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths := buildPaths(addrs, s.paths)
+	if len(paths) == 0 {
+		return false
+	}
+	if len(paths) == len(s.paths) {
+		same := true
+		for i := range paths {
+			if paths[i] != s.paths[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return false
+		}
+	}
+
+	current := s.paths[s.current]
+	s.paths, s.current = paths, 0
+	for i, p := range paths {
+		if p == current {
+			s.current = i
+		}
+	}
+	s.reselect()
+	return true
+}
+
+func (s *pathSelector) start() {
+	if len(s.paths) < 2 {
+		return
+	}
+
+	log.Printf("[Client] 🧭 已配置 %d 个 Server，按 RTT/吞吐量自动选路 (每 %v 探测)", len(s.paths), pathProbeInterval)
+
+	go func() {
+		s.probeAll()
+
+		ticker := time.NewTicker(pathProbeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.probeAll()
+			}
+		}
+	}()
+}
+
+func (s *pathSelector) stop() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *pathSelector) probeAll() {
+	s.mu.Lock()
+	paths := s.paths
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range paths {
+		wg.Add(1)
+		go func(p *path) {
+			defer wg.Done()
+
+			start := time.Now()
+			conn, err := s.dial("tcp", p.addr)
+			rtt := time.Since(start)
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if err != nil {
+				p.up = false
+				return
+			}
+			conn.Close()
+
+			if p.rtt == 0 {
+				p.rtt = rtt
+			} else {
+				p.rtt = time.Duration(pathEWMAWeight*float64(rtt) + (1-pathEWMAWeight)*float64(p.rtt))
+			}
+			p.up = true
+		}(p)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	s.reselect()
+	s.mu.Unlock()
+}
+
+func (s *pathSelector) reselect() {
+	best := s.current
+	for i, p := range s.paths {
+		if p.cost() < s.paths[best].cost() {
+			best = i
+		}
+	}
+	if best == s.current {
+		return
+	}
+
+	current := s.paths[s.current]
+	candidate := s.paths[best]
+	if current.up && float64(candidate.cost()) > float64(current.cost())*pathSwitchRatio {
+		return
+	}
+
+	log.Printf("[Client] 🧭 切换 Server: %s -> %s (RTT %v, 吞吐 %.0f KB/s)",
+		current.addr, candidate.addr, candidate.rtt, candidate.goodput/1024)
+	s.current = best
+}
+
+func (s *pathSelector) order() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	addrs := make([]string, 0, len(s.paths))
+	addrs = append(addrs, s.paths[s.current].addr)
+	for i, p := range s.paths {
+		if i != s.current {
+			addrs = append(addrs, p.addr)
+		}
+	}
+	return addrs
+}
+
+func (s *pathSelector) connect(dial func(addr string) error) (string, error) {
+	var lastErr error
+	for _, addr := range s.order() {
+		if err := dial(addr); err != nil {
+			lastErr = err
+			s.fail(addr, err)
+			continue
+		}
+		return addr, nil
+	}
+	return "", lastErr
+}
+
+func (s *pathSelector) fail(addr string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.paths {
+		if p.addr == addr {
+			p.up = false
+		}
+	}
+	if len(s.paths) > 1 {
+		log.Printf("[Client] ⚠️ Server %s 不可用，尝试其他路径: %v", addr, err)
+		s.reselect()
+	}
+}
+
+func (s *pathSelector) observe(addr string, start time.Time, owner *countingConn) {
+	bytes := owner.total()
+	elapsed := time.Since(start)
+	if bytes < pathGoodputMin || elapsed <= 0 {
+		return
+	}
+	goodput := float64(bytes) / elapsed.Seconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.paths {
+		if p.addr != addr {
+			continue
+		}
+		if p.goodput == 0 {
+			p.goodput = goodput
+		} else {
+			p.goodput = pathEWMAWeight*goodput + (1-pathEWMAWeight)*p.goodput
+		}
+	}
+	s.reselect()
+}
+
+type countingConn struct {
+	net.Conn
+	read    atomic.Int64
+	written atomic.Int64
+}
+
+func (c *countingConn) Unwrap() net.Conn {
+	return c.Conn
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) total() int64 {
+	return c.read.Load() + c.written.Load()
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"tunnel/pkg/crash"
+)
+
+func (c *Client) handleRawTLS(ctx context.Context, ownerConn *countingConn, ownerAddr string) {
+	var serverConn net.Conn
+	serverAddr, err := c.paths.connect(func(addr string) error {
+		conn, err := c.dialServer(ctx, addr)
+		if err != nil {
+			return err
+		}
+		serverConn = conn
+		return nil
+	})
+	c.upstream.record(err)
+	if err != nil {
+		logf(ctx, "❌ %v", fmt.Errorf("连接原始 TLS Server 失败: %w", err))
+		return
+	}
+	defer serverConn.Close()
+	defer c.paths.observe(serverAddr, time.Now(), ownerConn)
+
+	logf(ctx, "✅ 原始 TLS 隧道建立成功: %s -> %s", ownerAddr, serverAddr)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	closeBoth := func() {
+		ownerConn.Close()
+		serverConn.Close()
+	}
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("client.raw")
+		io.Copy(serverConn, ownerConn)
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("client.raw")
+		io.Copy(ownerConn, serverConn)
+	}()
+
+	wg.Wait()
+	logf(ctx, "🔌 原始 TLS 连接关闭: %s", ownerAddr)
+}
@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"tunnel/pkg/clock"
+	"tunnel/pkg/control"
+	"tunnel/pkg/crash"
+	"tunnel/pkg/netutil"
+	"tunnel/pkg/version"
+)
+
+const (
+	controlTarget       = "CONTROL"
+	controlPingInterval = 30 * time.Second
+	controlCallTimeout  = 30 * time.Second
+)
+
+type controlLink struct {
+	stream *control.Stream
+	token  string
+	events func(control.Event)
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan control.Response
+	err     error
+	done    chan struct{}
+}
+
+func (c *Client) dialControl(ctx context.Context, events func(control.Event)) (*controlLink, error) {
+	sess, _, err := c.openSession(ctx, controlTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &controlLink{
+		stream:  control.NewStream(sess),
+		token:   c.config.ServerToken,
+		events:  events,
+		pending: make(map[uint64]chan control.Response),
+		done:    make(chan struct{}),
+	}
+	go l.readLoop()
+	return l, nil
+}
+
+func (l *controlLink) readLoop() {
+	defer crash.Recover("client.control")
+
+	for {
+		msg, err := l.stream.Receive()
+		if err != nil {
+			l.fail(err)
+			return
+		}
+
+		switch msg.Kind {
+		case control.KindResponse:
+			if msg.Response == nil {
+				continue
+			}
+			l.mu.Lock()
+			ch, ok := l.pending[msg.ID]
+			delete(l.pending, msg.ID)
+			l.mu.Unlock()
+			if ok {
+				ch <- *msg.Response
+			}
+		case control.KindEvent:
+			if msg.Event != nil && l.events != nil {
+				l.events(*msg.Event)
+			}
+		}
+	}
+}
+
+func (l *controlLink) call(req control.Request) (control.Response, error) {
+	if req.Token == "" {
+		req.Token = l.token
+	}
+
+	ch := make(chan control.Response, 1)
+	l.mu.Lock()
+	if l.err != nil {
+		l.mu.Unlock()
+		return control.Response{}, l.err
+	}
+	l.nextID++
+	id := l.nextID
+	l.pending[id] = ch
+	l.mu.Unlock()
+
+	if err := l.stream.Send(control.Message{ID: id, Kind: control.KindRequest, Request: &req}); err != nil {
+		l.fail(err)
+		return control.Response{}, fmt.Errorf("发送控制命令失败: %w", err)
+	}
+
+	timer := time.NewTimer(controlCallTimeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-l.done:
+		return control.Response{}, fmt.Errorf("控制通道已断开: %w", l.err)
+	case <-timer.C:
+		l.mu.Lock()
+		delete(l.pending, id)
+		l.mu.Unlock()
+		return control.Response{}, fmt.Errorf("控制命令超时")
+	}
+}
+
+func (l *controlLink) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	l.err = err
+	close(l.done)
+	l.stream.Close()
+}
+
+func (l *controlLink) Close() {
+	l.fail(net.ErrClosed)
+}
+
+func (c *Client) ServerControl(req control.Request) (control.Response, error) {
+	c.mu.Lock()
+	link := c.link
+	c.mu.Unlock()
+	if link != nil {
+		return link.call(req)
+	}
+
+	link, err := c.dialControl(c.ctx, nil)
+	if err != nil {
+		return control.Response{}, err
+	}
+	defer link.Close()
+	return link.call(req)
+}
+
+func (c *Client) maintainControl() {
+	defer crash.Recover("client.control")
+
+	var backoff netutil.Backoff
+	for {
+		link, err := c.dialControl(c.ctx, c.handleServerEvent)
+		if err != nil {
+			delay := backoff.Next()
+			log.Printf("[Client] ⚠️ 控制通道连接失败，%v 后重试: %v", delay, err)
+			select {
+			case <-time.After(delay):
+				continue
+			case <-c.ctx.Done():
+				return
+			}
+		}
+		backoff.Reset()
+
+		c.mu.Lock()
+		c.link = link
+		c.mu.Unlock()
+		log.Printf("[Client] 🎛️ 控制通道已连接%s", c.serverVersion(link))
+		c.checkServerClock(link)
+
+		c.keepControl(link)
+
+		c.mu.Lock()
+		c.link = nil
+		c.mu.Unlock()
+
+		select {
+		case <-c.ctx.Done():
+			return
+		default:
+			log.Printf("[Client] ⚠️ 控制通道断开: %v", link.err)
+		}
+	}
+}
+
+func (c *Client) serverVersion(link *controlLink) string {
+	resp, err := link.call(control.Request{Command: control.CommandVersion, Version: version.Version})
+	if err != nil || !resp.OK {
+		return ""
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return ""
+	}
+	var info version.Info
+	if err := json.Unmarshal(data, &info); err != nil || info.Version == "" {
+		return ""
+	}
+	if info.Version != version.Version {
+		log.Printf("[Client] ⚠️ Server 版本 v%s 与本地 v%s 不一致", info.Version, version.Version)
+	}
+	return fmt.Sprintf(" (Server v%s, %s)", info.Version, info.Commit)
+}
+
+func (c *Client) checkServerClock(link *controlLink) {
+	sent := time.Now()
+	resp, err := link.call(control.Request{Command: control.CommandTime, Time: sent.UTC().Format(time.RFC3339Nano)})
+	received := time.Now()
+	if err != nil || !resp.OK {
+		return
+	}
+	text, _ := resp.Data.(string)
+	remote, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return
+	}
+	clock.Report("Client", "Server", clock.Offset(sent, received, remote), c.config.Clock.Tolerance)
+}
+
+func (c *Client) keepControl(link *controlLink) {
+	ticker := time.NewTicker(controlPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			resp, err := link.call(control.Request{Command: control.CommandPing})
+			if err == nil && !resp.OK {
+				err = fmt.Errorf("%s", resp.Error)
+			}
+			c.upstream.record(err)
+			if err != nil {
+				link.fail(err)
+				return
+			}
+		case <-link.done:
+			return
+		case <-c.ctx.Done():
+			link.Close()
+			return
+		}
+	}
+}
+
+func (c *Client) handleServerEvent(event control.Event) {
+	switch event.Name {
+	case control.EventShutdown:
+		log.Printf("[Client] 📣 Server 通知: 即将关闭")
+	case control.EventKeyRotation:
+		log.Printf("[Client] 📣 Server 通知: 密钥即将轮换 %v", event.Data)
+	case control.EventCapacity:
+		log.Printf("[Client] 📣 Server 通知: 容量状态变化 %v", event.Data)
+	default:
+		log.Printf("[Client] 📣 Server 通知: %s %v", event.Name, event.Data)
+	}
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"tunnel/pkg/crash"
+)
+
+const (
+	RouteTunnel = "tunnel"
+	RouteDirect = "direct"
+	RouteProxy  = "proxy"
+	RouteReject = "reject"
+)
+
+type RouteRule struct {
+	Match    string
+	Action   string
+	Priority int
+}
+
+type route struct {
+	domain string
+	suffix bool
+	cidr   *net.IPNet
+	any    bool
+	action string
+}
+
+type router struct {
+	routes   []route
+	fallback string
+}
+
+func newRouter(rules []RouteRule, fallback string) (*router, error) {
+	if fallback == "" {
+		fallback = RouteTunnel
+	}
+	if !isRouteAction(fallback) {
+		return nil, fmt.Errorf("invalid default route: %s", fallback)
+	}
+
+	sorted := append([]RouteRule{}, rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+
+	r := &router{fallback: fallback}
+	for _, rule := range sorted {
+		action := strings.ToLower(rule.Action)
+		if !isRouteAction(action) {
+			return nil, fmt.Errorf("invalid route action %q for %s", rule.Action, rule.Match)
+		}
+
+		match := strings.ToLower(strings.TrimSpace(rule.Match))
+		rt := route{action: action}
+		switch {
+		case match == "*":
+			rt.any = true
+		case strings.Contains(match, "/"):
+			_, cidr, err := net.ParseCIDR(match)
+			if err != nil {
+				return nil, fmt.Errorf("invalid route cidr %s: %w", rule.Match, err)
+			}
+			rt.cidr = cidr
+		case strings.HasPrefix(match, "*."):
+			rt.domain = match[2:]
+			rt.suffix = true
+		case match != "":
+			rt.domain = strings.TrimSuffix(match, ".")
+		default:
+			return nil, fmt.Errorf("empty route match")
+		}
+		r.routes = append(r.routes, rt)
+	}
+	return r, nil
+}
+
+func isRouteAction(action string) bool {
+	switch action {
+	case RouteTunnel, RouteDirect, RouteProxy, RouteReject:
+		return true
+	}
+	return false
+}
+
+func (r *router) match(target string) string {
+	if target == "USE_DEFAULT" {
+		return RouteTunnel
+	}
+
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return r.fallback
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+
+	for _, rt := range r.routes {
+		switch {
+		case rt.any:
+			return rt.action
+		case rt.cidr != nil:
+			if ip != nil && rt.cidr.Contains(ip) {
+				return rt.action
+			}
+		case ip == nil && rt.suffix:
+			if host == rt.domain || strings.HasSuffix(host, "."+rt.domain) {
+				return rt.action
+			}
+		case ip == nil:
+			if host == rt.domain {
+				return rt.action
+			}
+		}
+	}
+	return r.fallback
+}
+
+func (c *Client) handleDirect(ctx context.Context, ownerConn *countingConn, ownerAddr, targetAddr string, initialData []byte, viaProxy bool) {
+	var targetConn net.Conn
+	var err error
+	if viaProxy {
+		targetConn, err = c.bypass.DialContext(ctx, "tcp", targetAddr)
+	} else {
+		d := net.Dialer{Timeout: c.config.DialTimeout}
+		targetConn, err = d.DialContext(ctx, "tcp", targetAddr)
+	}
+	if err != nil {
+		logf(ctx, "❌ 直连目标失败: %v", err)
+		return
+	}
+	defer targetConn.Close()
+
+	if viaProxy {
+		logf(ctx, "↪️ 经旁路代理连接: %s -> %s", ownerAddr, targetAddr)
+	} else {
+		logf(ctx, "↪️ 直连: %s -> %s", ownerAddr, targetAddr)
+	}
+
+	if len(initialData) > 0 {
+		if _, err := targetConn.Write(initialData); err != nil {
+			logf(ctx, "❌ 发送初始数据失败: %v", err)
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	closeBoth := func() {
+		ownerConn.Close()
+		targetConn.Close()
+	}
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("client.direct")
+		io.Copy(targetConn, ownerConn)
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("client.direct")
+		io.Copy(ownerConn, targetConn)
+	}()
+
+	wg.Wait()
+}
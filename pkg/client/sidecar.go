@@ -0,0 +1,139 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"tunnel/pkg/crash"
+)
+
+const (
+	sidecarProbeInterval  = 10 * time.Second
+	sidecarFailureLimit   = 3
+	sidecarFailureWindow  = time.Minute
+	sidecarResolveBackoff = 5 * time.Second
+)
+
+var ErrUpstreamPending = errors.New("upstream tunnel not established")
+
+type upstreamHealth struct {
+	mu        sync.Mutex
+	lastOK    time.Time
+	failures  int
+	failing   time.Time
+	lastErr   error
+	resolved  time.Time
+	onFailure func()
+}
+
+func (u *upstreamHealth) record(err error) {
+	u.mu.Lock()
+	if err == nil {
+		if u.failures > 0 && !u.lastOK.IsZero() {
+			log.Printf("[Client] ✅ 上游隧道已恢复")
+		}
+		u.lastOK, u.failures, u.lastErr = time.Now(), 0, nil
+		u.mu.Unlock()
+		return
+	}
+
+	if u.failures == 0 {
+		u.failing = time.Now()
+	}
+	u.failures++
+	u.lastErr = err
+	resolve := u.onFailure != nil && time.Since(u.resolved) >= sidecarResolveBackoff
+	if resolve {
+		u.resolved = time.Now()
+	}
+	u.mu.Unlock()
+
+	if resolve {
+		u.onFailure()
+	}
+}
+
+func (u *upstreamHealth) idle() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return time.Since(u.lastOK) >= sidecarProbeInterval/2
+}
+
+func (u *upstreamHealth) ready() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	switch {
+	case u.lastOK.IsZero():
+		return ErrUpstreamPending
+	case u.failures > 0:
+		return fmt.Errorf("upstream handshake failing: %v", u.lastErr)
+	}
+	return nil
+}
+
+func (u *upstreamHealth) live() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.failures >= sidecarFailureLimit && time.Since(u.failing) >= sidecarFailureWindow {
+		return fmt.Errorf("%d consecutive upstream handshake failures since %s: %v",
+			u.failures, u.failing.Format(time.RFC3339), u.lastErr)
+	}
+	return nil
+}
+
+func (c *Client) Liveness() error {
+	if c.config.Sidecar {
+		return c.upstream.live()
+	}
+	return nil
+}
+
+func (c *Client) reresolve() {
+	if c.edges == nil && c.discover == "" {
+		return
+	}
+	log.Printf("[Client] 🔎 Server 握手失败，重新解析 Server 地址")
+	if c.edges != nil {
+		c.edges.flush()
+	}
+	if c.discover != "" {
+		select {
+		case c.rediscover <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *Client) watchUpstream() {
+	defer crash.Recover("client.sidecar")
+
+	log.Printf("[Client] 🛶 Sidecar 模式: 上游隧道建立后才就绪，握手持续失败 %v (且不少于 %d 次) 判定为不存活", sidecarFailureWindow, sidecarFailureLimit)
+
+	ticker := time.NewTicker(sidecarProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		c.mu.Lock()
+		linked := c.link != nil
+		c.mu.Unlock()
+
+		if !linked && c.upstream.idle() {
+			sess, _, err := c.openSession(c.ctx, controlTarget)
+			if sess != nil {
+				sess.Close()
+			}
+			if err != nil && c.ctx.Err() == nil && c.upstream.ready() != nil {
+				log.Printf("[Client] ⚠️ 上游隧道探测失败: %v", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
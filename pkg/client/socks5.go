@@ -0,0 +1,166 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"tunnel/pkg/crash"
+	"tunnel/pkg/socks5"
+)
+
+const udpAssociateTarget = "UDP_ASSOCIATE"
+
+func (c *Client) handleSOCKS5(ctx context.Context, conn net.Conn) (byte, string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, "", fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if header[0] != socks5.Version {
+		return 0, "", fmt.Errorf("unsupported socks version: %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return 0, "", fmt.Errorf("failed to read methods: %w", err)
+	}
+	if !bytes.Contains(methods, []byte{socks5.MethodNoAuth}) {
+		conn.Write([]byte{socks5.Version, socks5.MethodNoAcceptable})
+		return 0, "", fmt.Errorf("no acceptable auth method")
+	}
+	if _, err := conn.Write([]byte{socks5.Version, socks5.MethodNoAuth}); err != nil {
+		return 0, "", err
+	}
+
+	request := make([]byte, 3)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return 0, "", fmt.Errorf("failed to read request: %w", err)
+	}
+	target, err := socks5.ReadAddr(conn)
+	if err != nil {
+		socks5.Reply(conn, socks5.RepAddrNotSupported, "")
+		return 0, "", err
+	}
+
+	switch request[1] {
+	case socks5.CmdConnect:
+		if err := socks5.Reply(conn, socks5.RepSuccess, ""); err != nil {
+			return 0, "", err
+		}
+		logf(ctx, "🧦 SOCKS5 CONNECT: %s", target)
+		return socks5.CmdConnect, target, nil
+	case socks5.CmdUDPAssociate:
+		return socks5.CmdUDPAssociate, target, nil
+	default:
+		socks5.Reply(conn, socks5.RepCommandNotSupported, "")
+		return 0, "", fmt.Errorf("unsupported command: %d", request[1])
+	}
+}
+
+func (c *Client) handleUDPAssociate(ctx context.Context, ownerConn *countingConn, ownerAddr string) {
+	localIP := addrIP(ownerConn.LocalAddr())
+	ownerIP := addrIP(ownerConn.RemoteAddr())
+
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: localIP})
+	if err != nil {
+		logf(ctx, "❌ UDP 监听失败: %v", err)
+		socks5.Reply(ownerConn, socks5.RepGeneralFailure, "")
+		return
+	}
+	defer udpConn.Close()
+
+	sess, serverAddr, err := c.openSession(ctx, udpAssociateTarget)
+	if err != nil {
+		logf(ctx, "❌ %v", err)
+		socks5.Reply(ownerConn, socks5.RepGeneralFailure, "")
+		return
+	}
+	defer sess.Close()
+
+	if err := socks5.Reply(ownerConn, socks5.RepSuccess, udpConn.LocalAddr().String()); err != nil {
+		return
+	}
+
+	logf(ctx, "🧦 SOCKS5 UDP ASSOCIATE: %s via %s (本地 %s)", ownerAddr, serverAddr, udpConn.LocalAddr())
+
+	var mu sync.Mutex
+	var peer *net.UDPAddr
+
+	closeAll := func() {
+		ownerConn.Close()
+		udpConn.Close()
+		sess.Close()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		defer closeAll()
+		io.Copy(io.Discard, ownerConn)
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer closeAll()
+		defer crash.Recover("client.udp")
+
+		buf := make([]byte, 64*1024)
+		for {
+			n, from, err := udpConn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !from.IP.Equal(ownerIP) {
+				continue
+			}
+			if _, _, err := socks5.ParseDatagram(buf[:n]); err != nil {
+				continue
+			}
+
+			mu.Lock()
+			peer = from
+			mu.Unlock()
+
+			if err := sess.WriteEncrypted(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer closeAll()
+		defer crash.Recover("client.udp")
+
+		for {
+			data, err := sess.ReadEncrypted()
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			to := peer
+			mu.Unlock()
+
+			if to != nil {
+				udpConn.WriteToUDP(data, to)
+			}
+		}
+	}()
+
+	wg.Wait()
+	logf(ctx, "🔌 SOCKS5 UDP ASSOCIATE 结束: %s", ownerAddr)
+}
+
+func addrIP(addr net.Addr) net.IP {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
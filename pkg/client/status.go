@@ -0,0 +1,92 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"sort"
+
+	"tunnel/pkg/status"
+	"tunnel/pkg/version"
+)
+
+var (
+	ErrStopped  = errors.New("stopped")
+	ErrStarting = errors.New("starting")
+)
+
+func (c *Client) Ready() <-chan struct{} {
+	return c.ready
+}
+
+func (c *Client) Readiness() error {
+	if c.ctx.Err() != nil {
+		return ErrStopped
+	}
+	select {
+	case <-c.ready:
+	default:
+		return ErrStarting
+	}
+	if c.config.Sidecar {
+		return c.upstream.ready()
+	}
+	return nil
+}
+
+func (c *Client) Status() status.Status {
+	st := status.Status{
+		Mode:       "client",
+		Version:    version.Version,
+		Transports: []string{c.transport()},
+		PID:        os.Getpid(),
+	}
+
+	c.mu.Lock()
+	for _, f := range c.forwards {
+		st.Listeners = append(st.Listeners, status.Listener{Name: "forward", Address: f.ln.Addr().String()})
+	}
+	c.mu.Unlock()
+	sort.Slice(st.Listeners, func(i, j int) bool {
+		return st.Listeners[i].Address < st.Listeners[j].Address
+	})
+
+	if c.health != nil {
+		st.Listeners = append(st.Listeners, status.Listener{Name: "health", Address: c.health.Addr()})
+	}
+	if c.config.ControlSocket != "" {
+		st.Listeners = append(st.Listeners, status.Listener{Name: "control", Address: c.config.ControlSocket})
+	}
+	return st
+}
+
+func (c *Client) EffectiveConfig() Config {
+	cfg := c.config
+	cfg.Password = status.Mask(cfg.Password)
+	cfg.Key = nil
+	cfg.ServerToken = status.Mask(cfg.ServerToken)
+	cfg.DiscoverKey = status.Mask(cfg.DiscoverKey)
+	cfg.UpstreamProxy = status.MaskURL(cfg.UpstreamProxy)
+	cfg.BypassProxy = status.MaskURL(cfg.BypassProxy)
+	return cfg
+}
+
+func (c *Client) transport() string {
+	switch {
+	case c.config.EnablePoll:
+		return "poll"
+	case c.config.EnableWS && c.config.WSConfig.EnableTLS:
+		return "websocket+tls"
+	case c.config.EnableWS:
+		return "websocket"
+	case c.tls != nil:
+		return "tcp+tls"
+	}
+	return "tcp"
+}
+
+func (c *Client) Version() version.Info {
+	info := version.Get()
+	info.Transports = []string{c.transport()}
+	info.Ciphers = []string{c.cipher.Name()}
+	return info
+}
@@ -0,0 +1,132 @@
+package clock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	DefaultTolerance = 2 * time.Second
+
+	ntpPort    = "123"
+	ntpTimeout = 5 * time.Second
+	ntpEpoch   = 2208988800
+)
+
+type Config struct {
+	NTPServer string
+	Tolerance time.Duration
+}
+
+func (c Config) tolerance() time.Duration {
+	if c.Tolerance <= 0 {
+		return DefaultTolerance
+	}
+	return c.Tolerance
+}
+
+func Query(server string) (time.Duration, error) {
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, ntpPort)
+	}
+	conn, err := net.DialTimeout("udp", addr, ntpTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ntpTimeout))
+
+	req := make([]byte, 48)
+	req[0] = 0x23
+	sent := time.Now()
+	putTimestamp(req[40:], sent)
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	if n < 48 {
+		return 0, errors.New("short ntp response")
+	}
+	if mode := resp[0] & 0x07; mode != 4 {
+		return 0, fmt.Errorf("unexpected ntp mode %d", mode)
+	}
+	if !bytes.Equal(resp[24:32], req[40:48]) {
+		return 0, errors.New("ntp response does not match request")
+	}
+	if stratum := resp[1]; stratum == 0 || stratum > 15 {
+		return 0, fmt.Errorf("ntp server unsynchronized (stratum %d)", stratum)
+	}
+
+	serverRecv := timestamp(resp[32:])
+	serverSend := timestamp(resp[40:])
+	return (serverRecv.Sub(sent) + serverSend.Sub(received)) / 2, nil
+}
+
+func Offset(sent, received, remote time.Time) time.Duration {
+	return remote.Sub(sent.Add(received.Sub(sent) / 2))
+}
+
+func Check(tag string, cfg Config) {
+	if cfg.NTPServer == "" {
+		return
+	}
+	offset, err := Query(cfg.NTPServer)
+	if err != nil {
+		log.Printf("[%s] ⚠️ 时钟检查失败，无法查询 NTP %s: %v", tag, cfg.NTPServer, err)
+		return
+	}
+	Report(tag, "NTP "+cfg.NTPServer, offset, cfg.tolerance())
+}
+
+func Skewed(offset, tolerance time.Duration) bool {
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	return abs(offset) > tolerance
+}
+
+func Report(tag, source string, offset, tolerance time.Duration) bool {
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	offset = offset.Round(time.Millisecond)
+	if Skewed(offset, tolerance) {
+		log.Printf("[%s] ⚠️ 本机时钟与 %s 相差 %v，超过容忍值 %v，服务时间窗口、到期时间等依赖时钟的功能可能不准确", tag, source, offset, tolerance)
+		return false
+	}
+	log.Printf("[%s] 🕒 时钟检查通过: 与 %s 相差 %v", tag, source, offset)
+	return true
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func timestamp(b []byte) time.Time {
+	sec := binary.BigEndian.Uint32(b[0:4])
+	frac := binary.BigEndian.Uint32(b[4:8])
+	nsec := (int64(frac) * 1e9) >> 32
+	return time.Unix(int64(sec)-ntpEpoch, nsec)
+}
+
+func putTimestamp(b []byte, t time.Time) {
+	sec := uint32(t.Unix() + ntpEpoch)
+	frac := uint32((int64(t.Nanosecond()) << 32) / 1e9)
+	binary.BigEndian.PutUint32(b[0:4], sec)
+	binary.BigEndian.PutUint32(b[4:8], frac)
+}
@@ -0,0 +1,323 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"tunnel/pkg/crash"
+	"tunnel/pkg/crypto"
+	"tunnel/pkg/netutil"
+)
+
+const (
+	defaultInterval = 5 * time.Second
+	syncTimeout     = 5 * time.Second
+	maxStateAge     = 30 * time.Second
+	maxBanAhead     = 7 * 24 * time.Hour
+	maxNodes        = 64
+)
+
+type Config struct {
+	Enable   bool
+	Listen   string
+	Peers    []string
+	Node     string
+	KeyFile  string
+	Interval time.Duration
+}
+
+type State struct {
+	Node     string               `json:"node"`
+	Time     time.Time            `json:"time"`
+	Day      string               `json:"day"`
+	Sessions int                  `json:"sessions"`
+	Usage    map[string]int64     `json:"usage,omitempty"`
+	Bans     map[string]time.Time `json:"bans,omitempty"`
+}
+
+type Node struct {
+	config Config
+	cipher *crypto.AESCipher
+	local  func() State
+	merge  func(State)
+	ln     net.Listener
+
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	peers map[string]bool
+
+	done chan struct{}
+}
+
+func New(config Config, local func() State, merge func(State)) (*Node, error) {
+	if config.Listen == "" {
+		return nil, fmt.Errorf("cluster listen address is required")
+	}
+	if config.KeyFile == "" {
+		return nil, fmt.Errorf("cluster key file is required")
+	}
+	key, err := crypto.LoadKeyFile(config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cluster key: %w", err)
+	}
+	defer func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}()
+	cipher, err := crypto.NewGCMCipherFromKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster key: %w", err)
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Node == "" {
+		host, _ := os.Hostname()
+		config.Node = host + "/" + config.Listen
+	}
+
+	return &Node{
+		config: config,
+		cipher: cipher,
+		local:  local,
+		merge:  merge,
+		seen:   make(map[string]time.Time),
+		peers:  make(map[string]bool),
+	}, nil
+}
+
+func (n *Node) Start() error {
+	ln, err := netutil.Listen(n.config.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen cluster address: %w", err)
+	}
+	done := make(chan struct{})
+	n.mu.Lock()
+	n.ln, n.done = ln, done
+	n.mu.Unlock()
+
+	log.Printf("[Cluster] 🔗 集群同步已启用: 节点 %s，监听 %s，对等节点 %d 个 (每 %v)",
+		n.config.Node, n.config.Listen, len(n.config.Peers), n.config.Interval)
+
+	go n.acceptLoop(ln, done)
+	go n.syncLoop(done)
+	return nil
+}
+
+func (n *Node) Addr() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ln == nil {
+		return n.config.Listen
+	}
+	return n.ln.Addr().String()
+}
+
+func (n *Node) Stop() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.done == nil {
+		return
+	}
+	close(n.done)
+	n.done = nil
+	n.ln.Close()
+}
+
+func (n *Node) Wipe() {
+	n.cipher.Wipe()
+}
+
+func (n *Node) acceptLoop(ln net.Listener, done chan struct{}) {
+	defer crash.Recover("cluster.accept")
+
+	var backoff netutil.Backoff
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			netutil.HandleAcceptError("Cluster", err, &backoff)
+			continue
+		}
+		backoff.Reset()
+		go n.serve(conn)
+	}
+}
+
+func (n *Node) serve(conn net.Conn) {
+	defer crash.Recover("cluster.serve")
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(syncTimeout))
+
+	cc := crypto.NewCryptoConn(conn, n.cipher)
+	state, err := n.read(cc)
+	if err != nil {
+		log.Printf("[Cluster] ⚠️ 无效的同步请求: %s (%v)", conn.RemoteAddr(), err)
+		return
+	}
+	n.accept(state)
+	n.write(cc)
+}
+
+func (n *Node) syncLoop(done chan struct{}) {
+	defer crash.Recover("cluster.sync")
+
+	ticker := time.NewTicker(n.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		n.syncAll()
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Node) syncAll() {
+	var wg sync.WaitGroup
+	for _, peer := range n.config.Peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			err := n.syncPeer(peer)
+			n.markPeer(peer, err)
+		}(peer)
+	}
+	wg.Wait()
+}
+
+func (n *Node) syncPeer(peer string) error {
+	conn, err := net.DialTimeout("tcp", peer, syncTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(syncTimeout))
+
+	cc := crypto.NewCryptoConn(conn, n.cipher)
+	if err := n.write(cc); err != nil {
+		return err
+	}
+	state, err := n.read(cc)
+	if err != nil {
+		return err
+	}
+	n.accept(state)
+	return nil
+}
+
+func (n *Node) markPeer(peer string, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	up, known := n.peers[peer]
+	n.peers[peer] = err == nil
+	switch {
+	case err != nil && (up || !known):
+		log.Printf("[Cluster] ⚠️ 对等节点不可达: %s (%v)", peer, err)
+	case err == nil && !up:
+		log.Printf("[Cluster] ✅ 已与对等节点同步: %s", peer)
+	}
+}
+
+func (n *Node) read(cc *crypto.CryptoConn) (State, error) {
+	var state State
+	data, err := cc.ReadEncrypted()
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("invalid state: %w", err)
+	}
+	if err := validate(state, time.Now()); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+func validate(state State, now time.Time) error {
+	if state.Node == "" {
+		return fmt.Errorf("missing node id")
+	}
+	if age := now.Sub(state.Time); age > maxStateAge || age < -maxStateAge {
+		return fmt.Errorf("stale state (%v)", age.Round(time.Second))
+	}
+	if state.Sessions < 0 {
+		return fmt.Errorf("negative session count")
+	}
+	for ip, used := range state.Usage {
+		if used < 0 {
+			return fmt.Errorf("negative usage for %s", ip)
+		}
+	}
+	for ip, expiry := range state.Bans {
+		if expiry.Sub(now) > maxBanAhead {
+			return fmt.Errorf("ban for %s expires too far in the future", ip)
+		}
+	}
+	return nil
+}
+
+func (n *Node) write(cc *crypto.CryptoConn) error {
+	state := n.local()
+	state.Node = n.config.Node
+	state.Time = time.Now()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return cc.WriteEncrypted(data)
+}
+
+func (n *Node) accept(state State) {
+	if state.Node == n.config.Node {
+		return
+	}
+	n.mu.Lock()
+	if _, ok := n.seen[state.Node]; !ok && len(n.seen) >= maxNodes {
+		n.mu.Unlock()
+		log.Printf("[Cluster] ⚠️ 已跟踪 %d 个节点，忽略新节点 %s", maxNodes, state.Node)
+		return
+	}
+	n.seen[state.Node] = time.Now()
+	n.mu.Unlock()
+	n.merge(state)
+}
+
+func (n *Node) Stats() map[string]interface{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	nodes := make([]string, 0, len(n.seen))
+	for node := range n.seen {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	reachable := 0
+	for _, up := range n.peers {
+		if up {
+			reachable++
+		}
+	}
+
+	return map[string]interface{}{
+		"node":            n.config.Node,
+		"peers":           len(n.config.Peers),
+		"peers_reachable": reachable,
+		"nodes_seen":      nodes,
+	}
+}
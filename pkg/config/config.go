@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
+	"tunnel/pkg/crypto"
 )
 
 type Config struct {
@@ -19,6 +21,11 @@ type ServerConfig struct {
 	Listen   string `json:"listen" yaml:"listen"`
 	Target   string `json:"target" yaml:"target"`
 	Password string `json:"password" yaml:"password"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+
+	AllowDynamicTargets bool     `json:"allow_dynamic_targets" yaml:"allow_dynamic_targets"`
+	DynamicTargetAllow  []string `json:"dynamic_target_allow" yaml:"dynamic_target_allow"`
+	DynamicTargetPorts  string   `json:"dynamic_target_ports" yaml:"dynamic_target_ports"`
 
 	EnableWS bool   `json:"enable_ws" yaml:"enable_ws"`
 	WSPath   string `json:"ws_path" yaml:"ws_path"`
@@ -26,21 +33,189 @@ type ServerConfig struct {
 	WSCert   string `json:"ws_cert" yaml:"ws_cert"`
 	WSKey    string `json:"ws_key" yaml:"ws_key"`
 
-	ACL ACLConfig `json:"acl" yaml:"acl"`
+	WSAffinityCookie string `json:"ws_affinity_cookie" yaml:"ws_affinity_cookie"`
+	WSAffinityValue  string `json:"ws_affinity_value" yaml:"ws_affinity_value"`
+
+	WSAllowedOrigins []string `json:"ws_allowed_origins" yaml:"ws_allowed_origins"`
+
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+
+	WSPingInterval Duration `json:"ws_ping_interval" yaml:"ws_ping_interval"`
+	WSPongMisses   int      `json:"ws_pong_misses" yaml:"ws_pong_misses"`
+	WSReadBuffer   int      `json:"ws_read_buffer" yaml:"ws_read_buffer"`
+	WSWriteBuffer  int      `json:"ws_write_buffer" yaml:"ws_write_buffer"`
+
+	TLS TLSConfig `json:"tls" yaml:"tls"`
+
+	DualProtocol bool `json:"dual_protocol" yaml:"dual_protocol"`
+	EnablePoll   bool `json:"enable_poll" yaml:"enable_poll"`
+
+	ListenTLS bool `json:"listen_tls" yaml:"listen_tls"`
+
+	RawTLS   bool   `json:"raw_tls" yaml:"raw_tls"`
+	RawTLSCA string `json:"raw_tls_ca" yaml:"raw_tls_ca"`
+
+	FIPS bool `json:"fips" yaml:"fips"`
+
+	ListenShards int `json:"listen_shards" yaml:"listen_shards"`
+
+	FrameDebug bool `json:"frame_debug" yaml:"frame_debug"`
+
+	InsecureAllowDefault bool `json:"insecure_allow_default" yaml:"insecure_allow_default"`
+
+	MaxConnections int `json:"max_connections" yaml:"max_connections"`
+
+	DNSServer string `json:"dns_server" yaml:"dns_server"`
+
+	DSCP   int `json:"dscp" yaml:"dscp"`
+	FWMark int `json:"fwmark" yaml:"fwmark"`
+
+	TargetTLS TargetTLSConfig `json:"target_tls" yaml:"target_tls"`
+
+	ACL   ACLConfig   `json:"acl" yaml:"acl"`
+	Guard GuardConfig `json:"guard" yaml:"guard"`
+	Probe ProbeConfig `json:"probe_log" yaml:"probe_log"`
+
+	HandshakeTimeout Duration `json:"handshake_timeout" yaml:"handshake_timeout"`
+	ReadTimeout      Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout     Duration `json:"write_timeout" yaml:"write_timeout"`
+	DialTimeout      Duration `json:"dial_timeout" yaml:"dial_timeout"`
+	UDPIdleTimeout   Duration `json:"udp_idle_timeout" yaml:"udp_idle_timeout"`
+
+	SessionLog SessionLogConfig `json:"session_log" yaml:"session_log"`
+
+	Quota QuotaConfig `json:"quota" yaml:"quota"`
+
+	PeerLimit PeerLimitConfig `json:"peer_limit" yaml:"peer_limit"`
+
+	MemoryLimit int64 `json:"memory_limit" yaml:"memory_limit"`
+
+	Schedule []string `json:"schedule" yaml:"schedule"`
+
+	Usage UsageConfig `json:"usage" yaml:"usage"`
+
+	Control ControlConfig `json:"control" yaml:"control"`
+
+	Cluster ClusterConfig `json:"cluster" yaml:"cluster"`
+
+	Clock ClockConfig `json:"clock" yaml:"clock"`
+
+	PlainForwards []PlainForwardConfig `json:"plain_forwards" yaml:"plain_forwards"`
+
+	BatchDelay Duration `json:"batch_delay" yaml:"batch_delay"`
+	BatchSize  int      `json:"batch_size" yaml:"batch_size"`
+
+	RelayEngine string `json:"relay_engine" yaml:"relay_engine"`
+
+	RouteScript        string   `json:"route_script" yaml:"route_script"`
+	RouteScriptTimeout Duration `json:"route_script_timeout" yaml:"route_script_timeout"`
+
+	DrainTimeout Duration `json:"drain_timeout" yaml:"drain_timeout"`
+
+	Metrics MetricsConfig `json:"metrics" yaml:"metrics"`
+	Admin   AdminConfig   `json:"admin" yaml:"admin"`
+
+	Health string `json:"health" yaml:"health"`
+
+	Crash    CrashConfig `json:"crash" yaml:"crash"`
+	EventLog string      `json:"eventlog" yaml:"eventlog"`
+
+	ExpireAt string `json:"expire_at" yaml:"expire_at"`
 }
 
 type ClientConfig struct {
+	Name     string `json:"name" yaml:"name"`
 	Listen   string `json:"listen" yaml:"listen"`
 	Server   string `json:"server" yaml:"server"`
 	Target   string `json:"target" yaml:"target"`
 	Password string `json:"password" yaml:"password"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
 
-	EnableHTTPS bool `json:"enable_https" yaml:"enable_https"`
+	Keyring KeyringConfig `json:"keyring" yaml:"keyring"`
+
+	Servers []string `json:"servers" yaml:"servers"`
+
+	EnableHTTPS  bool `json:"enable_https" yaml:"enable_https"`
+	EnableSOCKS5 bool `json:"enable_socks5" yaml:"enable_socks5"`
 
 	EnableWS     bool   `json:"enable_ws" yaml:"enable_ws"`
 	WSPath       string `json:"ws_path" yaml:"ws_path"`
 	WSTLS        bool   `json:"ws_tls" yaml:"ws_tls"`
 	WSSkipVerify bool   `json:"ws_skip_verify" yaml:"ws_skip_verify"`
+	WSEdgeRotate bool   `json:"ws_edge_rotate" yaml:"ws_edge_rotate"`
+	WSAffinity   bool   `json:"ws_affinity" yaml:"ws_affinity"`
+	WSOrigin     string `json:"ws_origin" yaml:"ws_origin"`
+
+	WSPingInterval Duration `json:"ws_ping_interval" yaml:"ws_ping_interval"`
+	WSPongMisses   int      `json:"ws_pong_misses" yaml:"ws_pong_misses"`
+	WSReadBuffer   int      `json:"ws_read_buffer" yaml:"ws_read_buffer"`
+	WSWriteBuffer  int      `json:"ws_write_buffer" yaml:"ws_write_buffer"`
+
+	EnablePoll bool `json:"enable_poll" yaml:"enable_poll"`
+
+	ServerTLS           bool   `json:"server_tls" yaml:"server_tls"`
+	ServerTLSSNI        string `json:"server_tls_sni" yaml:"server_tls_sni"`
+	ServerTLSSkipVerify bool   `json:"server_tls_skip_verify" yaml:"server_tls_skip_verify"`
+
+	RawTLS     bool   `json:"raw_tls" yaml:"raw_tls"`
+	RawTLSCert string `json:"raw_tls_cert" yaml:"raw_tls_cert"`
+	RawTLSKey  string `json:"raw_tls_key" yaml:"raw_tls_key"`
+
+	FIPS bool `json:"fips" yaml:"fips"`
+
+	FrameDebug bool `json:"frame_debug" yaml:"frame_debug"`
+
+	InsecureAllowDefault bool `json:"insecure_allow_default" yaml:"insecure_allow_default"`
+
+	MaxConnections int `json:"max_connections" yaml:"max_connections"`
+
+	Forwards []ForwardConfig `json:"forwards" yaml:"forwards"`
+
+	ControlSocket string `json:"control_socket" yaml:"control_socket"`
+
+	ServerToken string `json:"server_token" yaml:"server_token"`
+	ServerLink  bool   `json:"server_link" yaml:"server_link"`
+
+	DiscoverKey string `json:"server_discover_key" yaml:"server_discover_key"`
+
+	UpstreamProxy string `json:"upstream_proxy" yaml:"upstream_proxy"`
+
+	ReadTimeout  Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout Duration `json:"write_timeout" yaml:"write_timeout"`
+	DialTimeout  Duration `json:"dial_timeout" yaml:"dial_timeout"`
+
+	BatchDelay Duration `json:"batch_delay" yaml:"batch_delay"`
+	BatchSize  int      `json:"batch_size" yaml:"batch_size"`
+
+	RelayEngine string `json:"relay_engine" yaml:"relay_engine"`
+
+	Health  string `json:"health" yaml:"health"`
+	Sidecar bool   `json:"sidecar" yaml:"sidecar"`
+
+	DSCP   int `json:"dscp" yaml:"dscp"`
+	FWMark int `json:"fwmark" yaml:"fwmark"`
+
+	DNSOverrides map[string]string `json:"dns_overrides" yaml:"dns_overrides"`
+
+	Routes       []RouteConfig `json:"routes" yaml:"routes"`
+	DefaultRoute string        `json:"default_route" yaml:"default_route"`
+	BypassProxy  string        `json:"bypass_proxy" yaml:"bypass_proxy"`
+
+	Clock ClockConfig `json:"clock" yaml:"clock"`
+
+	Crash    CrashConfig `json:"crash" yaml:"crash"`
+	EventLog string      `json:"eventlog" yaml:"eventlog"`
+}
+
+type KeyringConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Kind string `json:"kind" yaml:"kind"`
+}
+
+type RouteConfig struct {
+	Match    string `json:"match" yaml:"match"`
+	Action   string `json:"action" yaml:"action"`
+	Priority int    `json:"priority" yaml:"priority"`
 }
 
 type ACLConfig struct {
@@ -48,6 +223,155 @@ type ACLConfig struct {
 	Mode      string   `json:"mode" yaml:"mode"`
 	Whitelist []string `json:"whitelist" yaml:"whitelist"`
 	Blacklist []string `json:"blacklist" yaml:"blacklist"`
+
+	Logic string         `json:"logic" yaml:"logic"`
+	Match ACLMatchConfig `json:"match" yaml:"match"`
+
+	Rules   []ACLRuleConfig `json:"rules" yaml:"rules"`
+	Default string          `json:"default" yaml:"default"`
+	GeoIP   string          `json:"geoip" yaml:"geoip"`
+
+	Pin ACLPinConfig `json:"pin" yaml:"pin"`
+
+	KernelFilter bool `json:"kernel_filter" yaml:"kernel_filter"`
+}
+
+type ACLPinConfig struct {
+	Enable   bool `json:"enable" yaml:"enable"`
+	PrefixV4 int  `json:"prefix_v4" yaml:"prefix_v4"`
+	PrefixV6 int  `json:"prefix_v6" yaml:"prefix_v6"`
+}
+
+type ACLRuleConfig struct {
+	Name      string   `json:"name" yaml:"name"`
+	Action    string   `json:"action" yaml:"action"`
+	IPs       []string `json:"ips" yaml:"ips"`
+	Countries []string `json:"countries" yaml:"countries"`
+	Days      []string `json:"days" yaml:"days"`
+	Hours     string   `json:"hours" yaml:"hours"`
+	Transport string   `json:"transport" yaml:"transport"`
+
+	ACLMatchConfig `yaml:",inline"`
+}
+
+type ACLMatchConfig struct {
+	JA3       []string          `json:"ja3" yaml:"ja3"`
+	JA4       []string          `json:"ja4" yaml:"ja4"`
+	UserAgent []string          `json:"user_agent" yaml:"user_agent"`
+	Headers   map[string]string `json:"headers" yaml:"headers"`
+	Paths     []string          `json:"paths" yaml:"paths"`
+}
+
+type TLSConfig struct {
+	MinVersion   string   `json:"min_version" yaml:"min_version"`
+	MaxVersion   string   `json:"max_version" yaml:"max_version"`
+	CipherSuites []string `json:"cipher_suites" yaml:"cipher_suites"`
+	Curves       []string `json:"curves" yaml:"curves"`
+	ALPN         []string `json:"alpn" yaml:"alpn"`
+
+	OCSPStapling   bool     `json:"ocsp_stapling" yaml:"ocsp_stapling"`
+	ReloadInterval Duration `json:"reload_interval" yaml:"reload_interval"`
+}
+
+type TargetTLSConfig struct {
+	Enable     bool     `json:"enable" yaml:"enable"`
+	ServerName string   `json:"server_name" yaml:"server_name"`
+	ALPN       []string `json:"alpn" yaml:"alpn"`
+	Cert       string   `json:"cert" yaml:"cert"`
+	Key        string   `json:"key" yaml:"key"`
+	CA         string   `json:"ca" yaml:"ca"`
+	SkipVerify bool     `json:"skip_verify" yaml:"skip_verify"`
+}
+
+type GuardConfig struct {
+	Enable      bool     `json:"enable" yaml:"enable"`
+	MaxFailures int      `json:"max_failures" yaml:"max_failures"`
+	BanDuration Duration `json:"ban_duration" yaml:"ban_duration"`
+}
+
+type ProbeConfig struct {
+	Enable   bool   `json:"enable" yaml:"enable"`
+	Path     string `json:"path" yaml:"path"`
+	MaxBytes int    `json:"max_bytes" yaml:"max_bytes"`
+}
+
+type SessionLogConfig struct {
+	Enable bool   `json:"enable" yaml:"enable"`
+	Output string `json:"output" yaml:"output"`
+}
+
+type QuotaConfig struct {
+	SessionBytes int64 `json:"session_bytes" yaml:"session_bytes"`
+	DailyBytes   int64 `json:"daily_bytes" yaml:"daily_bytes"`
+}
+
+type PeerLimitConfig struct {
+	MaxSessions int `json:"max_sessions" yaml:"max_sessions"`
+	PerMinute   int `json:"per_minute" yaml:"per_minute"`
+}
+
+type UsageConfig struct {
+	MaxSessions    int  `json:"max_sessions" yaml:"max_sessions"`
+	PinFirstClient bool `json:"pin_first_client" yaml:"pin_first_client"`
+}
+
+type ControlConfig struct {
+	Enable   bool   `json:"enable" yaml:"enable"`
+	Token    string `json:"token" yaml:"token"`
+	LogLines int    `json:"log_lines" yaml:"log_lines"`
+}
+
+type ClockConfig struct {
+	NTPServer string   `json:"ntp_server" yaml:"ntp_server"`
+	Tolerance Duration `json:"tolerance" yaml:"tolerance"`
+}
+
+type ClusterConfig struct {
+	Enable   bool     `json:"enable" yaml:"enable"`
+	Listen   string   `json:"listen" yaml:"listen"`
+	Peers    []string `json:"peers" yaml:"peers"`
+	Node     string   `json:"node" yaml:"node"`
+	KeyFile  string   `json:"key_file" yaml:"key_file"`
+	Interval Duration `json:"interval" yaml:"interval"`
+}
+
+type PlainForwardConfig struct {
+	Name   string `json:"name" yaml:"name"`
+	Listen string `json:"listen" yaml:"listen"`
+	Target string `json:"target" yaml:"target"`
+}
+
+type ForwardConfig struct {
+	Name   string `json:"name" yaml:"name"`
+	Listen string `json:"listen" yaml:"listen"`
+	Target string `json:"target" yaml:"target"`
+}
+
+type MetricsConfig struct {
+	Push MetricsPushConfig `json:"push" yaml:"push"`
+}
+
+type MetricsPushConfig struct {
+	Enable   bool     `json:"enable" yaml:"enable"`
+	Protocol string   `json:"protocol" yaml:"protocol"`
+	Address  string   `json:"address" yaml:"address"`
+	Network  string   `json:"network" yaml:"network"`
+	Interval Duration `json:"interval" yaml:"interval"`
+	Prefix   string   `json:"prefix" yaml:"prefix"`
+}
+
+type AdminConfig struct {
+	Enable bool   `json:"enable" yaml:"enable"`
+	Listen string `json:"listen" yaml:"listen"`
+	Token  string `json:"token" yaml:"token"`
+	Pprof  bool   `json:"pprof" yaml:"pprof"`
+	Kill   bool   `json:"kill" yaml:"kill"`
+}
+
+type CrashConfig struct {
+	Dir      string `json:"dir" yaml:"dir"`
+	Webhook  string `json:"webhook" yaml:"webhook"`
+	LogLines int    `json:"log_lines" yaml:"log_lines"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -112,19 +436,24 @@ func DefaultServerConfig() ServerConfig {
 	return ServerConfig{
 		Listen:   "0.0.0.0:8888",
 		Target:   "127.0.0.1:50050",
-		Password: "SecureTunnel@2024",
+		Password: crypto.DefaultPassword,
 		WSPath:   "/ws",
 		ACL: ACLConfig{
 			Enable: false,
 			Mode:   "whitelist",
 		},
+		Guard: GuardConfig{
+			Enable:      false,
+			MaxFailures: 3,
+			BanDuration: NewDuration(30 * time.Minute),
+		},
 	}
 }
 
 func DefaultClientConfig() ClientConfig {
 	return ClientConfig{
 		Listen:   "127.0.0.1:443",
-		Password: "SecureTunnel@2024",
+		Password: crypto.DefaultPassword,
 		WSPath:   "/ws",
 	}
 }
@@ -151,6 +480,11 @@ func GenerateExampleConfig() *Config {
 					"192.168.1.100",
 				},
 			},
+			Guard: GuardConfig{
+				Enable:      true,
+				MaxFailures: 3,
+				BanDuration: NewDuration(30 * time.Minute),
+			},
 		},
 		Client: ClientConfig{
 			Listen:      "127.0.0.1:443",
@@ -185,6 +519,11 @@ func GenerateServerExampleConfig() *Config {
 					"192.168.1.100",
 				},
 			},
+			Guard: GuardConfig{
+				Enable:      true,
+				MaxFailures: 3,
+				BanDuration: NewDuration(30 * time.Minute),
+			},
 		},
 	}
 }
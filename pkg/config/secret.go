@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	EnvPassword     = "TUNNEL_PASSWORD"
+	EnvAdminToken   = "TUNNEL_ADMIN_TOKEN"
+	EnvControlToken = "TUNNEL_CONTROL_TOKEN"
+	EnvServerToken  = "TUNNEL_SERVER_TOKEN"
+	EnvDiscoverKey  = "TUNNEL_DISCOVER_KEY"
+)
+
+func LookupSecret(name string) (string, bool, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	if value := os.Getenv(name); value != "" {
+		return value, true, nil
+	}
+	return "", false, nil
+}
+
+func FillSecret(dst *string, name string) error {
+	if *dst != "" {
+		return nil
+	}
+	value, ok, err := LookupSecret(name)
+	if err != nil {
+		return err
+	}
+	if ok {
+		*dst = value
+	}
+	return nil
+}
+
+func (c *Config) ApplySecretEnv() error {
+	for _, s := range []struct {
+		dst  *string
+		name string
+	}{
+		{&c.Server.Password, EnvPassword},
+		{&c.Server.Admin.Token, EnvAdminToken},
+		{&c.Server.Control.Token, EnvControlToken},
+		{&c.Client.Password, EnvPassword},
+		{&c.Client.ServerToken, EnvServerToken},
+		{&c.Client.DiscoverKey, EnvDiscoverKey},
+	} {
+		if err := FillSecret(s.dst, s.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"tunnel/pkg/crash"
+)
+
+const (
+	CommandList   = "list"
+	CommandAdd    = "add"
+	CommandRemove = "remove"
+	CommandOnce   = "once"
+	CommandStats  = "stats"
+	CommandLogs   = "logs"
+
+	CommandACLAllow  = "acl_allow"
+	CommandACLDeny   = "acl_deny"
+	CommandACLRemove = "acl_remove"
+	CommandACLList   = "acl_list"
+)
+
+type Request struct {
+	Command string `json:"command"`
+	Name    string `json:"name,omitempty"`
+	Listen  string `json:"listen,omitempty"`
+	Target  string `json:"target,omitempty"`
+	Lines   int    `json:"lines,omitempty"`
+	Token   string `json:"token,omitempty"`
+	Address string `json:"address,omitempty"`
+	Version string `json:"version,omitempty"`
+	Wait    string `json:"wait,omitempty"`
+	Time    string `json:"time,omitempty"`
+}
+
+type Response struct {
+	OK    bool        `json:"ok"`
+	Error string      `json:"error,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+func Failure(err error) Response {
+	return Response{Error: err.Error()}
+}
+
+func Success(data interface{}) Response {
+	return Response{OK: true, Data: data}
+}
+
+type Server struct {
+	path    string
+	ln      net.Listener
+	handler func(Request) Response
+}
+
+func Listen(path string, handler func(Request) Response) (*Server, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("control socket %s is already in use", path)
+	}
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to chmod control socket: %w", err)
+	}
+
+	s := &Server{path: path, ln: ln, handler: handler}
+	go s.serve()
+
+	log.Printf("[Control] 🎛️ 控制接口已启动: %s", path)
+	return s, nil
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer crash.Recover("control")
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	var req Request
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(Failure(fmt.Errorf("invalid request: %w", err)))
+		return
+	}
+
+	json.NewEncoder(conn).Encode(s.handler(req))
+}
+
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	os.Remove(s.path)
+	return err
+}
+
+func Call(path string, req Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to connect control socket: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, err
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("invalid response: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,80 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const (
+	KindRequest  = "request"
+	KindResponse = "response"
+	KindEvent    = "event"
+
+	CommandPing    = "ping"
+	CommandKill    = "kill"
+	CommandVersion = "version"
+	CommandConfig  = "config"
+	CommandTime    = "time"
+
+	EventShutdown    = "shutdown"
+	EventKeyRotation = "key_rotation"
+	EventCapacity    = "capacity"
+	EventSessionEnd  = "session_end"
+)
+
+type Message struct {
+	ID       uint64    `json:"id,omitempty"`
+	Kind     string    `json:"kind"`
+	Request  *Request  `json:"request,omitempty"`
+	Response *Response `json:"response,omitempty"`
+	Event    *Event    `json:"event,omitempty"`
+}
+
+type Event struct {
+	Name string      `json:"name"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+type FrameConn interface {
+	ReadEncrypted() ([]byte, error)
+	WriteEncrypted(data []byte) error
+	Close() error
+}
+
+type Stream struct {
+	conn FrameConn
+	mu   sync.Mutex
+}
+
+func NewStream(conn FrameConn) *Stream {
+	return &Stream{conn: conn}
+}
+
+func (s *Stream) Send(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.WriteEncrypted(data)
+}
+
+func (s *Stream) Receive() (Message, error) {
+	data, err := s.conn.ReadEncrypted()
+	if err != nil {
+		return Message{}, err
+	}
+
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return Message{}, fmt.Errorf("invalid control message: %w", err)
+	}
+	return msg, nil
+}
+
+func (s *Stream) Close() error {
+	return s.conn.Close()
+}
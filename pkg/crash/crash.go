@@ -0,0 +1,254 @@
+package crash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Dir      string
+	Webhook  string
+	LogLines int
+}
+
+type Reporter struct {
+	config     Config
+	configHash string
+	logs       *LogBuffer
+	client     *http.Client
+}
+
+type Report struct {
+	Time       time.Time `json:"time"`
+	Host       string    `json:"host"`
+	PID        int       `json:"pid"`
+	Component  string    `json:"component"`
+	Reason     string    `json:"reason"`
+	GoVersion  string    `json:"go_version"`
+	ConfigHash string    `json:"config_hash"`
+	Stack      string    `json:"stack"`
+	Goroutines string    `json:"goroutines"`
+	RecentLogs []string  `json:"recent_logs"`
+}
+
+var (
+	mu        sync.RWMutex
+	current   *Reporter
+	logOutput io.Writer = os.Stderr
+)
+
+func Install(config Config) *Reporter {
+	if config.LogLines <= 0 {
+		config.LogLines = 200
+	}
+
+	r := &Reporter{
+		config: config,
+		logs:   NewLogBuffer(config.LogLines),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	mu.Lock()
+	current = r
+	log.SetOutput(io.MultiWriter(logOutput, r.logs))
+	mu.Unlock()
+
+	return r
+}
+
+func SetLogOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	setLogOutput(w)
+}
+
+func TeeLogOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	setLogOutput(io.MultiWriter(logOutput, w))
+}
+
+func setLogOutput(w io.Writer) {
+	logOutput = w
+	if current != nil {
+		log.SetOutput(io.MultiWriter(logOutput, current.logs))
+	} else {
+		log.SetOutput(w)
+	}
+}
+
+func SetConfigHash(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		current.configHash = hex.EncodeToString(sum[:])
+	}
+}
+
+func ConfigHash() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return ""
+	}
+	return current.configHash
+}
+
+func (r *Reporter) Enabled() bool {
+	return r != nil && (r.config.Dir != "" || r.config.Webhook != "")
+}
+
+func (r *Reporter) Report(component, reason string, stack []byte) {
+	if !r.Enabled() {
+		return
+	}
+
+	mu.RLock()
+	configHash := r.configHash
+	mu.RUnlock()
+
+	host, _ := os.Hostname()
+	goroutines := make([]byte, 1<<20)
+	goroutines = goroutines[:runtime.Stack(goroutines, true)]
+
+	report := Report{
+		Time:       time.Now(),
+		Host:       host,
+		PID:        os.Getpid(),
+		Component:  component,
+		Reason:     reason,
+		GoVersion:  runtime.Version(),
+		ConfigHash: configHash,
+		Stack:      string(stack),
+		Goroutines: string(goroutines),
+		RecentLogs: r.logs.Lines(),
+	}
+
+	if r.config.Dir != "" {
+		if path, err := r.writeFile(report); err != nil {
+			fmt.Fprintf(os.Stderr, "[Crash] ⚠️ 写入崩溃报告失败: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "[Crash] 📝 崩溃报告已保存: %s\n", path)
+		}
+	}
+
+	if r.config.Webhook != "" {
+		if err := r.postWebhook(report); err != nil {
+			fmt.Fprintf(os.Stderr, "[Crash] ⚠️ 发送崩溃报告失败: %v\n", err)
+		}
+	}
+}
+
+func (r *Reporter) writeFile(report Report) (string, error) {
+	if err := os.MkdirAll(r.config.Dir, 0700); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("crash-%s-%d.json", report.Time.Format("20060102-150405"), report.PID)
+	path := filepath.Join(r.config.Dir, name)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return path, os.WriteFile(path, data, 0600)
+}
+
+func (r *Reporter) postWebhook(report Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Post(r.config.Webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func Recover(component string) {
+	p := recover()
+	if p == nil {
+		return
+	}
+
+	stack := make([]byte, 64*1024)
+	stack = stack[:runtime.Stack(stack, false)]
+	log.Printf("[Crash] 💥 %s 发生 panic: %v\n%s", component, p, stack)
+
+	mu.RLock()
+	r := current
+	mu.RUnlock()
+	r.Report(component, fmt.Sprintf("panic: %v", p), stack)
+}
+
+func Exit(component string, err error) {
+	mu.RLock()
+	r := current
+	mu.RUnlock()
+
+	stack := make([]byte, 64*1024)
+	stack = stack[:runtime.Stack(stack, false)]
+	r.Report(component, fmt.Sprintf("exit: %v", err), stack)
+}
+
+type LogBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{lines: make([]string, size)}
+}
+
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % len(b.lines)
+		if b.next == 0 {
+			b.full = true
+		}
+	}
+	return len(p), nil
+}
+
+func (b *LogBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines := make([]string, 0, len(b.lines))
+	if b.full {
+		lines = append(lines, b.lines[b.next:]...)
+	}
+	return append(lines, b.lines[:b.next]...)
+}
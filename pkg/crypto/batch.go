@@ -0,0 +1,89 @@
+package crypto
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultBatchSize = 16 * 1024
+
+type BatchConfig struct {
+	Delay time.Duration
+	Size  int
+}
+
+type FrameWriter interface {
+	WriteEncrypted(data []byte) error
+}
+
+type Batcher struct {
+	w     FrameWriter
+	delay time.Duration
+	size  int
+
+	mu    sync.Mutex
+	buf   []byte
+	timer *time.Timer
+	err   error
+}
+
+func NewBatcher(w FrameWriter, cfg BatchConfig) *Batcher {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultBatchSize
+	}
+	return &Batcher{w: w, delay: cfg.Delay, size: cfg.Size}
+}
+
+func (b *Batcher) WriteEncrypted(data []byte) error {
+	if b.delay <= 0 {
+		return b.w.WriteEncrypted(data)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+	if len(b.buf) == 0 && len(data) >= b.size {
+		return b.w.WriteEncrypted(data)
+	}
+
+	b.buf = append(b.buf, data...)
+	if len(b.buf) >= b.size {
+		return b.flushLocked()
+	}
+	if len(b.buf) == len(data) {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.delay, func() { b.Flush() })
+		} else {
+			b.timer.Reset(b.delay)
+		}
+	}
+	return nil
+}
+
+func (b *Batcher) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushLocked()
+}
+
+func (b *Batcher) flushLocked() error {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	if len(b.buf) == 0 || b.err != nil {
+		return b.err
+	}
+	b.err = b.w.WriteEncrypted(b.buf)
+	b.buf = b.buf[:0]
+	return b.err
+}
+
+func (b *Batcher) Close() error {
+	if b.delay <= 0 {
+		return nil
+	}
+	return b.Flush()
+}
@@ -5,14 +5,42 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
+	"log"
 	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	MaxFrameLength      = 1024 * 1024 * 10
+	DefaultFrameTimeout = 30 * time.Second
+)
+
+var (
+	ErrFrameDesync = errors.New("frame desync")
+	ErrDecrypt     = errors.New("decrypt failed")
+	ErrWiped       = errors.New("cipher key wiped")
 )
 
 type AESCipher struct {
 	key   []byte
+	state atomic.Pointer[cipherState]
+}
+
+type cipherState struct {
 	block cipher.Block
+	aead  cipher.AEAD
+}
+
+func newAESCipher(key []byte, block cipher.Block, aead cipher.AEAD) *AESCipher {
+	c := &AESCipher{key: key}
+	c.state.Store(&cipherState{block: block, aead: aead})
+	return c
 }
 
 func NewAESCipher(password string) (*AESCipher, error) {
@@ -24,37 +52,80 @@ func NewAESCipher(password string) (*AESCipher, error) {
 		return nil, err
 	}
 
-	return &AESCipher{
-		key:   key,
-		block: block,
-	}, nil
+	return newAESCipher(key, block, nil), nil
+}
+
+func (c *AESCipher) Wipe() {
+	c.state.Store(nil)
+	for i := range c.key {
+		c.key[i] = 0
+	}
 }
 
 func (c *AESCipher) Encrypt(plaintext []byte) ([]byte, error) {
-	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
-	iv := ciphertext[:aes.BlockSize]
+	return c.AppendEncrypt(nil, plaintext)
+}
 
+func (c *AESCipher) Overhead() int {
+	if st := c.state.Load(); st != nil && st.aead != nil {
+		return st.aead.NonceSize() + st.aead.Overhead()
+	}
+	return aes.BlockSize
+}
+
+func (c *AESCipher) AppendEncrypt(dst, plaintext []byte) ([]byte, error) {
+	st := c.state.Load()
+	if st == nil {
+		return nil, ErrWiped
+	}
+	if st.aead != nil {
+		return appendSeal(st.aead, dst, plaintext)
+	}
+
+	n := len(dst)
+	total := n + aes.BlockSize + len(plaintext)
+	if cap(dst) < total {
+		grown := make([]byte, n, total)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:total]
+
+	iv := dst[n : n+aes.BlockSize]
 	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
 		return nil, err
 	}
 
-	stream := cipher.NewCFBEncrypter(c.block, iv)
-	stream.XORKeyStream(ciphertext[aes.BlockSize:], plaintext)
+	stream := cipher.NewCFBEncrypter(st.block, iv)
+	stream.XORKeyStream(dst[n+aes.BlockSize:], plaintext)
 
-	return ciphertext, nil
+	return dst, nil
 }
 
 func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+	return c.DecryptInPlace(append([]byte(nil), ciphertext...))
+}
+
+func (c *AESCipher) DecryptInPlace(ciphertext []byte) ([]byte, error) {
+	st := c.state.Load()
+	if st == nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, ErrWiped)
+	}
+	if st.aead != nil {
+		return open(st.aead, ciphertext)
+	}
 	if len(ciphertext) < aes.BlockSize {
-		return nil, errors.New("ciphertext too short")
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
 	}
 
 	iv := ciphertext[:aes.BlockSize]
-	ciphertext = ciphertext[aes.BlockSize:]
+	plaintext := ciphertext[aes.BlockSize:]
 
-	plaintext := make([]byte, len(ciphertext))
-	stream := cipher.NewCFBDecrypter(c.block, iv)
-	stream.XORKeyStream(plaintext, ciphertext)
+	stream := cipher.NewCFBDecrypter(st.block, iv)
+	stream.XORKeyStream(plaintext, plaintext)
 
 	return plaintext, nil
 }
@@ -62,53 +133,148 @@ func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
 type CryptoConn struct {
 	net.Conn
 	cipher *AESCipher
+	debug  bool
+
+	frameTimeout time.Duration
+	writeTimeout time.Duration
+	readDeadline atomic.Int64
+
+	readOffset  int64
+	readFrames  int64
+	writeOffset int64
+	writeFrames int64
 }
 
 func NewCryptoConn(conn net.Conn, cipher *AESCipher) *CryptoConn {
 	return &CryptoConn{
-		Conn:   conn,
-		cipher: cipher,
+		Conn:         conn,
+		cipher:       cipher,
+		frameTimeout: DefaultFrameTimeout,
 	}
 }
 
+func (c *CryptoConn) SetDebug(debug bool) {
+	c.debug = debug
+}
+
+func (c *CryptoConn) SetFrameTimeout(timeout time.Duration) {
+	c.frameTimeout = timeout
+}
+
+func (c *CryptoConn) SetWriteTimeout(timeout time.Duration) {
+	c.writeTimeout = timeout
+}
+
+func (c *CryptoConn) SetReadDeadline(t time.Time) error {
+	if t.IsZero() {
+		c.readDeadline.Store(0)
+	} else {
+		c.readDeadline.Store(t.UnixNano())
+	}
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *CryptoConn) restoreReadDeadline() {
+	var t time.Time
+	if deadline := c.readDeadline.Load(); deadline != 0 {
+		t = time.Unix(0, deadline)
+	}
+	c.Conn.SetReadDeadline(t)
+}
+
+func (c *CryptoConn) desync(format string, args ...interface{}) error {
+	c.Conn.Close()
+	return fmt.Errorf("%w: "+format, append([]interface{}{ErrFrameDesync}, args...)...)
+}
+
 func (c *CryptoConn) ReadEncrypted() ([]byte, error) {
+	frameOffset := c.readOffset
+	frame := c.readFrames
+
 	lenBuf := make([]byte, 4)
 	if _, err := io.ReadFull(c.Conn, lenBuf); err != nil {
 		return nil, err
 	}
+	c.readOffset += 4
 
 	length := int(lenBuf[0])<<24 | int(lenBuf[1])<<16 | int(lenBuf[2])<<8 | int(lenBuf[3])
 
-	if length <= 0 || length > 1024*1024*10 {
-		return nil, errors.New("invalid data length")
+	if length <= 0 || length > MaxFrameLength {
+		if c.debug {
+			log.Printf("[Frame] ❌ 帧长度非法: 第 %d 帧，偏移 %d，帧头 % x", frame, frameOffset, lenBuf)
+		}
+		return nil, c.desync("invalid data length %d at offset %d (frame %d)", length, frameOffset, frame)
+	}
+
+	if c.frameTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.frameTimeout))
+		defer c.restoreReadDeadline()
 	}
 
 	encrypted := make([]byte, length)
 	if _, err := io.ReadFull(c.Conn, encrypted); err != nil {
+		if isTimeout(err) {
+			return nil, c.desync("incomplete frame of length %d at offset %d (frame %d)", length, frameOffset, frame)
+		}
 		return nil, err
 	}
+	c.readOffset += int64(length)
 
-	return c.cipher.Decrypt(encrypted)
+	if c.debug {
+		crcBuf := make([]byte, 4)
+		if _, err := io.ReadFull(c.Conn, crcBuf); err != nil {
+			if isTimeout(err) {
+				return nil, c.desync("missing checksum at offset %d (frame %d)", frameOffset, frame)
+			}
+			return nil, err
+		}
+		c.readOffset += 4
+
+		expected := binary.BigEndian.Uint32(crcBuf)
+		actual := crc32.ChecksumIEEE(encrypted)
+		if expected != actual {
+			log.Printf("[Frame] ❌ CRC 校验失败: 第 %d 帧，偏移 %d，长度 %d，期望 %08x，实际 %08x",
+				frame, frameOffset, length, expected, actual)
+			return nil, c.desync("frame checksum mismatch at offset %d (frame %d)", frameOffset, frame)
+		}
+	}
+
+	c.readFrames++
+	return c.cipher.DecryptInPlace(encrypted)
 }
 
 func (c *CryptoConn) WriteEncrypted(data []byte) error {
-	encrypted, err := c.cipher.Encrypt(data)
+	size := 4 + c.cipher.Overhead() + len(data)
+	if c.debug {
+		size += 4
+	}
+
+	frame, err := c.cipher.AppendEncrypt(make([]byte, 4, size), data)
 	if err != nil {
 		return err
 	}
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)-4))
 
-	length := len(encrypted)
-	lenBuf := []byte{
-		byte(length >> 24),
-		byte(length >> 16),
-		byte(length >> 8),
-		byte(length),
+	if c.debug {
+		frame = binary.BigEndian.AppendUint32(frame, crc32.ChecksumIEEE(frame[4:]))
 	}
 
-	if _, err := c.Conn.Write(lenBuf); err != nil {
-		return err
+	if c.writeTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	_, err = c.Conn.Write(frame)
+	if err == nil {
+		c.writeOffset += int64(len(frame))
+		c.writeFrames++
 	}
-
-	_, err = c.Conn.Write(encrypted)
 	return err
 }
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (c *CryptoConn) Offsets() (readOffset, writeOffset int64) {
+	return c.readOffset, c.writeOffset
+}
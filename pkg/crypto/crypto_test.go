@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func benchCipher(b *testing.B) *AESCipher {
+	b.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	cipher, err := NewAESCipherFromKey(key)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return cipher
+}
+
+func benchConnPair(b *testing.B, ln net.Listener) (client, server net.Conn) {
+	b.Helper()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	server, ok := <-accepted
+	if !ok {
+		b.Fatal("accept failed")
+	}
+	return client, server
+}
+
+func BenchmarkCryptoConnBidirectional(b *testing.B) {
+	for _, size := range []int{64, 1024, 16 * 1024} {
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			cipher := benchCipher(b)
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer ln.Close()
+
+			payload := make([]byte, size)
+			b.SetBytes(int64(2 * size))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				client, server := benchConnPair(b, ln)
+				defer client.Close()
+				defer server.Close()
+
+				go func() {
+					echo := NewCryptoConn(server, cipher)
+					for {
+						data, err := echo.ReadEncrypted()
+						if err != nil {
+							return
+						}
+						if err := echo.WriteEncrypted(data); err != nil {
+							return
+						}
+					}
+				}()
+
+				conn := NewCryptoConn(client, cipher)
+				for pb.Next() {
+					if err := conn.WriteEncrypted(payload); err != nil {
+						b.Error(err)
+						return
+					}
+					if _, err := conn.ReadEncrypted(); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const fipsKeyInfo = "tunnel frame key aes-256-gcm"
+
+var ErrFIPSModule = errors.New("fips mode requires a FIPS 140-3 crypto module: build with GOFIPS140=v1.0.0 or run with GODEBUG=fips140=on (Go 1.24+), or build with GOEXPERIMENT=boringcrypto")
+
+func FIPSModule() string {
+	return fipsModule()
+}
+
+func NewFIPSCipher(key []byte) (*AESCipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	if FIPSModule() == "" {
+		return nil, ErrFIPSModule
+	}
+
+	derived, block, aead, err := newFIPSAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return newAESCipher(derived, block, aead), nil
+}
+
+func NewFIPSCipherFromFile(keyFile string) (*AESCipher, error) {
+	if keyFile == "" {
+		return nil, errors.New("fips mode requires a key file, password-derived keys are not approved")
+	}
+	key, err := LoadKeyFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}()
+	return NewFIPSCipher(key)
+}
+
+func (c *AESCipher) FIPS() bool {
+	st := c.state.Load()
+	return st != nil && st.aead != nil
+}
+
+func (c *AESCipher) Name() string {
+	if c.FIPS() {
+		return "AES-256-GCM"
+	}
+	return "AES-256-CFB"
+}
+
+func appendSeal(aead cipher.AEAD, dst, plaintext []byte) ([]byte, error) {
+	n := len(dst)
+	nonceSize := aead.NonceSize()
+	total := n + nonceSize + len(plaintext) + aead.Overhead()
+	if cap(dst) < total {
+		grown := make([]byte, n, total)
+		copy(grown, dst)
+		dst = grown
+	}
+
+	nonce := dst[n : n+nonceSize]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(dst[:n+nonceSize], nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	nonceSize := aead.NonceSize()
+	if len(ciphertext) < nonceSize+aead.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+	nonce := ciphertext[:nonceSize]
+	sealed := ciphertext[nonceSize:]
+	plaintext, err := aead.Open(sealed[:0], nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,26 @@
+//go:build go1.24
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+)
+
+func newFIPSAEAD(key []byte) ([]byte, cipher.Block, cipher.AEAD, error) {
+	derived, err := hkdf.Key(sha256.New, key, nil, fipsKeyInfo, KeySize)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	aead, err := cipher.NewGCMWithRandomNonce(block)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return derived, block, aead, nil
+}
@@ -0,0 +1,12 @@
+//go:build !go1.24
+
+package crypto
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+func newFIPSAEAD([]byte) ([]byte, cipher.Block, cipher.AEAD, error) {
+	return nil, nil, nil, errors.New("fips mode requires a build with Go 1.24 or later (crypto/hkdf, GCM with random nonces)")
+}
@@ -0,0 +1,12 @@
+//go:build boringcrypto
+
+package crypto
+
+import "crypto/boring"
+
+func fipsModule() string {
+	if boring.Enabled() {
+		return "boringcrypto"
+	}
+	return ""
+}
@@ -0,0 +1,12 @@
+//go:build go1.24 && !boringcrypto
+
+package crypto
+
+import "crypto/fips140"
+
+func fipsModule() string {
+	if fips140.Enabled() {
+		return "go-fips140"
+	}
+	return ""
+}
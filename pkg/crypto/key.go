@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const KeySize = 32
+
+func NewAESCipherFromKey(key []byte) (*AESCipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	key = append([]byte(nil), key...)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return newAESCipher(key, block, nil), nil
+}
+
+func NewGCMCipherFromKey(key []byte) (*AESCipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	key = append([]byte(nil), key...)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return newAESCipher(key, block, aead), nil
+}
+
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		key, err := enc.DecodeString(s)
+		if err != nil {
+			continue
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key must decode to %d bytes, got %d", KeySize, len(key))
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("key is not valid base64")
+}
+
+func LoadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := ParseKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("key file %s is empty", path)
+}
+
+func WriteKeyFile(path string, key []byte) error {
+	data := "# tunnel pre-shared key, keep secret\n" + EncodeKey(key) + "\n"
+	return os.WriteFile(path, []byte(data), 0600)
+}
+
+func NewCipher(password, keyFile string) (*AESCipher, error) {
+	if keyFile == "" {
+		return NewAESCipher(password)
+	}
+	key, err := LoadKeyFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}()
+	return NewAESCipherFromKey(key)
+}
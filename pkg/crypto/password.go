@@ -0,0 +1,111 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	DefaultPassword = "SecureTunnel@2024"
+
+	MinPasswordLength = 12
+	MinPasswordBits   = 60
+
+	DefaultGeneratedLength = 32
+)
+
+var (
+	ErrDefaultPassword = errors.New("password is the built-in default")
+	ErrWeakPassword    = errors.New("password is too weak")
+)
+
+var commonPasswords = []string{
+	"password", "passw0rd", "123456", "qwerty", "admin", "letmein",
+	"welcome", "tunnel", "secret", "changeme", "iloveyou", "abc123",
+}
+
+func CheckPassword(password string) error {
+	if password == DefaultPassword {
+		return ErrDefaultPassword
+	}
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("%w: shorter than %d characters", ErrWeakPassword, MinPasswordLength)
+	}
+
+	lower := strings.ToLower(password)
+	for _, common := range commonPasswords {
+		if strings.Contains(lower, common) && len(password)-len(common) < MinPasswordLength {
+			return fmt.Errorf("%w: based on the common password %q", ErrWeakPassword, common)
+		}
+	}
+
+	distinct := make(map[rune]bool)
+	for _, r := range password {
+		distinct[r] = true
+	}
+	if len(distinct) < MinPasswordLength/2 {
+		return fmt.Errorf("%w: only %d distinct characters", ErrWeakPassword, len(distinct))
+	}
+
+	if bits := PasswordBits(password); bits < MinPasswordBits {
+		return fmt.Errorf("%w: about %.0f bits of entropy, need %d", ErrWeakPassword, bits, MinPasswordBits)
+	}
+	return nil
+}
+
+func PasswordBits(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	length := 0
+	var prev rune
+	for i, r := range password {
+		if d := r - prev; i == 0 || d < -1 || d > 1 {
+			length++
+		}
+		prev = r
+	}
+	return float64(length) * math.Log2(float64(pool))
+}
+
+func GeneratePassword(length int) (string, error) {
+	if length < MinPasswordLength {
+		length = MinPasswordLength
+	}
+	buf := make([]byte, (length*6+7)/8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)[:length], nil
+}
@@ -0,0 +1,69 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"tunnel/pkg/netutil"
+)
+
+type Check func() error
+
+type Server struct {
+	ln  net.Listener
+	srv *http.Server
+}
+
+func Start(addr, tag string, live, ready Check) (*Server, error) {
+	ln, err := netutil.Listen(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen health: %w", err)
+	}
+
+	h := &Server{ln: ln}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handle(live))
+	mux.HandleFunc("/readyz", handle(ready))
+	h.srv = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	log.Printf("[%s] 💓 健康检查已启动: http://%s/healthz, /readyz", tag, ln.Addr())
+	go func() {
+		if err := h.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[%s] ⚠️ 健康检查异常退出: %v", tag, err)
+		}
+	}()
+	return h, nil
+}
+
+func handle(check Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := map[string]string{"status": "ok"}
+		code := http.StatusOK
+		if check != nil {
+			if err := check(); err != nil {
+				status = map[string]string{"status": "unavailable", "reason": err.Error()}
+				code = http.StatusServiceUnavailable
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+func (h *Server) Addr() string {
+	return h.ln.Addr().String()
+}
+
+func (h *Server) Close() error {
+	return h.srv.Close()
+}
@@ -0,0 +1,79 @@
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	KindPassword = "password"
+	KindKey      = "key"
+
+	helperTimeout = 30 * time.Second
+)
+
+var (
+	ErrNotFound    = errors.New("credential not found in os keyring")
+	ErrUnsupported = errors.New("os keyring is not supported on this platform")
+)
+
+func Get(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("keyring name is empty")
+	}
+	secret, err := get(name)
+	if err != nil {
+		return "", fmt.Errorf("keyring %q: %w", name, err)
+	}
+	secret = strings.TrimRight(secret, "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("keyring %q: %w", name, ErrNotFound)
+	}
+	return secret, nil
+}
+
+func ValidKind(kind string) bool {
+	return kind == "" || kind == KindPassword || kind == KindKey
+}
+
+func runHelper(notFound func(code int, stderr string) bool, name string, args ...string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s not found: %w", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), helperTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && notFound(exitErr.ExitCode(), stderr.String()) {
+			return "", ErrNotFound
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
+
+func decodeBlob(blob []byte) string {
+	if len(blob)%2 != 0 || bytes.IndexByte(blob, 0) < 0 {
+		return string(blob)
+	}
+	units := make([]uint16, len(blob)/2)
+	for i := range units {
+		units[i] = uint16(blob[2*i]) | uint16(blob[2*i+1])<<8
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,11 @@
+//go:build darwin && !ios
+
+package keyring
+
+import "strings"
+
+func get(name string) (string, error) {
+	return runHelper(func(code int, stderr string) bool {
+		return code == 44 || strings.Contains(stderr, "could not be found")
+	}, "security", "find-generic-password", "-s", name, "-w")
+}
@@ -0,0 +1,7 @@
+//go:build !windows && !(darwin && !ios) && !((linux && !android) || freebsd || openbsd || netbsd || dragonfly)
+
+package keyring
+
+func get(name string) (string, error) {
+	return "", ErrUnsupported
+}
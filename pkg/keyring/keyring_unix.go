@@ -0,0 +1,9 @@
+//go:build (linux && !android) || freebsd || openbsd || netbsd || dragonfly
+
+package keyring
+
+func get(name string) (string, error) {
+	return runHelper(func(code int, stderr string) bool {
+		return code == 1 && stderr == ""
+	}, "secret-tool", "lookup", "service", name)
+}
@@ -0,0 +1,71 @@
+package logging
+
+import "strings"
+
+type EventType uint16
+
+const (
+	EventError       EventType = 1
+	EventWarning     EventType = 2
+	EventInformation EventType = 4
+)
+
+const (
+	EventIDInfo        uint32 = 1
+	EventIDWarning     uint32 = 2
+	EventIDError       uint32 = 3
+	EventIDStart       uint32 = 100
+	EventIDStop        uint32 = 101
+	EventIDAuthFailure uint32 = 200
+	EventIDACLDeny     uint32 = 201
+)
+
+type eventRule struct {
+	component string
+	contains  string
+	id        uint32
+	kind      EventType
+}
+
+var eventRules = []eventRule{
+	{"", "启动成功", EventIDStart, EventInformation},
+	{"", "正在关闭", EventIDStop, EventInformation},
+	{"", "停止接受新连接", EventIDStop, EventInformation},
+	{"", "已到期，停止服务", EventIDStop, EventWarning},
+	{"", "收到紧急关闭指令", EventIDStop, EventWarning},
+	{"", "握手校验失败", EventIDAuthFailure, EventWarning},
+	{"", "握手失败", EventIDAuthFailure, EventWarning},
+	{"", "令牌错误", EventIDAuthFailure, EventWarning},
+	{"ACL", "拒绝访问", EventIDACLDeny, EventWarning},
+	{"ACL", "封禁 IP", EventIDACLDeny, EventWarning},
+}
+
+func splitPrefix(msg string) (component, instance, text string) {
+	if strings.HasPrefix(msg, "[") {
+		if end := strings.Index(msg, "] "); end > 0 {
+			component, instance, _ = strings.Cut(msg[1:end], ":")
+			return component, instance, msg[end+2:]
+		}
+	}
+	return "", "", msg
+}
+
+func Classify(msg string) (uint32, EventType) {
+	component, _, text := splitPrefix(strings.TrimSpace(msg))
+	for _, rule := range eventRules {
+		if rule.component != "" && rule.component != component {
+			continue
+		}
+		if strings.Contains(text, rule.contains) {
+			return rule.id, rule.kind
+		}
+	}
+
+	switch {
+	case strings.Contains(text, "❌"), strings.Contains(text, "💥"):
+		return EventIDError, EventError
+	case strings.Contains(text, "⚠️"), strings.Contains(text, "🚫"), strings.Contains(text, "⛔"):
+		return EventIDWarning, EventWarning
+	}
+	return EventIDInfo, EventInformation
+}
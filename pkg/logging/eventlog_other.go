@@ -0,0 +1,29 @@
+//go:build !windows
+
+package logging
+
+import "errors"
+
+var errEventLogUnsupported = errors.New("windows event log is only available on windows")
+
+type EventLog struct{}
+
+func OpenEventLog(source string) (*EventLog, error) {
+	return nil, errEventLogUnsupported
+}
+
+func (l *EventLog) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (l *EventLog) Close() error {
+	return nil
+}
+
+func InstallEventLog(source string) error {
+	return errEventLogUnsupported
+}
+
+func RemoveEventLog(source string) error {
+	return errEventLogUnsupported
+}
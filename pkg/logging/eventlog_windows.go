@@ -0,0 +1,135 @@
+//go:build windows
+
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	eventLogKey     = `SYSTEM\CurrentControlSet\Services\EventLog\Application\`
+	eventMessageDLL = `%SystemRoot%\System32\EventCreate.exe`
+
+	eventTypesSupported = uint32(EventError | EventWarning | EventInformation)
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSourceW  = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = advapi32.NewProc("ReportEventW")
+	procRegCreateKeyExW       = advapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueExW        = advapi32.NewProc("RegSetValueExW")
+	procRegDeleteKeyW         = advapi32.NewProc("RegDeleteKeyW")
+)
+
+type EventLog struct {
+	mu     sync.Mutex
+	handle uintptr
+}
+
+func OpenEventLog(source string) (*EventLog, error) {
+	name, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, err
+	}
+	h, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, fmt.Errorf("register event source %s: %w", source, err)
+	}
+	return &EventLog{handle: h}, nil
+}
+
+func (l *EventLog) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	id, kind := Classify(msg)
+	text, err := syscall.UTF16PtrFromString(strings.ReplaceAll(msg, "\x00", ""))
+	if err != nil {
+		return len(p), nil
+	}
+	strs := [1]*uint16{text}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.handle != 0 {
+		procReportEventW.Call(l.handle, uintptr(kind), 0, uintptr(id), 0, 1, 0, uintptr(unsafe.Pointer(&strs[0])), 0)
+	}
+	return len(p), nil
+}
+
+func (l *EventLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.handle == 0 {
+		return nil
+	}
+	r, _, err := procDeregisterEventSource.Call(l.handle)
+	l.handle = 0
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func InstallEventLog(source string) error {
+	path, err := syscall.UTF16PtrFromString(eventLogKey + source)
+	if err != nil {
+		return err
+	}
+	var key syscall.Handle
+	var disposition uint32
+	r, _, _ := procRegCreateKeyExW.Call(uintptr(syscall.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(path)),
+		0, 0, 0, syscall.KEY_SET_VALUE, 0, uintptr(unsafe.Pointer(&key)), uintptr(unsafe.Pointer(&disposition)))
+	if r != 0 {
+		return fmt.Errorf("create registry key for %s: %w", source, syscall.Errno(r))
+	}
+	defer syscall.RegCloseKey(key)
+
+	if err := setStringValue(key, "EventMessageFile", eventMessageDLL); err != nil {
+		return err
+	}
+	if err := setDWordValue(key, "TypesSupported", eventTypesSupported); err != nil {
+		return err
+	}
+	return setDWordValue(key, "CustomSource", 1)
+}
+
+func RemoveEventLog(source string) error {
+	path, err := syscall.UTF16PtrFromString(eventLogKey + source)
+	if err != nil {
+		return err
+	}
+	r, _, _ := procRegDeleteKeyW.Call(uintptr(syscall.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(path)))
+	if r != 0 {
+		return fmt.Errorf("delete registry key for %s: %w", source, syscall.Errno(r))
+	}
+	return nil
+}
+
+func setStringValue(key syscall.Handle, name, value string) error {
+	data, err := syscall.UTF16FromString(value)
+	if err != nil {
+		return err
+	}
+	return setValue(key, name, syscall.REG_EXPAND_SZ, (*byte)(unsafe.Pointer(&data[0])), uint32(len(data)*2))
+}
+
+func setDWordValue(key syscall.Handle, name string, value uint32) error {
+	return setValue(key, name, syscall.REG_DWORD, (*byte)(unsafe.Pointer(&value)), 4)
+}
+
+func setValue(key syscall.Handle, name string, kind uint32, data *byte, size uint32) error {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	r, _, _ := procRegSetValueExW.Call(uintptr(key), uintptr(unsafe.Pointer(p)), 0, uintptr(kind), uintptr(unsafe.Pointer(data)), uintptr(size))
+	if r != 0 {
+		return fmt.Errorf("set registry value %s: %w", name, syscall.Errno(r))
+	}
+	return nil
+}
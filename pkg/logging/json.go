@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const containerEnv = "RUNNING_IN_CONTAINER"
+
+func InContainer() bool {
+	if v := os.Getenv(containerEnv); v != "" && v != "0" && v != "false" {
+		return true
+	}
+	_, err := os.Stat("/.dockerenv")
+	return err == nil
+}
+
+type entry struct {
+	Time      string `json:"time"`
+	Component string `json:"component,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Message   string `json:"msg"`
+}
+
+type JSONWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewJSONWriter(out io.Writer) *JSONWriter {
+	return &JSONWriter{out: out}
+}
+
+func (w *JSONWriter) Write(p []byte) (int, error) {
+	e := entry{Time: time.Now().Format(time.RFC3339Nano)}
+	e.Component, e.Instance, e.Message = splitPrefix(strings.TrimSpace(string(p)))
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.out.Write(append(data, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
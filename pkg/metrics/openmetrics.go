@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+)
+
+type Family struct {
+	Name    string
+	Type    string
+	Help    string
+	Label   string
+	Samples map[string]float64
+}
+
+func Counter(name, help string, value int64) Family {
+	return Family{Name: name, Type: TypeCounter, Help: help, Samples: map[string]float64{"": float64(value)}}
+}
+
+func Gauge(name, help string, value int64) Family {
+	return Family{Name: name, Type: TypeGauge, Help: help, Samples: map[string]float64{"": float64(value)}}
+}
+
+func LabeledCounter(name, help, label string, values map[string]int64) Family {
+	samples := make(map[string]float64, len(values))
+	for k, v := range values {
+		samples[k] = float64(v)
+	}
+	return Family{Name: name, Type: TypeCounter, Help: help, Label: label, Samples: samples}
+}
+
+func WriteOpenMetrics(w io.Writer, prefix string, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		name := f.Name
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		bw.WriteString("# TYPE " + name + " " + f.Type + "\n")
+		bw.WriteString("# HELP " + name + " " + escapeHelp(f.Help) + "\n")
+
+		sample := name
+		if f.Type == TypeCounter {
+			sample += "_total"
+		}
+		keys := make([]string, 0, len(f.Samples))
+		for k := range f.Samples {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			bw.WriteString(sample)
+			if f.Label != "" {
+				bw.WriteString("{" + f.Label + "=\"" + escapeLabel(k) + "\"}")
+			}
+			bw.WriteString(" " + strconv.FormatFloat(f.Samples[k], 'g', -1, 64) + "\n")
+		}
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProtocolStatsD = "statsd"
+	ProtocolInflux = "influx"
+)
+
+type PushConfig struct {
+	Enable   bool
+	Protocol string
+	Address  string
+	Network  string
+	Interval time.Duration
+	Prefix   string
+}
+
+func DefaultPushConfig() PushConfig {
+	return PushConfig{
+		Protocol: ProtocolStatsD,
+		Network:  "udp",
+		Interval: 10 * time.Second,
+		Prefix:   "tunnel",
+	}
+}
+
+type Pusher struct {
+	config PushConfig
+	source func() map[string]interface{}
+	host   string
+	client *http.Client
+
+	done chan struct{}
+	once sync.Once
+}
+
+func NewPusher(config PushConfig, source func() map[string]interface{}) (*Pusher, error) {
+	defaults := DefaultPushConfig()
+	if config.Protocol == "" {
+		config.Protocol = defaults.Protocol
+	}
+	if config.Network == "" {
+		config.Network = defaults.Network
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.Prefix == "" {
+		config.Prefix = defaults.Prefix
+	}
+
+	if config.Protocol != ProtocolStatsD && config.Protocol != ProtocolInflux {
+		return nil, fmt.Errorf("unknown metrics protocol: %s", config.Protocol)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("metrics push address is required")
+	}
+
+	host, _ := os.Hostname()
+
+	return &Pusher{
+		config: config,
+		source: source,
+		host:   host,
+		client: &http.Client{Timeout: 10 * time.Second},
+		done:   make(chan struct{}),
+	}, nil
+}
+
+func (p *Pusher) Start() {
+	log.Printf("[Metrics] 📤 指标推送已启用: %s -> %s (每 %v)", p.config.Protocol, p.config.Address, p.config.Interval)
+
+	go func() {
+		ticker := time.NewTicker(p.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				if err := p.Push(); err != nil {
+					log.Printf("[Metrics] ⚠️ 推送指标失败: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (p *Pusher) Stop() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+}
+
+func (p *Pusher) Push() error {
+	values := make(map[string]float64)
+	flatten("", p.source(), values)
+
+	var payload []byte
+	switch p.config.Protocol {
+	case ProtocolInflux:
+		payload = p.encodeInflux(values, time.Now())
+	default:
+		payload = p.encodeStatsD(values)
+	}
+
+	return p.send(payload)
+}
+
+func (p *Pusher) encodeStatsD(values map[string]float64) []byte {
+	var buf bytes.Buffer
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(&buf, "%s.%s:%g|g\n", p.config.Prefix, key, values[key])
+	}
+	return buf.Bytes()
+}
+
+func (p *Pusher) encodeInflux(values map[string]float64, now time.Time) []byte {
+	fields := make([]string, 0, len(values))
+	for _, key := range sortedKeys(values) {
+		fields = append(fields, fmt.Sprintf("%s=%g", key, values[key]))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(p.config.Prefix)
+	if p.host != "" {
+		buf.WriteString(",host=")
+		buf.WriteString(escapeInfluxTag(p.host))
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(strings.Join(fields, ","))
+	fmt.Fprintf(&buf, " %d\n", now.UnixNano())
+	return buf.Bytes()
+}
+
+func (p *Pusher) send(payload []byte) error {
+	if strings.HasPrefix(p.config.Address, "http://") || strings.HasPrefix(p.config.Address, "https://") {
+		resp, err := p.client.Post(p.config.Address, "text/plain; charset=utf-8", bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("metrics endpoint returned %s", resp.Status)
+		}
+		return nil
+	}
+
+	conn, err := net.DialTimeout(p.config.Network, p.config.Address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write(payload)
+	return err
+}
+
+func flatten(prefix string, in map[string]interface{}, out map[string]float64) {
+	for key, value := range in {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(name, v, out)
+		case int:
+			out[name] = float64(v)
+		case int64:
+			out[name] = float64(v)
+		case uint64:
+			out[name] = float64(v)
+		case float64:
+			out[name] = v
+		case bool:
+			if v {
+				out[name] = 1
+			} else {
+				out[name] = 0
+			}
+		}
+	}
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeInfluxTag(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
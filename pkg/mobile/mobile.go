@@ -0,0 +1,105 @@
+package mobile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"tunnel/pkg/client"
+	"tunnel/pkg/config"
+	"tunnel/pkg/crypto"
+)
+
+var (
+	mu      sync.Mutex
+	current *client.Client
+	stop    context.CancelFunc
+)
+
+func StartClient(configJSON string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		return errors.New("client is already running")
+	}
+
+	var cc config.ClientConfig
+	if err := json.Unmarshal([]byte(configJSON), &cc); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if cc.Listen == "" {
+		return errors.New("listen is required")
+	}
+	if cc.Server == "" {
+		return errors.New("server is required")
+	}
+
+	cfg, err := client.FromConfig(cc)
+	if err != nil {
+		return err
+	}
+	if cfg.KeyFile == "" && len(cfg.Key) == 0 && !cc.InsecureAllowDefault {
+		if err := crypto.CheckPassword(cfg.Password); err != nil {
+			return err
+		}
+	}
+
+	cli, err := client.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cli.Start(ctx)
+	}()
+
+	select {
+	case <-cli.Ready():
+	case err := <-errc:
+		cancel()
+		if err == nil {
+			err = errors.New("client stopped during startup")
+		}
+		return err
+	}
+
+	current, stop = cli, cancel
+	return nil
+}
+
+func Stop() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		return nil
+	}
+	stop()
+	err := current.Stop()
+	current, stop = nil, nil
+	return err
+}
+
+func Running() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return current != nil
+}
+
+func Stats() string {
+	mu.Lock()
+	cli := current
+	mu.Unlock()
+	if cli == nil {
+		return "{}"
+	}
+
+	data, err := json.Marshal(cli.Stats())
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
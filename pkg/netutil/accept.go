@@ -0,0 +1,141 @@
+package netutil
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+type Backoff struct {
+	delay time.Duration
+}
+
+func (b *Backoff) Next() time.Duration {
+	if b.delay == 0 {
+		b.delay = minAcceptDelay
+	} else {
+		b.delay *= 2
+	}
+	if b.delay > maxAcceptDelay {
+		b.delay = maxAcceptDelay
+	}
+	return b.delay
+}
+
+func (b *Backoff) Reset() {
+	b.delay = 0
+}
+
+func IsFDExhausted(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
+
+func IsClosed(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)
+}
+
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func HandleAcceptError(tag string, err error, backoff *Backoff) {
+	delay := backoff.Next()
+	if IsFDExhausted(err) {
+		log.Printf("[%s] ⚠️ 文件描述符耗尽，%v 后重试 (请提高 ulimit -n 或降低最大连接数): %v", tag, delay, err)
+	} else {
+		log.Printf("[%s] ⚠️ Accept 错误，%v 后重试: %v", tag, delay, err)
+	}
+	time.Sleep(delay)
+}
+
+type LimitListener struct {
+	net.Listener
+	limit *connLimit
+}
+
+type connLimit struct {
+	tag      string
+	max      int64
+	open     atomic.Int64
+	rejected atomic.Int64
+}
+
+func NewLimitListener(ln net.Listener, max int, tag string) *LimitListener {
+	return &LimitListener{
+		Listener: ln,
+		limit:    &connLimit{tag: tag, max: int64(max)},
+	}
+}
+
+func (l *LimitListener) Share(ln net.Listener) *LimitListener {
+	return &LimitListener{Listener: ln, limit: l.limit}
+}
+
+func (l *LimitListener) Accept() (net.Conn, error) {
+	limit := l.limit
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if !limit.acquire() {
+			limit.rejected.Add(1)
+			log.Printf("[%s] ⚠️ 已达最大连接数 %d，拒绝连接: %s", limit.tag, limit.max, conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		return &limitConn{Conn: conn, release: func() { limit.open.Add(-1) }}, nil
+	}
+}
+
+func (c *connLimit) acquire() bool {
+	for {
+		open := c.open.Load()
+		if c.max > 0 && open >= c.max {
+			return false
+		}
+		if c.open.CompareAndSwap(open, open+1) {
+			return true
+		}
+	}
+}
+
+func (l *LimitListener) Open() int64 {
+	return l.limit.open.Load()
+}
+
+func (l *LimitListener) Rejected() int64 {
+	return l.limit.rejected.Load()
+}
+
+func (l *LimitListener) Max() int64 {
+	return l.limit.max
+}
+
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitConn) Unwrap() net.Conn {
+	return c.Conn
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
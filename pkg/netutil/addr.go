@@ -0,0 +1,74 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+func NormalizeAddr(addr string) (string, error) {
+	addr = strings.TrimSpace(addr)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", addrError(addr)
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", fmt.Errorf("invalid port in address %q", addr)
+	}
+
+	if strings.HasPrefix(addr, "[") {
+		ip := parseIPZone(host)
+		if ip == "" {
+			return "", fmt.Errorf("invalid IPv6 literal in address %q", addr)
+		}
+		host = ip
+	} else if ip := parseIPZone(host); ip != "" {
+		host = ip
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func NormalizeIP(s string) string {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	if ip := parseIPZone(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")); ip != "" {
+		return ip
+	}
+	return s
+}
+
+func WithDefaultPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	return net.JoinHostPort(host, port)
+}
+
+func addrError(addr string) error {
+	switch {
+	case addr == "":
+		return fmt.Errorf("address is empty")
+	case strings.Count(addr, ":") > 1 && !strings.HasPrefix(addr, "["):
+		return fmt.Errorf("ambiguous address %q: IPv6 literals must be bracketed, e.g. [2001:db8::1]:443", addr)
+	case !strings.Contains(addr, ":") || strings.HasSuffix(addr, "]"):
+		return fmt.Errorf("address %q is missing a port", addr)
+	}
+	return fmt.Errorf("invalid address %q, expected host:port", addr)
+}
+
+func parseIPZone(host string) string {
+	ip, zone, _ := strings.Cut(host, "%")
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if zone != "" {
+		return parsed.String() + "%" + zone
+	}
+	return parsed.String()
+}
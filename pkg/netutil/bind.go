@@ -0,0 +1,68 @@
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+func Listen(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, ExplainListenError(addr, err)
+	}
+	return ln, nil
+}
+
+func CheckListen(addrs ...string) error {
+	seen := make(map[string]bool)
+	for _, addr := range addrs {
+		if addr == "" {
+			continue
+		}
+		normalized, err := NormalizeAddr(addr)
+		if err != nil {
+			return fmt.Errorf("invalid listen address: %w", err)
+		}
+		if _, port, _ := net.SplitHostPort(normalized); port != "0" {
+			if seen[normalized] {
+				return fmt.Errorf("listen address %s is configured more than once", addr)
+			}
+			seen[normalized] = true
+		}
+
+		ln, err := Listen(addr)
+		if err != nil {
+			return err
+		}
+		ln.Close()
+	}
+	return nil
+}
+
+func ExplainListenError(addr string, err error) error {
+	_, portStr, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portStr)
+
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		if owner := listenerOwner(port); owner != "" {
+			return fmt.Errorf("address %s is already in use by %s", addr, owner)
+		}
+		return fmt.Errorf("address %s is already in use by another process", addr)
+	case errors.Is(err, syscall.EACCES) && port > 0 && port < 1024:
+		return fmt.Errorf("permission denied binding %s: ports below 1024 require root or CAP_NET_BIND_SERVICE "+
+			"(e.g. setcap cap_net_bind_service=+ep <binary>)", addr)
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		return fmt.Errorf("address %s is not assigned to any local interface", addr)
+	}
+
+	var dnsErr *net.DNSError
+	var addrErr *net.AddrError
+	if errors.As(err, &dnsErr) || errors.As(err, &addrErr) {
+		return fmt.Errorf("invalid listen address %s: %w", addr, err)
+	}
+	return err
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package netutil
+
+func listenerOwner(port int) string {
+	return ""
+}
@@ -0,0 +1,15 @@
+package netutil
+
+import (
+	"context"
+	"io"
+)
+
+func CloseOnDone(ctx context.Context, conn io.Closer) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,68 @@
+package netutil
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultWriteTimeout = 30 * time.Second
+
+	idleRearmDivisor = 4
+)
+
+type ReadDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+type WriteDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+func (t Timeouts) ArmWrite(conn WriteDeadliner) {
+	if t.Write > 0 {
+		conn.SetWriteDeadline(time.Now().Add(t.Write))
+	}
+}
+
+type Idle struct {
+	timeout time.Duration
+	conns   []ReadDeadliner
+	armed   atomic.Int64
+}
+
+func (t Timeouts) Idle(conns ...ReadDeadliner) *Idle {
+	if t.Read <= 0 {
+		return nil
+	}
+	i := &Idle{timeout: t.Read, conns: conns}
+	i.arm(time.Now())
+	return i
+}
+
+func (i *Idle) Touch() {
+	if i == nil {
+		return
+	}
+	now := time.Now()
+	last := i.armed.Load()
+	if now.UnixNano()-last < int64(i.timeout/idleRearmDivisor) {
+		return
+	}
+	if i.armed.CompareAndSwap(last, now.UnixNano()) {
+		i.arm(now)
+	}
+}
+
+func (i *Idle) arm(now time.Time) {
+	i.armed.Store(now.UnixNano())
+	deadline := now.Add(i.timeout)
+	for _, conn := range i.conns {
+		conn.SetReadDeadline(deadline)
+	}
+}
@@ -0,0 +1,44 @@
+package netutil
+
+import (
+	"fmt"
+	"syscall"
+)
+
+type SocketMark struct {
+	DSCP int
+	Mark int
+}
+
+func (m SocketMark) Enabled() bool {
+	return m.DSCP != 0 || m.Mark != 0
+}
+
+func (m SocketMark) Validate() error {
+	if m.DSCP < 0 || m.DSCP > 63 {
+		return fmt.Errorf("dscp must be between 0 and 63")
+	}
+	if m.Mark < 0 {
+		return fmt.Errorf("fwmark must not be negative")
+	}
+	if m.Enabled() && !markSupported {
+		return fmt.Errorf("dscp and fwmark are only supported on linux")
+	}
+	return nil
+}
+
+func (m SocketMark) Control() func(network, address string, c syscall.RawConn) error {
+	if !m.Enabled() {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var opErr error
+		err := c.Control(func(fd uintptr) {
+			opErr = m.apply(network, fd)
+		})
+		if err != nil {
+			return err
+		}
+		return opErr
+	}
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package netutil
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+const markSupported = true
+
+func (m SocketMark) apply(network string, fd uintptr) error {
+	if m.DSCP != 0 {
+		tos := m.DSCP << 2
+		err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+		if network == "tcp6" || network == "udp6" {
+			err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+		}
+		if err != nil {
+			return fmt.Errorf("set dscp: %w", os.NewSyscallError("setsockopt", err))
+		}
+	}
+	if m.Mark != 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, m.Mark); err != nil {
+			return fmt.Errorf("set fwmark: %w", os.NewSyscallError("setsockopt", err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package netutil
+
+const markSupported = false
+
+func (m SocketMark) apply(network string, fd uintptr) error {
+	return nil
+}
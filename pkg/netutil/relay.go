@@ -0,0 +1,100 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const (
+	RelayGoroutine = "goroutine"
+	RelayPooled    = "pooled"
+
+	relayBufferSize = 32 * 1024
+)
+
+var relayBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, relayBufferSize)
+		return &buf
+	},
+}
+
+var relayPressure atomic.Bool
+
+func SetRelayPressure(on bool) {
+	relayPressure.Store(on)
+}
+
+type Unwrapper interface {
+	Unwrap() net.Conn
+}
+
+type RelayReader struct {
+	conn   net.Conn
+	raw    syscall.RawConn
+	pooled bool
+	buf    *[]byte
+	full   bool
+}
+
+func ValidateRelayEngine(engine string) error {
+	switch engine {
+	case "", RelayGoroutine, RelayPooled:
+		return nil
+	}
+	return fmt.Errorf("unknown relay engine: %s", engine)
+}
+
+func NewRelayReader(conn net.Conn, engine string) *RelayReader {
+	return &RelayReader{conn: conn, raw: rawConn(conn), pooled: engine == RelayPooled}
+}
+
+func (r *RelayReader) Next() ([]byte, error) {
+	if r.raw != nil && !r.full && (r.pooled || relayPressure.Load()) {
+		r.release()
+		if err := waitReadable(r.raw); err != nil {
+			return nil, err
+		}
+	}
+	if r.buf == nil {
+		r.buf = relayBuffers.Get().(*[]byte)
+	}
+
+	n, err := r.conn.Read(*r.buf)
+	r.full = n == len(*r.buf)
+	if err != nil {
+		return nil, err
+	}
+	return (*r.buf)[:n], nil
+}
+
+func (r *RelayReader) Close() {
+	r.release()
+}
+
+func (r *RelayReader) release() {
+	if r.buf != nil {
+		relayBuffers.Put(r.buf)
+		r.buf = nil
+	}
+}
+
+func rawConn(conn net.Conn) syscall.RawConn {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			raw, err := c.SyscallConn()
+			if err != nil || !readinessSupported {
+				return nil
+			}
+			return raw
+		case Unwrapper:
+			conn = c.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package netutil
+
+import (
+	"errors"
+	"syscall"
+)
+
+const readinessSupported = false
+
+func waitReadable(raw syscall.RawConn) error {
+	return errors.New("readiness wait is not supported on this platform")
+}
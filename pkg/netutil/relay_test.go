@@ -0,0 +1,76 @@
+package netutil
+
+import (
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+const idleRelayConns = 512
+
+func BenchmarkRelayIdle(b *testing.B) {
+	for _, engine := range []string{RelayGoroutine, RelayPooled} {
+		b.Run(engine, func(b *testing.B) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer ln.Close()
+
+			var total float64
+			for i := 0; i < b.N; i++ {
+				total += idleRelayBytes(b, ln, engine)
+			}
+			b.ReportMetric(total/float64(b.N), "bytes/conn")
+		})
+	}
+}
+
+func idleRelayBytes(b *testing.B, ln net.Listener, engine string) float64 {
+	b.Helper()
+	before := relayMemory()
+
+	conns := make([]net.Conn, 0, 2*idleRelayConns)
+	var wg sync.WaitGroup
+	for i := 0; i < idleRelayConns; i++ {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			b.Fatal(err)
+		}
+		server, err := ln.Accept()
+		if err != nil {
+			b.Fatal(err)
+		}
+		conns = append(conns, client, server)
+
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+			r := NewRelayReader(conn, engine)
+			defer r.Close()
+			for {
+				if _, err := r.Next(); err != nil {
+					return
+				}
+			}
+		}(server)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	after := relayMemory()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	wg.Wait()
+	return float64(after-before) / idleRelayConns
+}
+
+func relayMemory() uint64 {
+	runtime.GC()
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapInuse + m.StackInuse
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package netutil
+
+import (
+	"syscall"
+)
+
+const readinessSupported = true
+
+func waitReadable(raw syscall.RawConn) error {
+	var peek [1]byte
+	return raw.Read(func(fd uintptr) bool {
+		_, _, err := syscall.Recvfrom(int(fd), peek[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		return err != syscall.EAGAIN
+	})
+}
@@ -0,0 +1,41 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package netutil
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+)
+
+const soReusePort = 0xf
+
+func ListenReusePort(addr string, n int) ([]net.Listener, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var opErr error
+			err := c.Control(func(fd uintptr) {
+				opErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+			})
+			if err != nil {
+				return err
+			}
+			return os.NewSyscallError("setsockopt", opErr)
+		},
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		ln, err := lc.Listen(context.Background(), "tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+		addr = ln.Addr().String()
+	}
+	return listeners, nil
+}
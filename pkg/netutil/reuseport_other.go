@@ -0,0 +1,12 @@
+//go:build !linux || mips || mipsle || mips64 || mips64le
+
+package netutil
+
+import (
+	"errors"
+	"net"
+)
+
+func ListenReusePort(addr string, n int) ([]net.Listener, error) {
+	return nil, errors.New("SO_REUSEPORT listener shards are only supported on linux")
+}
@@ -0,0 +1,122 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Enable   bool
+	Path     string
+	MaxBytes int
+}
+
+type Record struct {
+	Time      time.Time         `json:"time"`
+	Remote    string            `json:"remote"`
+	Reason    string            `json:"reason"`
+	ElapsedMS int64             `json:"elapsed_ms,omitempty"`
+	Method    string            `json:"method,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Payload   []byte            `json:"payload,omitempty"`
+}
+
+type Logger struct {
+	mu       sync.Mutex
+	file     *os.File
+	maxBytes int
+}
+
+func New(cfg Config) (*Logger, error) {
+	if !cfg.Enable {
+		return &Logger{}, nil
+	}
+
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("probe log path is required")
+	}
+
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open probe log: %w", err)
+	}
+
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 256
+	}
+
+	return &Logger{
+		file:     file,
+		maxBytes: maxBytes,
+	}, nil
+}
+
+func (l *Logger) Enabled() bool {
+	return l != nil && l.file != nil
+}
+
+func (l *Logger) MaxBytes() int {
+	if !l.Enabled() {
+		return 0
+	}
+	return l.maxBytes
+}
+
+func (l *Logger) Log(rec Record) {
+	if !l.Enabled() {
+		return
+	}
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	if len(rec.Payload) > l.maxBytes {
+		rec.Payload = rec.Payload[:l.maxBytes]
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.file.Write(append(data, '\n'))
+}
+
+func (l *Logger) LogRequest(remote, reason string, r *http.Request) {
+	if !l.Enabled() {
+		return
+	}
+
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		if len(values) > 0 {
+			headers[name] = values[0]
+		}
+	}
+	if r.Host != "" {
+		headers["Host"] = r.Host
+	}
+
+	l.Log(Record{
+		Remote:  remote,
+		Reason:  reason,
+		Method:  r.Method,
+		Path:    r.URL.RequestURI(),
+		Headers: headers,
+	})
+}
+
+func (l *Logger) Close() error {
+	if !l.Enabled() {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,437 @@
+package proto
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"tunnel/pkg/crypto"
+	"tunnel/pkg/transport"
+)
+
+const (
+	TransportTCP  = "tcp"
+	TransportTLS  = "tls"
+	TransportWS   = "ws"
+	TransportWSS  = "wss"
+	TransportPoll = "poll"
+
+	RejectTarget = "reject.conformance.invalid:1"
+
+	defaultCheckTimeout = 10 * time.Second
+)
+
+type frameConn interface {
+	ReadEncrypted() ([]byte, error)
+	WriteEncrypted(data []byte) error
+	Close() error
+}
+
+type CheckConfig struct {
+	Server     string
+	Password   string
+	Transport  string
+	WSPath     string
+	SkipVerify bool
+	FrameDebug bool
+	Target     string
+	Timeout    time.Duration
+}
+
+type Result struct {
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	Detail   string        `json:"detail,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+type checker struct {
+	config CheckConfig
+	cipher *crypto.AESCipher
+}
+
+func Check(ctx context.Context, config CheckConfig) ([]Result, error) {
+	if config.Transport == "" {
+		config.Transport = TransportTCP
+	}
+	switch config.Transport {
+	case TransportTCP, TransportTLS, TransportWS, TransportWSS, TransportPoll:
+	default:
+		return nil, fmt.Errorf("unknown transport %q (tcp, tls, ws, wss, poll)", config.Transport)
+	}
+	if config.WSPath == "" {
+		config.WSPath = transport.DefaultWSConfig().Path
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultCheckTimeout
+	}
+
+	cipher, err := crypto.NewAESCipher(config.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Target == "" {
+		echo, err := startEcho()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start echo target: %w", err)
+		}
+		defer echo.Close()
+		config.Target = echo.Addr().String()
+	}
+
+	c := &checker{config: config, cipher: cipher}
+	steps := []struct {
+		name string
+		run  func(ctx context.Context) error
+	}{
+		{"handshake", c.handshake},
+		{"echo-small", c.echo(16, 1)},
+		{"echo-multi-frame", c.echo(1024, 64)},
+		{"echo-large-frame", c.echo(crypto.MaxFrameLength/4, 1)},
+		{"reject-unresolvable", c.reject},
+		{"wrong-key", c.wrongKey},
+	}
+
+	var results []Result
+	for _, step := range steps {
+		stepCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+		start := time.Now()
+		err := step.run(stepCtx)
+		cancel()
+
+		result := Result{Name: step.name, Passed: err == nil, Duration: time.Since(start)}
+		if err != nil {
+			result.Detail = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (c *checker) dial(ctx context.Context, cipher *crypto.AESCipher) (frameConn, error) {
+	cfg := c.config
+	var conn net.Conn
+	var err error
+
+	switch cfg.Transport {
+	case TransportWS, TransportWSS:
+		wsConfig := transport.DefaultWSConfig()
+		wsConfig.Path = cfg.WSPath
+		wsConfig.EnableTLS = cfg.Transport == TransportWSS
+		wsConfig.SkipVerify = cfg.SkipVerify
+		ws, err := transport.NewWSClient(wsConfig, cipher).Connect(ctx, cfg.Server)
+		if err != nil {
+			return nil, err
+		}
+		return ws, nil
+	case TransportPoll:
+		wsConfig := transport.DefaultWSConfig()
+		wsConfig.Path = cfg.WSPath
+		conn, err = transport.NewPollClient(wsConfig).Dial(ctx, cfg.Server)
+	case TransportTLS:
+		dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: cfg.SkipVerify}}
+		conn, err = dialer.DialContext(ctx, "tcp", cfg.Server)
+	default:
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", cfg.Server)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	cryptoConn := crypto.NewCryptoConn(conn, cipher)
+	cryptoConn.SetDebug(cfg.FrameDebug)
+	return cryptoConn, nil
+}
+
+func (c *checker) open(ctx context.Context, target string) (frameConn, string, error) {
+	conn, err := c.dial(ctx, c.cipher)
+	if err != nil {
+		return nil, "", fmt.Errorf("dial: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := conn.WriteEncrypted([]byte(target)); err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("send handshake: %w", err)
+	}
+	reply, err := conn.ReadEncrypted()
+	if err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("read handshake reply: %w", err)
+	}
+	return conn, string(reply), nil
+}
+
+func (c *checker) handshake(ctx context.Context) error {
+	conn, reply, err := c.open(ctx, c.config.Target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if reply != ReplyOK {
+		return fmt.Errorf("expected reply %q, got %q", ReplyOK, reply)
+	}
+	return nil
+}
+
+func (c *checker) echo(size, frames int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		conn, reply, err := c.open(ctx, c.config.Target)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if reply != ReplyOK {
+			return fmt.Errorf("expected reply %q, got %q", ReplyOK, reply)
+		}
+
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		defer stop()
+
+		sent := make([]byte, size*frames)
+		if _, err := rand.Read(sent); err != nil {
+			return err
+		}
+
+		writeErr := make(chan error, 1)
+		go func() {
+			for i := 0; i < frames; i++ {
+				if err := conn.WriteEncrypted(sent[i*size : (i+1)*size]); err != nil {
+					writeErr <- fmt.Errorf("write frame %d: %w", i, err)
+					return
+				}
+			}
+			writeErr <- nil
+		}()
+
+		received := make([]byte, 0, len(sent))
+		for len(received) < len(sent) {
+			data, err := conn.ReadEncrypted()
+			if err != nil {
+				return fmt.Errorf("read after %d/%d bytes: %w", len(received), len(sent), err)
+			}
+			received = append(received, data...)
+		}
+		if err := <-writeErr; err != nil {
+			return err
+		}
+		if !bytes.Equal(received, sent) {
+			return fmt.Errorf("echoed data differs from sent data (%d bytes)", len(sent))
+		}
+		return nil
+	}
+}
+
+func (c *checker) reject(ctx context.Context) error {
+	conn, reply, err := c.open(ctx, RejectTarget)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !strings.HasPrefix(reply, ReplyErrorPrefix) {
+		return fmt.Errorf("expected %q reply for %s, got %q", ReplyErrorPrefix+"<reason>", RejectTarget, reply)
+	}
+	return nil
+}
+
+func (c *checker) wrongKey(ctx context.Context) error {
+	secret := make([]byte, 16)
+	rand.Read(secret)
+	cipher, err := crypto.NewAESCipher(fmt.Sprintf("%x", secret))
+	if err != nil {
+		return err
+	}
+
+	conn, err := c.dial(ctx, cipher)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := conn.WriteEncrypted([]byte(c.config.Target)); err != nil {
+		return nil
+	}
+	reply, err := conn.ReadEncrypted()
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("server kept the connection open after an undecryptable handshake")
+		}
+		return nil
+	}
+	if string(reply) == ReplyOK {
+		return fmt.Errorf("server accepted a handshake encrypted with the wrong key")
+	}
+	return nil
+}
+
+func startEcho() (net.Listener, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln, nil
+}
+
+type ServeConfig struct {
+	Listen     string
+	Password   string
+	Transport  string
+	WSPath     string
+	CertFile   string
+	KeyFile    string
+	FrameDebug bool
+}
+
+func Serve(ctx context.Context, config ServeConfig) error {
+	cipher, err := crypto.NewAESCipher(config.Password)
+	if err != nil {
+		return err
+	}
+	if config.WSPath == "" {
+		config.WSPath = transport.DefaultWSConfig().Path
+	}
+
+	switch config.Transport {
+	case "", TransportTCP, TransportTLS:
+		var ln net.Listener
+		if config.Transport == TransportTLS {
+			cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+			if err != nil {
+				return fmt.Errorf("failed to load certificate: %w", err)
+			}
+			ln, err = tls.Listen("tcp", config.Listen, &tls.Config{Certificates: []tls.Certificate{cert}})
+			if err != nil {
+				return err
+			}
+		} else {
+			ln, err = net.Listen("tcp", config.Listen)
+			if err != nil {
+				return err
+			}
+		}
+		context.AfterFunc(ctx, func() { ln.Close() })
+		log.Printf("[Proto] 🧪 一致性测试 Server 已启动: %s (%s)", ln.Addr(), config.Transport)
+
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			go func() {
+				cryptoConn := crypto.NewCryptoConn(conn, cipher)
+				cryptoConn.SetDebug(config.FrameDebug)
+				serveConformance(cryptoConn, conn.RemoteAddr().String())
+			}()
+		}
+
+	case TransportWS, TransportWSS, TransportPoll:
+		wsConfig := transport.DefaultWSConfig()
+		wsConfig.Path = config.WSPath
+		wsConfig.EnableTLS = config.Transport == TransportWSS
+		wsConfig.TLSCert = config.CertFile
+		wsConfig.TLSKey = config.KeyFile
+
+		server := transport.NewWSServer(wsConfig, cipher, func(ws *transport.WSConn) {
+			serveConformance(ws, ws.RemoteAddr().String())
+		})
+		server.SetPollHandler(func(conn net.Conn) {
+			cryptoConn := crypto.NewCryptoConn(conn, cipher)
+			cryptoConn.SetDebug(config.FrameDebug)
+			serveConformance(cryptoConn, conn.RemoteAddr().String())
+		})
+
+		errCh := make(chan error, 1)
+		go func() { errCh <- server.Start(config.Listen) }()
+		select {
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown transport %q (tcp, tls, ws, wss, poll)", config.Transport)
+}
+
+func serveConformance(conn frameConn, peer string) {
+	defer conn.Close()
+
+	first, err := conn.ReadEncrypted()
+	if err != nil {
+		log.Printf("[Proto] ❌ %s 握手帧无效: %v", peer, err)
+		return
+	}
+
+	target := string(first)
+	switch {
+	case target == TargetControl || target == TargetUDPAssociate:
+		log.Printf("[Proto] ⚠️ %s 请求 %s，一致性测试 Server 不支持", peer, target)
+		conn.WriteEncrypted([]byte(ReplyErrorPrefix + "not supported by conformance server"))
+		return
+	case target == RejectTarget:
+		log.Printf("[Proto] ✅ %s 握手正确，按约定拒绝 %s", peer, target)
+		conn.WriteEncrypted([]byte(ReplyErrorPrefix + "rejected by conformance server"))
+		return
+	case target != TargetDefault:
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			log.Printf("[Proto] ❌ %s 握手目标格式错误: %q", peer, target)
+			return
+		}
+	}
+
+	if err := conn.WriteEncrypted([]byte(ReplyOK)); err != nil {
+		return
+	}
+	log.Printf("[Proto] ✅ %s 握手正确 (目标 %s)，开始回显", peer, target)
+
+	var frames, total int
+	for {
+		data, err := conn.ReadEncrypted()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !transport.IsNormalClose(err) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("[Proto] ❌ %s 第 %d 帧读取失败: %v", peer, frames, err)
+			}
+			break
+		}
+		frames++
+		total += len(data)
+		if err := conn.WriteEncrypted(data); err != nil {
+			log.Printf("[Proto] ❌ %s 回显失败: %v", peer, err)
+			break
+		}
+	}
+	log.Printf("[Proto] 🔌 %s 已断开，共回显 %d 帧 / %d 字节", peer, frames, total)
+}
@@ -0,0 +1,160 @@
+package proto
+
+import (
+	"crypto/aes"
+
+	"tunnel/pkg/crypto"
+)
+
+const (
+	Version = 1
+
+	TargetDefault      = "USE_DEFAULT"
+	TargetUDPAssociate = "UDP_ASSOCIATE"
+	TargetControl      = "CONTROL"
+
+	ReplyOK          = "OK"
+	ReplyErrorPrefix = "ERROR:"
+)
+
+type Field struct {
+	Name        string `json:"name"`
+	Size        string `json:"size"`
+	Encoding    string `json:"encoding,omitempty"`
+	Description string `json:"description"`
+}
+
+type Cipher struct {
+	Algorithm     string `json:"algorithm"`
+	KeySize       int    `json:"key_size"`
+	KeyDerivation string `json:"key_derivation"`
+	IVSize        int    `json:"iv_size"`
+	IV            string `json:"iv"`
+	Layout        string `json:"layout"`
+}
+
+type Framing struct {
+	Transports     []string `json:"transports"`
+	Fields         []Field  `json:"fields"`
+	MaxFrameLength int      `json:"max_frame_length"`
+	FrameTimeout   string   `json:"frame_timeout"`
+	Notes          []string `json:"notes,omitempty"`
+}
+
+type Endpoint struct {
+	Method      string `json:"method"`
+	Query       string `json:"query"`
+	Description string `json:"description"`
+}
+
+type Poll struct {
+	SessionID string     `json:"session_id"`
+	Endpoints []Endpoint `json:"endpoints"`
+	Stream    string     `json:"stream"`
+}
+
+type Target struct {
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	Payload     string `json:"payload"`
+}
+
+type Handshake struct {
+	Request string   `json:"request"`
+	Targets []Target `json:"targets"`
+	Replies []Field  `json:"replies"`
+	Notes   []string `json:"notes,omitempty"`
+}
+
+type Spec struct {
+	Version   int       `json:"version"`
+	Cipher    Cipher    `json:"cipher"`
+	FIPS      Cipher    `json:"fips_cipher"`
+	Stream    Framing   `json:"stream_framing"`
+	WebSocket Framing   `json:"websocket_framing"`
+	Poll      Poll      `json:"poll"`
+	Handshake Handshake `json:"handshake"`
+	Datagram  []Field   `json:"udp_datagram"`
+}
+
+func Describe() Spec {
+	return Spec{
+		Version: Version,
+		Cipher: Cipher{
+			Algorithm:     "AES-256-CFB",
+			KeySize:       32,
+			KeyDerivation: "SHA-256(password)",
+			IVSize:        aes.BlockSize,
+			IV:            "random per frame, never reused",
+			Layout:        "iv || AES-CFB-encrypt(key, iv, plaintext)",
+		},
+		FIPS: Cipher{
+			Algorithm:     "AES-256-GCM",
+			KeySize:       32,
+			KeyDerivation: "HKDF-SHA256(key file, salt empty, info \"tunnel frame key aes-256-gcm\")",
+			IVSize:        12,
+			IV:            "random nonce per frame, never reused",
+			Layout:        "nonce || AES-GCM-seal(key, nonce, plaintext) (ciphertext || 16-byte tag), replaces iv || ciphertext in every framing when both sides run with -fips",
+		},
+		Stream: Framing{
+			Transports: []string{"tcp", "tls", "poll"},
+			Fields: []Field{
+				{Name: "length", Size: "4", Encoding: "uint32 big-endian", Description: "length of iv || ciphertext, 1..max_frame_length"},
+				{Name: "iv", Size: "16", Description: "cipher IV"},
+				{Name: "ciphertext", Size: "length - 16", Description: "encrypted payload"},
+				{Name: "crc32", Size: "4", Encoding: "uint32 big-endian", Description: "CRC-32 (IEEE) of iv || ciphertext, present only when both sides run with frame debug enabled"},
+			},
+			MaxFrameLength: crypto.MaxFrameLength,
+			FrameTimeout:   crypto.DefaultFrameTimeout.String(),
+			Notes: []string{
+				"a frame whose length is 0 or above max_frame_length is a desync and the connection is closed",
+				"once the length prefix is read, the rest of the frame must arrive within frame_timeout",
+				"frame boundaries carry no meaning after the handshake; peers may merge or split writes",
+			},
+		},
+		WebSocket: Framing{
+			Transports: []string{"ws", "wss"},
+			Fields: []Field{
+				{Name: "message", Size: "variable", Encoding: "text message, standard base64 with padding", Description: "base64(iv || ciphertext), one frame per message"},
+			},
+			MaxFrameLength: crypto.MaxFrameLength,
+			Notes: []string{
+				"the upgrade request goes to the configured path (default /ws); other paths get a decoy page",
+			},
+		},
+		Poll: Poll{
+			SessionID: "32 hex characters chosen by the client",
+			Endpoints: []Endpoint{
+				{Method: "POST", Query: "sid=<id>&op=open", Description: "open a session"},
+				{Method: "POST", Query: "sid=<id>", Description: "body carries upstream stream bytes"},
+				{Method: "GET", Query: "sid=<id>", Description: "long poll (up to 20s) for downstream stream bytes"},
+				{Method: "POST", Query: "sid=<id>&op=close", Description: "close the session"},
+			},
+			Stream: "the concatenated bodies form the same byte stream as stream_framing",
+		},
+		Handshake: Handshake{
+			Request: "the first client frame's plaintext is the target",
+			Targets: []Target{
+				{Value: "host:port", Description: "connect to this address if the server allows dynamic targets, otherwise its configured target; IPv6 literals are bracketed", Payload: "raw stream bytes"},
+				{Value: TargetDefault, Description: "connect to the server's configured target", Payload: "raw stream bytes"},
+				{Value: TargetUDPAssociate, Description: "relay UDP; refused unless the server allows dynamic targets", Payload: "one udp_datagram per frame"},
+				{Value: TargetControl, Description: "control channel", Payload: "one JSON control message per frame"},
+			},
+			Replies: []Field{
+				{Name: ReplyOK, Size: "2", Description: "target reached; data frames follow in both directions"},
+				{Name: ReplyErrorPrefix + "<reason>", Size: "variable", Description: "request refused; the server closes the connection"},
+			},
+			Notes: []string{
+				"a first frame that does not decrypt to a valid target is an authentication failure; the server closes the connection without a reply",
+			},
+		},
+		Datagram: []Field{
+			{Name: "rsv", Size: "2", Description: "zero"},
+			{Name: "frag", Size: "1", Description: "zero; fragments are dropped"},
+			{Name: "atyp", Size: "1", Description: "1 = IPv4, 3 = domain, 4 = IPv6"},
+			{Name: "addr", Size: "4, 1 + n or 16", Description: "destination (upstream) or source (downstream) address"},
+			{Name: "port", Size: "2", Encoding: "uint16 big-endian", Description: "port"},
+			{Name: "data", Size: "variable", Description: "UDP payload"},
+		},
+	}
+}
@@ -0,0 +1,376 @@
+package provision
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tunnel/pkg/config"
+	"tunnel/pkg/crypto"
+	"tunnel/pkg/netutil"
+)
+
+const (
+	TransportTCP  = "tcp"
+	TransportTLS  = "tls"
+	TransportWS   = "ws"
+	TransportWSS  = "wss"
+	TransportPoll = "poll"
+)
+
+type Spec struct {
+	Domain    string
+	Target    string
+	Transport string
+
+	Listen       string
+	ClientListen string
+	Password     string
+	WSPath       string
+
+	CertFile string
+	KeyFile  string
+	CertDays int
+
+	InstallDir string
+	BinDir     string
+
+	OutDir string
+	Force  bool
+}
+
+type Result struct {
+	ServerDir string
+	ClientDir string
+	Bundle    string
+	Files     []string
+}
+
+func (s *Spec) normalize() error {
+	if s.Domain == "" {
+		return fmt.Errorf("domain is required")
+	}
+	if s.Target == "" {
+		return fmt.Errorf("target is required")
+	}
+	target, err := netutil.NormalizeAddr(s.Target)
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+	s.Target = target
+
+	switch s.Transport {
+	case "":
+		s.Transport = TransportWSS
+	case TransportTCP, TransportTLS, TransportWS, TransportWSS, TransportPoll:
+	default:
+		return fmt.Errorf("unknown transport %q (tcp, tls, ws, wss, poll)", s.Transport)
+	}
+
+	if s.Listen == "" {
+		if s.usesTLS() {
+			s.Listen = "0.0.0.0:443"
+		} else {
+			s.Listen = "0.0.0.0:8888"
+		}
+	}
+	if _, err := netutil.NormalizeAddr(s.Listen); err != nil {
+		return fmt.Errorf("invalid listen address: %w", err)
+	}
+	if s.ClientListen == "" {
+		s.ClientListen = config.DefaultClientConfig().Listen
+	}
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return fmt.Errorf("cert and key must be given together")
+	}
+	if s.CertDays <= 0 {
+		s.CertDays = 365
+	}
+	if s.WSPath == "" {
+		s.WSPath = config.DefaultServerConfig().WSPath
+	}
+	if s.InstallDir == "" {
+		s.InstallDir = "/etc/tunnel"
+	}
+	if s.BinDir == "" {
+		s.BinDir = "/usr/local/bin"
+	}
+	if s.OutDir == "" {
+		s.OutDir = "provision-" + s.Domain
+	}
+
+	if s.Password == "" {
+		password, err := crypto.GeneratePassword(crypto.DefaultGeneratedLength)
+		if err != nil {
+			return fmt.Errorf("failed to generate password: %w", err)
+		}
+		s.Password = password
+	} else if err := crypto.CheckPassword(s.Password); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Spec) usesTLS() bool {
+	return s.Transport == TransportTLS || s.Transport == TransportWSS
+}
+
+func Generate(spec Spec) (*Result, error) {
+	if err := spec.normalize(); err != nil {
+		return nil, err
+	}
+
+	if !spec.Force {
+		if _, err := os.Stat(spec.OutDir); err == nil {
+			return nil, fmt.Errorf("output directory %s already exists (use -force to overwrite)", spec.OutDir)
+		}
+	}
+
+	result := &Result{
+		ServerDir: filepath.Join(spec.OutDir, "server"),
+		ClientDir: filepath.Join(spec.OutDir, "client"),
+		Bundle:    filepath.Join(spec.OutDir, "client-bundle.tar.gz"),
+	}
+	for _, dir := range []string{result.ServerDir, result.ClientDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	write := func(dir, name string, data []byte, perm os.FileMode) error {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, perm); err != nil {
+			return err
+		}
+		result.Files = append(result.Files, p)
+		return nil
+	}
+
+	if spec.usesTLS() {
+		certPEM, keyPEM, err := spec.certificate()
+		if err != nil {
+			return nil, err
+		}
+		if err := write(result.ServerDir, "server.crt", certPEM, 0644); err != nil {
+			return nil, err
+		}
+		if err := write(result.ServerDir, "server.key", keyPEM, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	serverCfg := spec.serverConfig()
+	if err := config.SaveConfig(serverCfg, filepath.Join(result.ServerDir, "server.yaml")); err != nil {
+		return nil, err
+	}
+	result.Files = append(result.Files, filepath.Join(result.ServerDir, "server.yaml"))
+	if err := write(result.ServerDir, "tunnel-server.service", spec.unit("server"), 0644); err != nil {
+		return nil, err
+	}
+
+	clientCfg := spec.clientConfig()
+	if err := config.SaveConfig(clientCfg, filepath.Join(result.ClientDir, "client.yaml")); err != nil {
+		return nil, err
+	}
+	result.Files = append(result.Files, filepath.Join(result.ClientDir, "client.yaml"))
+	if err := write(result.ClientDir, "tunnel-client.service", spec.unit("client"), 0644); err != nil {
+		return nil, err
+	}
+
+	if err := writeBundle(result.Bundle, result.ClientDir); err != nil {
+		return nil, fmt.Errorf("failed to write client bundle: %w", err)
+	}
+	result.Files = append(result.Files, result.Bundle)
+	return result, nil
+}
+
+func (s *Spec) serverConfig() *config.Config {
+	cfg := config.DefaultServerConfig()
+	cfg.Listen = s.Listen
+	cfg.Target = s.Target
+	cfg.Password = s.Password
+	cfg.WSPath = s.WSPath
+
+	switch s.Transport {
+	case TransportTLS:
+		cfg.ListenTLS = true
+	case TransportWS:
+		cfg.EnableWS = true
+	case TransportWSS:
+		cfg.EnableWS = true
+		cfg.WSTLS = true
+	case TransportPoll:
+		cfg.EnableWS = true
+		cfg.EnablePoll = true
+	}
+	if s.usesTLS() {
+		cfg.WSCert = path.Join(s.InstallDir, "server.crt")
+		cfg.WSKey = path.Join(s.InstallDir, "server.key")
+	}
+
+	cfg.Guard.Enable = true
+	return &config.Config{Mode: "server", Server: cfg}
+}
+
+func (s *Spec) clientConfig() *config.Config {
+	_, port, _ := net.SplitHostPort(s.Listen)
+	selfSigned := s.CertFile == ""
+
+	cfg := config.DefaultClientConfig()
+	cfg.Listen = s.ClientListen
+	cfg.Server = net.JoinHostPort(s.Domain, port)
+	cfg.Password = s.Password
+	cfg.WSPath = s.WSPath
+
+	switch s.Transport {
+	case TransportTLS:
+		cfg.ServerTLS = true
+		cfg.ServerTLSSNI = s.Domain
+		cfg.ServerTLSSkipVerify = selfSigned
+	case TransportWS:
+		cfg.EnableWS = true
+	case TransportWSS:
+		cfg.EnableWS = true
+		cfg.WSTLS = true
+		cfg.WSSkipVerify = selfSigned
+	case TransportPoll:
+		cfg.EnablePoll = true
+	}
+	return &config.Config{Mode: "client", Client: cfg}
+}
+
+func (s *Spec) unit(side string) []byte {
+	binary := path.Join(s.BinDir, "tunnel-"+side)
+	conf := path.Join(s.InstallDir, side+".yaml")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=Tunnel %s (%s)\n", strings.ToUpper(side[:1])+side[1:], s.Domain)
+	fmt.Fprintf(&b, "After=network-online.target\nWants=network-online.target\n\n")
+	fmt.Fprintf(&b, "[Service]\n")
+	fmt.Fprintf(&b, "ExecStart=%s -config %s\n", binary, conf)
+	if side == "server" {
+		fmt.Fprintf(&b, "AmbientCapabilities=CAP_NET_BIND_SERVICE\n")
+	}
+	fmt.Fprintf(&b, "Restart=on-failure\nRestartSec=3\nLimitNOFILE=65536\nNoNewPrivileges=true\n\n")
+	fmt.Fprintf(&b, "[Install]\nWantedBy=multi-user.target\n")
+	return []byte(b.String())
+}
+
+func (s *Spec) certificate() ([]byte, []byte, error) {
+	if s.CertFile != "" {
+		certPEM, err := os.ReadFile(s.CertFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read cert: %w", err)
+		}
+		keyPEM, err := os.ReadFile(s.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read key: %w", err)
+		}
+		return certPEM, keyPEM, nil
+	}
+	return SelfSigned(s.Domain, s.CertDays)
+}
+
+func SelfSigned(host string, days int) ([]byte, []byte, error) {
+	if host == "" {
+		return nil, nil, fmt.Errorf("host is required")
+	}
+	if days <= 0 {
+		return nil, nil, fmt.Errorf("certificate validity must be positive")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: host},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, days),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+func writeBundle(dst, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:    "tunnel-client/" + entry.Name(),
+			Mode:    int64(info.Mode().Perm()),
+			Size:    int64(len(data)),
+			ModTime: info.ModTime(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
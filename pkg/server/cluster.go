@@ -0,0 +1,26 @@
+package server
+
+import (
+	"log"
+
+	"tunnel/pkg/cluster"
+)
+
+func (s *Server) clusterState() cluster.State {
+	day, used := s.quota.snapshot()
+	return cluster.State{
+		Day:      day,
+		Sessions: s.usage.local(),
+		Usage:    used,
+		Bans:     s.acl.Bans(),
+	}
+}
+
+func (s *Server) mergeCluster(state cluster.State) {
+	s.quota.merge(state.Node, state.Day, state.Usage)
+	s.usage.merge(state.Node, state.Sessions)
+	if added := s.acl.MergeBans(state.Bans); added > 0 {
+		log.Printf("[Cluster] ⛔ 从节点 %s 同步封禁 IP %d 个", state.Node, added)
+		s.enforceACL("acl_banned")
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"tunnel/pkg/clock"
+	"tunnel/pkg/control"
+	"tunnel/pkg/crash"
+	"tunnel/pkg/netutil"
+)
+
+const (
+	controlTarget      = "CONTROL"
+	defaultControlLogs = 200
+	controlEventQueue  = 64
+)
+
+type ControlConfig struct {
+	Enable   bool
+	Token    string
+	LogLines int
+}
+
+func (s *Server) setupControl() {
+	if !s.config.Control.Enable {
+		return
+	}
+	if s.config.Control.LogLines <= 0 {
+		s.config.Control.LogLines = defaultControlLogs
+	}
+	s.logs = crash.NewLogBuffer(s.config.Control.LogLines)
+	log.SetOutput(io.MultiWriter(log.Writer(), s.logs))
+}
+
+func (s *Server) serveControl(conn frameConn, clientAddr string) {
+	if !s.config.Control.Enable {
+		log.Printf("[Server] ⚠️ 控制通道未启用，拒绝: %s", clientAddr)
+		conn.WriteEncrypted([]byte("ERROR:control channel disabled"))
+		return
+	}
+	if err := conn.WriteEncrypted([]byte("OK")); err != nil {
+		return
+	}
+
+	stream := control.NewStream(conn)
+	peer := newControlPeer(stream, clientAddr)
+	s.controls.Store(peer, clientAddr)
+	defer s.controls.Delete(peer)
+	defer close(peer.done)
+	go peer.run()
+	log.Printf("[Server] 🎛️ 控制通道已建立: %s", clientAddr)
+
+	for {
+		msg, err := stream.Receive()
+		if err != nil {
+			log.Printf("[Server] 🎛️ 控制通道关闭: %s", clientAddr)
+			return
+		}
+		if msg.Kind != control.KindRequest || msg.Request == nil {
+			continue
+		}
+
+		resp := s.handleControl(*msg.Request, clientAddr)
+		if err := stream.Send(control.Message{ID: msg.ID, Kind: control.KindResponse, Response: &resp}); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handleControl(req control.Request, clientAddr string) control.Response {
+	if s.killing.Load() {
+		return control.Failure(ErrStopped)
+	}
+	if s.config.Control.Token != "" &&
+		subtle.ConstantTimeCompare([]byte(req.Token), []byte(s.config.Control.Token)) != 1 {
+		log.Printf("[Server] 🚫 控制命令令牌错误: %s", clientAddr)
+		return control.Failure(fmt.Errorf("unauthorized"))
+	}
+
+	switch req.Command {
+	case control.CommandPing:
+		return control.Success("pong")
+	case control.CommandVersion:
+		if req.Version != "" {
+			log.Printf("[Server] 🏷️ 控制通道 Client 版本: v%s (%s)", req.Version, clientAddr)
+		}
+		return control.Success(s.Version())
+	case control.CommandTime:
+		now := time.Now()
+		if t, err := time.Parse(time.RFC3339Nano, req.Time); err == nil {
+			if offset := t.Sub(now); clock.Skewed(offset, s.config.Clock.Tolerance) {
+				log.Printf("[Server] ⚠️ 控制通道 Client 时钟相差 %v: %s", offset.Round(time.Millisecond), clientAddr)
+			}
+		}
+		return control.Success(now.UTC().Format(time.RFC3339Nano))
+	case control.CommandStats:
+		return control.Success(s.Stats())
+	case control.CommandLogs:
+		lines := s.logs.Lines()
+		if req.Lines > 0 && req.Lines < len(lines) {
+			lines = lines[len(lines)-req.Lines:]
+		}
+		return control.Success(lines)
+	case control.CommandKill:
+		if s.config.Control.Token == "" {
+			return control.Failure(fmt.Errorf("kill over control channel requires a control token"))
+		}
+		log.Printf("[Server] 🛑 控制通道收到关闭命令: %s", clientAddr)
+		go s.Kill()
+		return control.Success(nil)
+	case control.CommandACLAllow, control.CommandACLDeny, control.CommandACLRemove:
+		return s.updateACL(req, clientAddr)
+	case control.CommandACLList:
+		return control.Success(s.acl.Entries())
+	}
+	return control.Failure(fmt.Errorf("unknown command: %s", req.Command))
+}
+
+func (s *Server) updateACL(req control.Request, clientAddr string) control.Response {
+	if s.config.Control.Token == "" {
+		return control.Failure(fmt.Errorf("acl updates over control channel require a control token"))
+	}
+
+	entry := req.Address
+	if entry == "" {
+		entry = netutil.NormalizeIP(clientAddr)
+	}
+
+	var err error
+	switch req.Command {
+	case control.CommandACLAllow:
+		err = s.acl.Allow(entry)
+	case control.CommandACLDeny:
+		err = s.acl.Deny(entry)
+	default:
+		err = s.acl.Remove(entry)
+	}
+	if err != nil {
+		return control.Failure(err)
+	}
+
+	log.Printf("[Server] 🎛️ 控制通道更新 ACL (%s %s): %s", req.Command, entry, clientAddr)
+	s.enforceACL("acl_denied")
+	return control.Success(entry)
+}
+
+func (s *Server) notifyControl(name string, data interface{}) {
+	event := control.Message{Kind: control.KindEvent, Event: &control.Event{Name: name, Data: data}}
+	s.controls.Range(func(key, _ interface{}) bool {
+		key.(*controlPeer).notify(event)
+		return true
+	})
+}
+
+func (s *Server) killControls() {
+	s.controls.Range(func(key, _ interface{}) bool {
+		key.(*controlPeer).stream.Close()
+		return true
+	})
+}
+
+func (s *Server) closeControls() {
+	s.controls.Range(func(key, _ interface{}) bool {
+		key.(*controlPeer).shutdown()
+		return true
+	})
+}
+
+type controlPeer struct {
+	stream  *control.Stream
+	addr    string
+	events  chan control.Message
+	closing chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newControlPeer(stream *control.Stream, addr string) *controlPeer {
+	return &controlPeer{
+		stream:  stream,
+		addr:    addr,
+		events:  make(chan control.Message, controlEventQueue),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (p *controlPeer) notify(event control.Message) {
+	select {
+	case p.events <- event:
+	default:
+		p.once.Do(func() {
+			log.Printf("[Server] 🎛️ 控制通道事件积压 (%d 条未读)，断开: %s", controlEventQueue, p.addr)
+			p.stream.Close()
+		})
+	}
+}
+
+func (p *controlPeer) shutdown() {
+	p.once.Do(func() {
+		close(p.closing)
+	})
+}
+
+func (p *controlPeer) run() {
+	for {
+		select {
+		case event := <-p.events:
+			if err := p.stream.Send(event); err != nil {
+				p.stream.Close()
+				return
+			}
+		case <-p.closing:
+			for {
+				select {
+				case event := <-p.events:
+					if err := p.stream.Send(event); err != nil {
+						p.stream.Close()
+						return
+					}
+				default:
+					p.stream.Close()
+					return
+				}
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"tunnel/pkg/crash"
+)
+
+type chanListener struct {
+	addr   net.Addr
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newChanListener(addr net.Addr) *chanListener {
+	return &chanListener{
+		addr:   addr,
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+func (l *chanListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *chanListener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *chanListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *chanListener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+func (s *Server) startDual() error {
+	log.Printf("[Server] 🔀 双协议模式启动中 (WebSocket/HTTP + TCP 同端口)...")
+	log.Printf("[Server] 🎯 目标地址: %s", s.config.TargetAddr)
+
+	server, cleanup, err := s.newHTTPServer()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := s.listen(); err != nil {
+		return err
+	}
+
+	httpLn := newChanListener(s.ln.Addr())
+	defer httpLn.Close()
+
+	go func() {
+		if err := ignoreClosed(s.serveHTTP(server, httpLn)); err != nil {
+			log.Printf("[Server] ⚠️ HTTP 服务异常退出: %v", err)
+		}
+	}()
+
+	log.Printf("[Server] 🚀 双协议模式启动成功，监听地址: %s (WebSocket 路径: %s)", s.config.ListenAddr, s.config.WSConfig.Path)
+
+	return s.acceptLoop(func(conn net.Conn) {
+		go s.dispatch(s.ctx, conn, httpLn)
+	})
+}
+
+func (s *Server) dispatch(ctx context.Context, conn net.Conn, httpLn *chanListener) {
+	defer crash.Recover("server.dispatch")
+
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(s.config.HandshakeTimeout))
+	header, err := reader.Peek(4)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	peeked := &peekedConn{Conn: conn, reader: reader}
+
+	switch classifyFirstBytes(header) {
+	case ProbeHTTP:
+		httpLn.deliver(peeked)
+		return
+	case ProbeTLS:
+		if s.config.WSConfig.EnableTLS {
+			httpLn.deliver(peeked)
+			return
+		}
+	}
+
+	if !s.allowRaw(conn) {
+		return
+	}
+	s.handleTCPConnection(ctx, peeked, transportTCP)
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var ErrTargetForbidden = errors.New("target not permitted")
+
+var specialRanges = parseSpecialRanges(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+	"64:ff9b:1::/48",
+	"100::/64",
+	"2002::/16",
+)
+
+func parseSpecialRanges(items ...string) []*net.IPNet {
+	ranges := make([]*net.IPNet, 0, len(items))
+	for _, item := range items {
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			panic(err)
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return ranges
+}
+
+type portRange struct {
+	lo, hi int
+}
+
+type egressPolicy struct {
+	allow []*net.IPNet
+	ports []portRange
+	self  map[int]bool
+	local []net.IP
+}
+
+func newEgressPolicy(config *Config) (*egressPolicy, error) {
+	p := &egressPolicy{self: make(map[int]bool)}
+
+	for _, item := range config.DynamicTargetAllow {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		ipNet, err := parseAllowNet(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dynamic target allow entry %q: %w", item, err)
+		}
+		p.allow = append(p.allow, ipNet)
+	}
+
+	ports, err := parsePortRanges(config.DynamicTargetPorts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dynamic target ports: %w", err)
+	}
+	p.ports = ports
+
+	own := []string{config.ListenAddr, config.HealthListen}
+	if config.AdminConfig.Enable {
+		own = append(own, config.AdminConfig.Listen)
+	}
+	if config.Cluster.Enable {
+		own = append(own, config.Cluster.Listen)
+	}
+	for _, fwd := range config.PlainForwards {
+		own = append(own, fwd.Listen)
+	}
+	for _, addr := range own {
+		if _, port, err := net.SplitHostPort(addr); err == nil {
+			if n, err := strconv.Atoi(port); err == nil && n > 0 {
+				p.self[n] = true
+			}
+		}
+	}
+
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				p.local = append(p.local, ipNet.IP)
+			}
+		}
+	}
+	return p, nil
+}
+
+func parseAllowNet(item string) (*net.IPNet, error) {
+	if strings.Contains(item, "/") {
+		_, ipNet, err := net.ParseCIDR(item)
+		return ipNet, err
+	}
+	ip := net.ParseIP(item)
+	if ip == nil {
+		return nil, fmt.Errorf("not an IP or CIDR")
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func parsePortRanges(spec string) ([]portRange, error) {
+	var ranges []portRange
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		if !isRange {
+			hi = lo
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		to, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || from < 1 || to > 65535 || from > to {
+			return nil, fmt.Errorf("bad port range %q", part)
+		}
+		ranges = append(ranges, portRange{lo: from, hi: to})
+	}
+	return ranges, nil
+}
+
+func portsOrAll(spec string) string {
+	if strings.TrimSpace(spec) == "" {
+		return "全部"
+	}
+	return spec
+}
+
+func (p *egressPolicy) portAllowed(port int) bool {
+	if len(p.ports) == 0 {
+		return port > 0 && port <= 65535
+	}
+	for _, r := range p.ports {
+		if port >= r.lo && port <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *egressPolicy) isLocal(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return true
+	}
+	for _, local := range p.local {
+		if local.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *egressPolicy) allowed(ip net.IP) bool {
+	for _, ipNet := range p.allow {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func internalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsPrivate() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, ipNet := range specialRanges {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *egressPolicy) permits(ip net.IP, port int) error {
+	switch {
+	case !p.portAllowed(port):
+		return fmt.Errorf("%w: port %d", ErrTargetForbidden, port)
+	case p.self[port] && p.isLocal(ip):
+		return fmt.Errorf("%w: server's own port %d", ErrTargetForbidden, port)
+	case internalIP(ip) && !p.allowed(ip):
+		return fmt.Errorf("%w: internal address %s", ErrTargetForbidden, ip)
+	}
+	return nil
+}
+
+func (s *Server) vetTarget(ctx context.Context, addr string) (string, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid port: %s", portStr)
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	ips, err := s.lookupIPs(ctx, host)
+	if err != nil {
+		return "", err
+	}
+
+	var denied error
+	for _, ip := range ips {
+		if err := s.egress.permits(ip, port); err != nil {
+			if denied == nil {
+				denied = err
+			}
+			continue
+		}
+		return net.JoinHostPort(ip.String(), portStr), nil
+	}
+	return "", denied
+}
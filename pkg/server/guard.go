@@ -0,0 +1,281 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"tunnel/pkg/acl"
+	"tunnel/pkg/crypto"
+	"tunnel/pkg/netutil"
+	"tunnel/pkg/probe"
+)
+
+const (
+	guardSweepInterval = time.Minute
+	maxGuardFailures   = 10000
+)
+
+const (
+	ProbeTLS     = "tls"
+	ProbeHTTP    = "http"
+	ProbeOther   = "other"
+	ProbeTimeout = "timeout"
+)
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST"), []byte("HEAD"), []byte("PUT "),
+	[]byte("DELE"), []byte("OPTI"), []byte("CONN"), []byte("PATC"),
+	[]byte("TRAC"), []byte("PRI "),
+}
+
+type GuardConfig struct {
+	Enable      bool
+	MaxFailures int
+	BanDuration time.Duration
+}
+
+func DefaultGuardConfig() GuardConfig {
+	return GuardConfig{
+		Enable:      false,
+		MaxFailures: 3,
+		BanDuration: 30 * time.Minute,
+	}
+}
+
+type guard struct {
+	config GuardConfig
+	acl    *acl.ACL
+	stats  *Stats
+	probes *probe.Logger
+
+	mu       sync.Mutex
+	failures map[string]*failureCount
+
+	onBan func()
+}
+
+func newGuard(config GuardConfig, accessControl *acl.ACL, stats *Stats, probes *probe.Logger) *guard {
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = DefaultGuardConfig().MaxFailures
+	}
+	if config.BanDuration <= 0 {
+		config.BanDuration = DefaultGuardConfig().BanDuration
+	}
+
+	return &guard{
+		config:   config,
+		acl:      accessControl,
+		stats:    stats,
+		probes:   probes,
+		failures: make(map[string]*failureCount),
+	}
+}
+
+type failureCount struct {
+	count int
+	last  time.Time
+}
+
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (g *guard) inspect(conn net.Conn) (net.Conn, bool) {
+	if !g.config.Enable && !g.probes.Enabled() {
+		return conn, true
+	}
+
+	start := time.Now()
+	reader := bufio.NewReader(conn)
+	header, err := reader.Peek(4)
+	if err != nil {
+		if netutil.IsTimeout(err) {
+			g.recordTimeout(conn.RemoteAddr().String(), time.Since(start))
+		}
+		return conn, false
+	}
+
+	if kind := classifyFirstBytes(header); kind != "" {
+		payload, _ := reader.Peek(reader.Buffered())
+		g.probes.Log(probe.Record{
+			Remote:    conn.RemoteAddr().String(),
+			Reason:    "probe_" + kind,
+			ElapsedMS: time.Since(start).Milliseconds(),
+			Payload:   payload,
+		})
+		g.recordProbe(conn.RemoteAddr().String(), kind)
+		return conn, false
+	}
+
+	return &peekedConn{Conn: conn, reader: reader}, true
+}
+
+func classifyFirstBytes(header []byte) string {
+	if len(header) >= 2 && header[0] == 0x16 && header[1] == 0x03 {
+		return ProbeTLS
+	}
+
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(header, method) {
+			return ProbeHTTP
+		}
+	}
+
+	if len(header) >= 4 {
+		length := int(header[0])<<24 | int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		if length <= 16 || length > crypto.MaxFrameLength {
+			return ProbeOther
+		}
+	}
+
+	return ""
+}
+
+func (g *guard) recordProbe(addr, kind string) {
+	switch kind {
+	case ProbeTLS:
+		g.stats.ProbesTLS.Add(1)
+	case ProbeHTTP:
+		g.stats.ProbesHTTP.Add(1)
+	case ProbeTimeout:
+		g.stats.ProbesTimeout.Add(1)
+	default:
+		g.stats.ProbesOther.Add(1)
+	}
+
+	log.Printf("[Guard] 🔍 检测到非隧道流量 (%s): %s", kind, addr)
+
+	if g.config.Enable {
+		g.ban(addr)
+	}
+}
+
+func (g *guard) recordTimeout(addr string, elapsed time.Duration) {
+	g.probes.Log(probe.Record{
+		Remote:    addr,
+		Reason:    "probe_" + ProbeTimeout,
+		ElapsedMS: elapsed.Milliseconds(),
+	})
+	g.recordProbe(addr, ProbeTimeout)
+}
+
+func (g *guard) recordFailure(addr string) {
+	g.stats.HandshakeFailures.Add(1)
+	g.probes.Log(probe.Record{Remote: addr, Reason: "bad_handshake"})
+
+	if !g.config.Enable {
+		return
+	}
+
+	key := netutil.NormalizeIP(addr)
+	now := time.Now()
+
+	g.mu.Lock()
+	f := g.failures[key]
+	if f == nil || now.Sub(f.last) > g.config.BanDuration {
+		if f == nil && len(g.failures) >= maxGuardFailures {
+			g.evictOldest()
+		}
+		f = &failureCount{}
+		g.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	count := f.count
+	if count >= g.config.MaxFailures {
+		delete(g.failures, key)
+	}
+	g.mu.Unlock()
+
+	log.Printf("[Guard] ⚠️ 握手失败 (%d/%d): %s", count, g.config.MaxFailures, addr)
+
+	if count >= g.config.MaxFailures {
+		g.ban(addr)
+	}
+}
+
+func (g *guard) recordSuccess(addr string) {
+	if !g.config.Enable {
+		return
+	}
+
+	g.mu.Lock()
+	delete(g.failures, netutil.NormalizeIP(addr))
+	g.mu.Unlock()
+}
+
+func (g *guard) evictOldest() {
+	var oldest string
+	var at time.Time
+	for key, f := range g.failures {
+		if oldest == "" || f.last.Before(at) {
+			oldest, at = key, f.last
+		}
+	}
+	delete(g.failures, oldest)
+}
+
+func (g *guard) sweep() {
+	cutoff := time.Now().Add(-g.config.BanDuration)
+	g.mu.Lock()
+	for key, f := range g.failures {
+		if f.last.Before(cutoff) {
+			delete(g.failures, key)
+		}
+	}
+	g.mu.Unlock()
+	g.acl.SweepBans()
+}
+
+func (g *guard) run(ctx context.Context) {
+	ticker := time.NewTicker(guardSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (g *guard) ban(addr string) {
+	g.acl.Ban(addr, g.config.BanDuration)
+	g.stats.Bans.Add(1)
+	if g.onBan != nil {
+		g.onBan()
+	}
+}
+
+func normalizeHandshake(target string) (string, bool) {
+	if target == "USE_DEFAULT" || target == udpAssociateTarget || target == controlTarget {
+		return target, true
+	}
+
+	for i := 0; i < len(target); i++ {
+		if target[i] < 0x21 || target[i] > 0x7e {
+			return "", false
+		}
+	}
+
+	normalized, err := netutil.NormalizeAddr(target)
+	if err != nil {
+		return "", false
+	}
+	host, port, _ := net.SplitHostPort(normalized)
+	if host == "" || port == "0" {
+		return "", false
+	}
+	return normalized, true
+}
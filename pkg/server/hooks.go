@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+)
+
+type SessionInfo struct {
+	Start       time.Time `json:"start"`
+	Peer        string    `json:"peer"`
+	IP          string    `json:"ip"`
+	Transport   string    `json:"transport"`
+	Target      string    `json:"target"`
+	Rule        string    `json:"rule,omitempty"`
+	BytesUp     int64     `json:"bytes_up"`
+	BytesDown   int64     `json:"bytes_down"`
+	CloseReason string    `json:"close_reason,omitempty"`
+}
+
+type Hooks interface {
+	OnAccept(ctx context.Context, conn net.Conn) error
+	OnHandshake(ctx context.Context, info SessionInfo) error
+	OnDialTarget(ctx context.Context, info SessionInfo) (string, error)
+	OnClose(ctx context.Context, info SessionInfo)
+	OnError(ctx context.Context, info SessionInfo, err error)
+}
+
+type NopHooks struct{}
+
+func (NopHooks) OnAccept(context.Context, net.Conn) error       { return nil }
+func (NopHooks) OnHandshake(context.Context, SessionInfo) error { return nil }
+func (NopHooks) OnClose(context.Context, SessionInfo)           {}
+func (NopHooks) OnError(context.Context, SessionInfo, error)    {}
+
+func (NopHooks) OnDialTarget(_ context.Context, info SessionInfo) (string, error) {
+	return info.Target, nil
+}
+
+func (s *Server) Use(h Hooks) {
+	s.hooks = append(s.hooks, h)
+}
+
+type hookChain []Hooks
+
+func (c hookChain) OnAccept(ctx context.Context, conn net.Conn) error {
+	for _, h := range c {
+		if err := h.OnAccept(ctx, conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c hookChain) OnHandshake(ctx context.Context, info SessionInfo) error {
+	for _, h := range c {
+		if err := h.OnHandshake(ctx, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c hookChain) OnDialTarget(ctx context.Context, info SessionInfo) (string, error) {
+	for _, h := range c {
+		target, err := h.OnDialTarget(ctx, info)
+		if err != nil {
+			return "", err
+		}
+		info.Target = target
+	}
+	return info.Target, nil
+}
+
+func (c hookChain) OnClose(ctx context.Context, info SessionInfo) {
+	for _, h := range c {
+		h.OnClose(ctx, info)
+	}
+}
+
+func (c hookChain) OnError(ctx context.Context, info SessionInfo, err error) {
+	for _, h := range c {
+		h.OnError(ctx, info, err)
+	}
+}
+
+type hookListener struct {
+	net.Listener
+	ctx   context.Context
+	hooks hookChain
+}
+
+func (l *hookListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if err := l.hooks.OnAccept(l.ctx, conn); err != nil {
+			log.Printf("[Server] 🪝 钩子拒绝连接 %s: %v", conn.RemoteAddr(), err)
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+func (s *Server) hookListener(ln net.Listener) net.Listener {
+	if len(s.hooks) == 0 {
+		return ln
+	}
+	return &hookListener{Listener: ln, ctx: s.ctx, hooks: s.hooks}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"tunnel/pkg/admin"
+	"tunnel/pkg/control"
+)
+
+func (s *Server) Killed() <-chan struct{} {
+	return s.killed
+}
+
+func (s *Server) Kill() {
+	s.killOnce.Do(func() {
+		s.killing.Store(true)
+		log.Printf("[Server] 🛑 收到紧急关闭指令，断开所有会话并停止监听")
+		s.notifyControl(control.EventShutdown, nil)
+
+		dropped := s.stats.ActiveConnections.Load()
+		s.killSessions("admin_kill", func(*session) bool { return true })
+		s.killControls()
+		s.cancel()
+		s.Stop()
+		s.cipher.Wipe()
+		if s.peers != nil {
+			s.peers.Wipe()
+		}
+		log.Printf("[Server] 🛑 已断开 %d 个会话，隧道与集群密钥的原始副本已清零", dropped)
+
+		close(s.killed)
+	})
+}
+
+func (s *Server) handleKill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin.WriteJSON(w, http.StatusAccepted, map[string]interface{}{
+		"status":   "killing",
+		"sessions": s.stats.ActiveConnections.Load(),
+	})
+	go s.Kill()
+}
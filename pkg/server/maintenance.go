@@ -0,0 +1,49 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"tunnel/pkg/admin"
+)
+
+var ErrMaintenance = errors.New("maintenance mode")
+
+func (s *Server) Maintenance() bool {
+	return s.maintenance.Load()
+}
+
+func (s *Server) SetMaintenance(on bool) {
+	if s.maintenance.Swap(on) == on {
+		return
+	}
+	if on {
+		log.Printf("[Server] 🚧 进入维护模式: 拒绝新会话，%d 个现有会话继续运行", s.stats.ActiveConnections.Load())
+	} else {
+		log.Printf("[Server] ✅ 退出维护模式，恢复接受新会话")
+	}
+}
+
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		on, err := strconv.ParseBool(r.URL.Query().Get("enable"))
+		if err != nil {
+			http.Error(w, "enable must be true or false", http.StatusBadRequest)
+			return
+		}
+		s.SetMaintenance(on)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"maintenance": s.maintenance.Load(),
+		"sessions":    s.stats.ActiveConnections.Load(),
+	})
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"tunnel/pkg/control"
+	"tunnel/pkg/netutil"
+)
+
+const (
+	memoryHighWater = 0.90
+	memoryLowWater  = 0.75
+	memoryInterval  = time.Second
+)
+
+var ErrMemoryLimit = errors.New("memory limit reached")
+
+type memoryBudget struct {
+	limit    int64
+	overload atomic.Bool
+}
+
+func (m *memoryBudget) enabled() bool {
+	return m.limit > 0
+}
+
+func (m *memoryBudget) admit() bool {
+	return !m.overload.Load()
+}
+
+func (s *Server) watchMemory() {
+	m := s.memory
+	debug.SetMemoryLimit(m.limit)
+	log.Printf("[Server] 🧮 内存上限: %d MB (超过 %.0f%% 暂停接受新会话)", m.limit>>20, memoryHighWater*100)
+
+	ticker := time.NewTicker(memoryInterval)
+	defer ticker.Stop()
+
+	var stats runtime.MemStats
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.killed:
+			return
+		}
+
+		if m.overload.Load() {
+			debug.FreeOSMemory()
+		}
+		runtime.ReadMemStats(&stats)
+		used := int64(stats.HeapInuse + stats.StackInuse)
+		switch {
+		case !m.overload.Load() && used >= int64(float64(m.limit)*memoryHighWater):
+			m.overload.Store(true)
+			netutil.SetRelayPressure(true)
+			log.Printf("[Server] 🧯 内存接近上限 (%d/%d MB)，暂停接受新会话并收缩转发缓冲", used>>20, m.limit>>20)
+			s.notifyControl(control.EventCapacity, map[string]interface{}{
+				"state": "overloaded", "used": used, "limit": m.limit,
+				"active_connections": s.stats.ActiveConnections.Load(),
+			})
+		case m.overload.Load() && used < int64(float64(m.limit)*memoryLowWater):
+			m.overload.Store(false)
+			netutil.SetRelayPressure(false)
+			log.Printf("[Server] ✅ 内存回落 (%d/%d MB)，恢复接受新会话", used>>20, m.limit>>20)
+			s.notifyControl(control.EventCapacity, map[string]interface{}{
+				"state": "recovered", "used": used, "limit": m.limit,
+				"active_connections": s.stats.ActiveConnections.Load(),
+			})
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+
+	"tunnel/pkg/metrics"
+)
+
+func (s *Server) securityMetrics() []metrics.Family {
+	return []metrics.Family{
+		metrics.Counter("decrypt_failures", "Handshakes and frames that failed to decrypt or validate.", s.stats.DecryptFailures.Load()),
+		metrics.Counter("handshake_timeouts", "Connections that did not complete the handshake in time.", s.stats.ProbesTimeout.Load()),
+		metrics.Counter("handshake_failures", "Handshakes rejected for any reason.", s.stats.HandshakeFailures.Load()),
+		metrics.LabeledCounter("acl_denied", "Connections denied by the ACL, by matching rule.", "rule", s.acl.Denied()),
+		metrics.Counter("bans", "Source IPs banned by the guard.", s.stats.Bans.Load()),
+		metrics.Gauge("banned_ips", "Source IPs currently banned.", int64(len(s.acl.Bans()))),
+		metrics.Gauge("active_connections", "Open tunnel connections.", s.stats.ActiveConnections.Load()),
+	}
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.OpenMetricsContentType)
+	metrics.WriteOpenMetrics(w, "tunnel", s.securityMetrics())
+}
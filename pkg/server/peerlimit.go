@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPeerSessions = errors.New("too many concurrent sessions from client")
+	ErrPeerRate     = errors.New("client session rate exceeded")
+)
+
+const (
+	peerRateWindow = time.Minute
+	peerIdleExpiry = 24 * time.Hour
+)
+
+type PeerLimitConfig struct {
+	MaxSessions int
+	PerMinute   int
+}
+
+type peerState struct {
+	active   int
+	sessions int64
+	rejected int64
+	window   time.Time
+	count    int
+	last     time.Time
+}
+
+type peerLimits struct {
+	config PeerLimitConfig
+
+	mu    sync.Mutex
+	peers map[string]*peerState
+	swept time.Time
+}
+
+func newPeerLimits(cfg PeerLimitConfig) *peerLimits {
+	return &peerLimits{config: cfg, peers: make(map[string]*peerState)}
+}
+
+func (p *peerLimits) enabled() bool {
+	return p.config.MaxSessions > 0 || p.config.PerMinute > 0
+}
+
+func (p *peerLimits) admit(ip string) error {
+	if !p.enabled() {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.sweep(now)
+	st := p.peers[ip]
+	if st == nil {
+		st = &peerState{}
+		p.peers[ip] = st
+	}
+	st.last = now
+	if now.Sub(st.window) >= peerRateWindow {
+		st.window = now
+		st.count = 0
+	}
+
+	if p.config.MaxSessions > 0 && st.active >= p.config.MaxSessions {
+		st.rejected++
+		return ErrPeerSessions
+	}
+	if p.config.PerMinute > 0 && st.count >= p.config.PerMinute {
+		st.rejected++
+		return ErrPeerRate
+	}
+
+	st.count++
+	st.active++
+	st.sessions++
+	return nil
+}
+
+func (p *peerLimits) release(ip string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if st := p.peers[ip]; st != nil && st.active > 0 {
+		st.active--
+		st.last = time.Now()
+	}
+}
+
+func (p *peerLimits) sweep(now time.Time) {
+	if now.Sub(p.swept) < peerRateWindow {
+		return
+	}
+	p.swept = now
+	for ip, st := range p.peers {
+		if st.active == 0 && now.Sub(st.last) > peerIdleExpiry {
+			delete(p.peers, ip)
+		}
+	}
+}
+
+func (p *peerLimits) Stats() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clients := make(map[string]interface{}, len(p.peers))
+	for ip, st := range p.peers {
+		clients[ip] = map[string]interface{}{
+			"active":   st.active,
+			"sessions": st.sessions,
+			"rejected": st.rejected,
+		}
+	}
+	return map[string]interface{}{
+		"max_sessions": p.config.MaxSessions,
+		"per_minute":   p.config.PerMinute,
+		"clients":      clients,
+	}
+}
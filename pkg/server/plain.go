@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"tunnel/pkg/crash"
+	"tunnel/pkg/netutil"
+)
+
+type PlainForward struct {
+	Name   string
+	Listen string
+	Target string
+}
+
+type plainForward struct {
+	PlainForward
+	ln     net.Listener
+	active atomic.Int64
+	total  atomic.Int64
+	bytes  atomic.Int64
+}
+
+func (f *plainForward) tag() string {
+	if f.Name == "" {
+		return "Server"
+	}
+	return "Server:" + f.Name
+}
+
+func (f *plainForward) key() string {
+	if f.Name == "" {
+		return f.Listen
+	}
+	return f.Name
+}
+
+func (s *Server) startPlainForwards() error {
+	names := make(map[string]bool)
+	for _, cfg := range s.config.PlainForwards {
+		if cfg.Listen == "" || cfg.Target == "" {
+			return fmt.Errorf("plain forward requires both listen and target")
+		}
+		if cfg.Name != "" {
+			if names[cfg.Name] {
+				return fmt.Errorf("duplicate plain forward name %q", cfg.Name)
+			}
+			names[cfg.Name] = true
+		}
+		ln, err := netutil.Listen(cfg.Listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen plain forward %s: %w", cfg.Listen, err)
+		}
+		fwd := &plainForward{PlainForward: cfg, ln: ln}
+		s.plain = append(s.plain, fwd)
+		log.Printf("[%s] 🔓 明文转发: %s -> %s", fwd.tag(), cfg.Listen, cfg.Target)
+		go s.servePlain(s.hookListener(ln), fwd)
+	}
+	return nil
+}
+
+func (s *Server) restartPlainForwards() {
+	for _, fwd := range s.plain {
+		ln, err := netutil.Listen(fwd.Listen)
+		if err != nil {
+			log.Printf("[%s] ⚠️ 明文转发恢复失败: %v", fwd.tag(), err)
+			continue
+		}
+		fwd.ln = ln
+		go s.servePlain(s.hookListener(ln), fwd)
+	}
+}
+
+func (s *Server) stopPlainForwards() {
+	for _, fwd := range s.plain {
+		fwd.ln.Close()
+	}
+}
+
+func (s *Server) plainStats() map[string]interface{} {
+	stats := make(map[string]interface{}, len(s.plain))
+	for _, fwd := range s.plain {
+		stats[fwd.key()] = map[string]interface{}{
+			"active_connections": fwd.active.Load(),
+			"total_connections":  fwd.total.Load(),
+			"bytes":              fwd.bytes.Load(),
+		}
+	}
+	return stats
+}
+
+func (s *Server) servePlain(ln net.Listener, fwd *plainForward) {
+	defer crash.Recover("server.plain")
+
+	var backoff netutil.Backoff
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if netutil.IsClosed(err) {
+				return
+			}
+			netutil.HandleAcceptError(fwd.tag(), err, &backoff)
+			continue
+		}
+		backoff.Reset()
+
+		if !s.allowRaw(conn) {
+			continue
+		}
+		fwd.total.Add(1)
+		go s.relayPlain(s.ctx, conn, fwd)
+	}
+}
+
+func (s *Server) relayPlain(ctx context.Context, clientConn net.Conn, fwd *plainForward) {
+	defer crash.Recover("server.plain")
+	defer clientConn.Close()
+	ctx, cancel := netutil.CloseOnDone(ctx, clientConn)
+	defer cancel()
+
+	fwd.active.Add(1)
+	defer fwd.active.Add(-1)
+
+	targetConn, err := s.dialer.DialContext(ctx, "tcp", fwd.Target)
+	if err != nil {
+		log.Printf("[%s] ❌ 明文转发连接目标失败: %s -> %s: %v", fwd.tag(), clientConn.RemoteAddr(), fwd.Target, err)
+		return
+	}
+	defer targetConn.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	closeBoth := func() {
+		clientConn.Close()
+		targetConn.Close()
+	}
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("server.plain")
+		n, _ := io.Copy(targetConn, clientConn)
+		fwd.bytes.Add(n)
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("server.plain")
+		n, _ := io.Copy(clientConn, targetConn)
+		fwd.bytes.Add(n)
+	}()
+
+	wg.Wait()
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"syscall"
+	"time"
+
+	"tunnel/pkg/crash"
+	"tunnel/pkg/netutil"
+	"tunnel/pkg/sockfilter"
+)
+
+const prefilterRefresh = 30 * time.Second
+
+func (s *Server) startPrefilter(sockets []net.Listener, inherited bool) error {
+	var conns []syscall.Conn
+	for _, ln := range sockets {
+		if conn, ok := ln.(syscall.Conn); ok {
+			conns = append(conns, conn)
+		}
+	}
+	if !s.config.ACLConfig.KernelFilter {
+		if inherited {
+			for _, conn := range conns {
+				sockfilter.Detach(conn)
+			}
+		}
+		return nil
+	}
+
+	f := s.acl.Prefilter()
+	for _, conn := range conns {
+		if err := sockfilter.Attach(conn, f); err != nil {
+			return fmt.Errorf("failed to attach acl kernel filter: %w", err)
+		}
+	}
+	others := "放行"
+	if f.DropOthers {
+		others = "丢弃"
+	}
+	log.Printf("[Server] 🧱 ACL 内核预过滤已启用: 丢弃 %d 条，放行 %d 条，其余%s", len(f.Drop), len(f.Allow), others)
+	if s.config.EnableWS {
+		log.Printf("[Server] ⚠️ 内核预过滤按 TCP 对端地址判断，位于 CDN/反向代理之后时请勿启用")
+	}
+
+	go s.refreshPrefilter(conns)
+	return nil
+}
+
+func (s *Server) refreshPrefilter(conns []syscall.Conn) {
+	defer crash.Recover("server.prefilter")
+
+	ticker := time.NewTicker(prefilterRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.acl.Changes():
+		case <-ticker.C:
+		case <-s.killed:
+			return
+		}
+
+		f := s.acl.Prefilter()
+		for _, conn := range conns {
+			if err := sockfilter.Attach(conn, f); err != nil {
+				if netutil.IsClosed(err) {
+					return
+				}
+				log.Printf("[Server] ⚠️ 更新 ACL 内核预过滤失败: %v", err)
+			}
+		}
+	}
+}
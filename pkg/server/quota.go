@@ -0,0 +1,130 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSessionQuota = errors.New("session quota exceeded")
+	ErrDailyQuota   = errors.New("daily quota exceeded")
+)
+
+type QuotaConfig struct {
+	SessionBytes int64
+	DailyBytes   int64
+}
+
+type quota struct {
+	config QuotaConfig
+
+	mu    sync.Mutex
+	day   string
+	used  map[string]int64
+	peers map[string]map[string]int64
+}
+
+func newQuota(cfg QuotaConfig) *quota {
+	return &quota{config: cfg, used: make(map[string]int64), peers: make(map[string]map[string]int64)}
+}
+
+func (q *quota) enabled() bool {
+	return q.config.SessionBytes > 0 || q.config.DailyBytes > 0
+}
+
+func (q *quota) admit(ip string) bool {
+	if q.config.DailyBytes <= 0 {
+		return true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+	return q.total(ip) < q.config.DailyBytes
+}
+
+func (q *quota) charge(sess *session, n int) error {
+	if n <= 0 || !q.enabled() {
+		return nil
+	}
+
+	if q.config.SessionBytes > 0 && sess.up.Load()+sess.down.Load()+int64(n) > q.config.SessionBytes {
+		return q.exceeded(sess, ErrSessionQuota)
+	}
+
+	if q.config.DailyBytes > 0 {
+		q.mu.Lock()
+		q.rollover()
+		q.used[sess.ip] += int64(n)
+		used := q.total(sess.ip)
+		q.mu.Unlock()
+
+		if used > q.config.DailyBytes {
+			return q.exceeded(sess, ErrDailyQuota)
+		}
+	}
+	return nil
+}
+
+func (q *quota) exceeded(sess *session, err error) error {
+	sess.end("quota_exceeded")
+	sess.quotaOnce.Do(func() {
+		log.Printf("[Server] 📦 流量超出配额，关闭会话: %s -> %s (%v，上行 %d 字节，下行 %d 字节)",
+			sess.peer, sess.target, err, sess.up.Load(), sess.down.Load())
+	})
+	return err
+}
+
+func (q *quota) rollover() {
+	today := time.Now().Format("2006-01-02")
+	if q.day != today {
+		q.day = today
+		q.used = make(map[string]int64)
+		q.peers = make(map[string]map[string]int64)
+	}
+}
+
+func (q *quota) total(ip string) int64 {
+	total := q.used[ip]
+	for _, used := range q.peers {
+		total += used[ip]
+	}
+	return total
+}
+
+func (q *quota) snapshot() (string, map[string]int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+
+	used := make(map[string]int64, len(q.used))
+	for ip, n := range q.used {
+		used[ip] = n
+	}
+	return q.day, used
+}
+
+func (q *quota) merge(node, day string, used map[string]int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+
+	if day != q.day {
+		delete(q.peers, node)
+		return
+	}
+	q.peers[node] = used
+}
+
+func (q *quota) Stats() map[string]interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+
+	return map[string]interface{}{
+		"session_bytes": q.config.SessionBytes,
+		"daily_bytes":   q.config.DailyBytes,
+		"clients_today": len(q.used),
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"tunnel/pkg/acl"
+	"tunnel/pkg/crash"
+	"tunnel/pkg/netutil"
+)
+
+func loadClientCAs(tlsConfig *tls.Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read raw tls client ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in %s", path)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return nil
+}
+
+func (s *Server) handleRawTLS(ctx context.Context, conn *tls.Conn) {
+	defer crash.Recover("server.raw")
+	defer conn.Close()
+	ctx, cancel := netutil.CloseOnDone(ctx, conn)
+	defer cancel()
+	clientAddr := conn.RemoteAddr().String()
+
+	s.stats.TotalConnections.Add(1)
+	s.stats.ActiveConnections.Add(1)
+	defer s.stats.ActiveConnections.Add(-1)
+
+	handshakeCtx, handshakeCancel := context.WithTimeout(ctx, s.config.HandshakeTimeout)
+	err := conn.HandshakeContext(handshakeCtx)
+	handshakeCancel()
+	if err != nil {
+		log.Printf("[Server] ❌ 原始 TLS 握手失败: %s: %v", clientAddr, err)
+		s.guard.recordFailure(clientAddr)
+		return
+	}
+	s.guard.recordSuccess(clientAddr)
+
+	var subject string
+	if certs := conn.ConnectionState().PeerCertificates; len(certs) > 0 {
+		subject = certs[0].Subject.CommonName
+	}
+
+	check := &aclCheck{req: acl.Request{Addr: clientAddr}, transport: acl.TransportTCP}
+	_, rule := s.acl.Evaluate(check.req, check.transport)
+	sess := newSession(clientAddr, transportRawTLS, rule)
+	sess.conn, sess.check = conn, check
+	sess.target = s.config.TargetAddr
+	defer s.finishSession(ctx, sess)
+
+	if err := s.admitSession(ctx, sess); err != nil {
+		return
+	}
+	targetConn, err := s.openTarget(ctx, sess)
+	if err != nil {
+		return
+	}
+	defer targetConn.Close()
+
+	log.Printf("[Server] ✅ 原始 TLS 隧道建立成功: %s (%s) <-> %s", clientAddr, subject, sess.target)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	closeBoth := func() {
+		conn.Close()
+		targetConn.Close()
+	}
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("server.raw")
+		if _, err := io.Copy(targetConn, conn); err == nil {
+			sess.end("client_closed")
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("server.raw")
+		io.Copy(conn, targetConn)
+	}()
+
+	wg.Wait()
+	log.Printf("[Server] 🔌 原始 TLS 连接关闭 (%s): %s", sess.closeReason(), clientAddr)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"tunnel/pkg/netutil"
+)
+
+func newDialer(dnsServer string, mark netutil.SocketMark, timeout time.Duration) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout, Control: mark.Control()}
+	if dnsServer == "" {
+		return dialer
+	}
+
+	if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+		dnsServer = net.JoinHostPort(dnsServer, "53")
+	}
+
+	dialer.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, network, dnsServer)
+		},
+	}
+	return dialer
+}
+
+func (s *Server) dialTarget(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := s.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.targetTLS == nil || addr != s.config.TargetAddr {
+		return conn, nil
+	}
+
+	tlsConn, err := s.originateTLS(ctx, conn, addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (s *Server) resolveUDP(ctx context.Context, addr string) (*net.UDPAddr, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port: %s", portStr)
+	}
+
+	ips, err := s.lookupIPs(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: ips[0], Port: port}, nil
+}
+
+func (s *Server) lookupIPs(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if literal, _, ok := strings.Cut(host, "%"); ok {
+		if ip := net.ParseIP(literal); ip != nil {
+			return []net.IP{ip}, nil
+		}
+	}
+
+	resolver := s.dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.DialTimeout)
+	defer cancel()
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, nil
+}
+
+func normalizeAddrs(config *Config) error {
+	if _, err := netutil.NormalizeAddr(config.ListenAddr); err != nil {
+		return fmt.Errorf("invalid listen address: %w", err)
+	}
+	if config.TargetAddr != "" {
+		target, err := netutil.NormalizeAddr(config.TargetAddr)
+		if err != nil {
+			return fmt.Errorf("invalid target address: %w", err)
+		}
+		config.TargetAddr = target
+	}
+	for i, fwd := range config.PlainForwards {
+		if _, err := netutil.NormalizeAddr(fwd.Listen); err != nil {
+			return fmt.Errorf("invalid plain forward listen address: %w", err)
+		}
+		target, err := netutil.NormalizeAddr(fwd.Target)
+		if err != nil {
+			return fmt.Errorf("invalid plain forward target: %w", err)
+		}
+		config.PlainForwards[i].Target = target
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tunnel/pkg/acl"
+)
+
+var ErrOutsideSchedule = errors.New("outside service window")
+
+func parseSchedule(specs []string) ([]*acl.Window, error) {
+	var windows []*acl.Window
+	for _, spec := range specs {
+		w, err := acl.ParseSchedule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse schedule: %w", err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func (s *Server) inSchedule() bool {
+	if len(s.schedule) == 0 {
+		return true
+	}
+	now := time.Now()
+	for _, w := range s.schedule {
+		if w.Contains(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const defaultScriptTimeout = 2 * time.Second
+
+type ScriptConfig struct {
+	Path    string
+	Timeout time.Duration
+}
+
+type scriptRequest struct {
+	Peer      string `json:"peer"`
+	IP        string `json:"ip"`
+	Transport string `json:"transport"`
+	Target    string `json:"target"`
+	Rule      string `json:"rule,omitempty"`
+}
+
+type scriptDecision struct {
+	Allow  *bool  `json:"allow"`
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+}
+
+type scriptHooks struct {
+	NopHooks
+	config ScriptConfig
+}
+
+func newScriptHooks(config ScriptConfig) (*scriptHooks, error) {
+	info, err := os.Stat(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load route script: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("route script %s is a directory", config.Path)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultScriptTimeout
+	}
+	return &scriptHooks{config: config}, nil
+}
+
+func (h *scriptHooks) OnDialTarget(ctx context.Context, info SessionInfo) (string, error) {
+	decision, err := h.run(ctx, info)
+	if err != nil {
+		log.Printf("[Server] 📜 路由脚本执行失败，拒绝会话 %s: %v", info.Peer, err)
+		return "", fmt.Errorf("route script failed")
+	}
+
+	if decision.Allow != nil && !*decision.Allow {
+		if decision.Reason == "" {
+			decision.Reason = "denied by route script"
+		}
+		return "", fmt.Errorf("%s", decision.Reason)
+	}
+	if decision.Target != "" {
+		return decision.Target, nil
+	}
+	return info.Target, nil
+}
+
+func (h *scriptHooks) run(ctx context.Context, info SessionInfo) (scriptDecision, error) {
+	var decision scriptDecision
+
+	input, err := json.Marshal(scriptRequest{
+		Peer:      info.Peer,
+		IP:        info.IP,
+		Transport: info.Transport,
+		Target:    info.Target,
+		Rule:      info.Rule,
+	})
+	if err != nil {
+		return decision, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.config.Path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = 100 * time.Millisecond
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return decision, fmt.Errorf("%w: %s", err, msg)
+		}
+		return decision, err
+	}
+
+	if err := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &decision); err != nil {
+		return decision, fmt.Errorf("invalid script output: %w", err)
+	}
+	return decision, nil
+}
@@ -1,59 +1,443 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
-	"strings"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"tunnel/pkg/acl"
+	"tunnel/pkg/admin"
+	"tunnel/pkg/clock"
+	"tunnel/pkg/cluster"
+	"tunnel/pkg/crash"
 	"tunnel/pkg/crypto"
+	"tunnel/pkg/health"
+	"tunnel/pkg/metrics"
+	"tunnel/pkg/netutil"
+	"tunnel/pkg/probe"
+	"tunnel/pkg/sessionlog"
 	"tunnel/pkg/transport"
 )
 
+const (
+	defaultHandshakeTimeout = 5 * time.Second
+	defaultDialTimeout      = 10 * time.Second
+	defaultUDPIdleTimeout   = 5 * time.Minute
+)
+
 type Config struct {
 	ListenAddr   string
 	TargetAddr   string
 	Password     string
+	KeyFile      string
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 
+	AllowDynamicTargets bool
+	DynamicTargetAllow  []string
+	DynamicTargetPorts  string
+
+	HandshakeTimeout time.Duration
+	DialTimeout      time.Duration
+	UDPIdleTimeout   time.Duration
+
 	EnableWS bool
 	WSConfig transport.WSConfig
 
+	TrustedProxies []string
+
+	ListenTLS bool
+
+	RawTLS         bool
+	RawTLSClientCA string
+
+	FIPS bool
+
+	ListenShards int
+
+	Batch crypto.BatchConfig
+
+	RelayEngine string
+
+	FrameDebug bool
+
+	DualProtocol bool
+
+	EnablePoll bool
+
+	MaxConnections int
+
+	DNSServer string
+
+	TargetMark netutil.SocketMark
+
+	ExpireAt time.Time
+
+	TargetTLS TargetTLSConfig
+
 	ACLConfig acl.Config
+
+	GuardConfig GuardConfig
+	ProbeConfig probe.Config
+
+	MetricsPush metrics.PushConfig
+
+	AdminConfig admin.Config
+
+	HealthListen string
+
+	SessionLog sessionlog.Config
+
+	Quota QuotaConfig
+
+	PeerLimit PeerLimitConfig
+
+	Schedule []string
+
+	Usage UsageConfig
+
+	Control ControlConfig
+
+	Cluster cluster.Config
+
+	Clock clock.Config
+
+	PlainForwards []PlainForward
+
+	MemoryLimit int64
+
+	RouteScript ScriptConfig
+
+	DrainTimeout time.Duration
 }
 
 type Server struct {
-	config Config
-	cipher *crypto.AESCipher
-	ln     net.Listener
-	acl    *acl.ACL
+	config  Config
+	cipher  *crypto.AESCipher
+	ln      *netutil.LimitListener
+	shards  []*netutil.LimitListener
+	sockets []net.Listener
+	acl     *acl.ACL
+	stats   *Stats
+	guard   *guard
+	probes  *probe.Logger
+	pusher  *metrics.Pusher
+	peers   *cluster.Node
+	admin   *admin.Server
+	health  *health.Server
+	dialer  *net.Dialer
+	egress  *egressPolicy
+	proxies trustedProxies
+	plain   []*plainForward
+
+	targetTLS  *tls.Config
+	sessions   *sessionlog.Logger
+	quota      *quota
+	peerLimits *peerLimits
+	schedule   []*acl.Window
+	usage      *usage
+	memory     *memoryBudget
+	hooks      hookChain
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	killOnce    sync.Once
+	killing     atomic.Bool
+	killed      chan struct{}
+	ready       chan struct{}
+	draining    atomic.Bool
+	maintenance atomic.Bool
+
+	logs     *crash.LogBuffer
+	controls sync.Map
+	active   sync.Map
 }
 
 func New(config Config) (*Server, error) {
-	cipher, err := crypto.NewAESCipher(config.Password)
+	if config.DualProtocol && !config.EnableWS {
+		return nil, fmt.Errorf("dual protocol listener requires WebSocket mode")
+	}
+	if config.EnablePoll && !config.EnableWS {
+		return nil, fmt.Errorf("long-polling transport requires WebSocket mode")
+	}
+	if config.ListenTLS && config.EnableWS {
+		return nil, fmt.Errorf("listen tls is for TCP mode, use WebSocket TLS instead")
+	}
+	if config.RawTLS {
+		if config.EnableWS {
+			return nil, fmt.Errorf("raw tls mode cannot be combined with WebSocket mode")
+		}
+		if config.RawTLSClientCA == "" {
+			return nil, fmt.Errorf("raw tls mode requires a client ca, it carries no password")
+		}
+		config.ListenTLS = true
+	}
+
+	if config.HandshakeTimeout <= 0 {
+		config.HandshakeTimeout = defaultHandshakeTimeout
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaultDialTimeout
+	}
+	if config.UDPIdleTimeout <= 0 {
+		config.UDPIdleTimeout = defaultUDPIdleTimeout
+	}
+
+	if err := config.TargetMark.Validate(); err != nil {
+		return nil, err
+	}
+	if err := netutil.ValidateRelayEngine(config.RelayEngine); err != nil {
+		return nil, err
+	}
+	if err := normalizeAddrs(&config); err != nil {
+		return nil, err
+	}
+
+	if !config.ExpireAt.IsZero() && !time.Now().Before(config.ExpireAt) {
+		return nil, fmt.Errorf("server expired at %s", config.ExpireAt.Format(time.RFC3339))
+	}
+
+	var cipher *crypto.AESCipher
+	var err error
+	if config.FIPS {
+		cipher, err = crypto.NewFIPSCipherFromFile(config.KeyFile)
+	} else {
+		cipher, err = crypto.NewCipher(config.Password, config.KeyFile)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
+	if cipher.FIPS() {
+		log.Printf("[Server] 🔐 FIPS 模式: AES-256-GCM，HKDF-SHA256 派生密钥 (模块: %s)", crypto.FIPSModule())
+	}
 
 	accessControl, err := acl.New(config.ACLConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ACL: %w", err)
 	}
 
-	return &Server{
+	probes, err := probe.New(config.ProbeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create probe log: %w", err)
+	}
+	created := false
+	defer func() {
+		if !created {
+			probes.Close()
+		}
+	}()
+
+	targetTLS, err := newTargetTLS(config.TargetTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := sessionlog.New(config.SessionLog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session log: %w", err)
+	}
+	defer func() {
+		if !created {
+			sessions.Close()
+		}
+	}()
+
+	schedule, err := parseSchedule(config.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	egress, err := newEgressPolicy(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	proxies, err := parseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	srv := &Server{
 		config: config,
 		cipher: cipher,
 		acl:    accessControl,
-	}, nil
+		stats:  stats,
+		guard:  newGuard(config.GuardConfig, accessControl, stats, probes),
+		probes: probes,
+		dialer: newDialer(config.DNSServer, config.TargetMark, config.DialTimeout),
+		egress: egress,
+
+		proxies:    proxies,
+		targetTLS:  targetTLS,
+		sessions:   sessions,
+		quota:      newQuota(config.Quota),
+		peerLimits: newPeerLimits(config.PeerLimit),
+		schedule:   schedule,
+		usage:      newUsage(config.Usage),
+		memory:     &memoryBudget{limit: config.MemoryLimit},
+		ctx:        ctx,
+		cancel:     cancel,
+		killed:     make(chan struct{}),
+		ready:      make(chan struct{}),
+	}
+
+	srv.guard.onBan = func() { srv.enforceACL("acl_banned") }
+	srv.setupControl()
+
+	if config.RouteScript.Path != "" {
+		script, err := newScriptHooks(config.RouteScript)
+		if err != nil {
+			return nil, err
+		}
+		srv.Use(script)
+		log.Printf("[Server] 📜 路由脚本: %s (超时 %v)", config.RouteScript.Path, script.config.Timeout)
+	}
+
+	if config.MetricsPush.Enable {
+		pusher, err := metrics.NewPusher(config.MetricsPush, srv.Stats)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metrics pusher: %w", err)
+		}
+		srv.pusher = pusher
+	}
+
+	if config.Cluster.Enable {
+		if config.Cluster.KeyFile != "" && config.Cluster.KeyFile == config.KeyFile {
+			return nil, fmt.Errorf("cluster key file must differ from the tunnel key file")
+		}
+		peers, err := cluster.New(config.Cluster, srv.clusterState, srv.mergeCluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cluster node: %w", err)
+		}
+		srv.peers = peers
+	}
+
+	if config.AdminConfig.Enable {
+		adminServer, err := admin.New(config.AdminConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create admin server: %w", err)
+		}
+		srv.admin = adminServer
+		srv.registerAdmin()
+	}
+
+	created = true
+	return srv, nil
+}
+
+func (s *Server) registerAdmin() {
+	stats := func() interface{} { return s.Stats() }
+	s.admin.HandleJSON("/stats", stats)
+	s.admin.HandleJSON("/sessions", func() interface{} { return s.Sessions() })
+	s.admin.HandleJSON("/version", func() interface{} { return s.Version() })
+	s.admin.HandleJSON("/config", func() interface{} { return s.EffectiveConfig() })
+	s.admin.HandleFunc("/metrics", s.handleMetrics)
+	s.admin.HandleFunc("/maintenance", s.handleMaintenance)
+	s.admin.PublishVar("tunnel", stats)
+	if s.config.AdminConfig.EnableKill {
+		s.admin.HandleFunc("/kill", s.handleKill)
+	}
 }
 
-func (s *Server) Start() error {
+func (s *Server) Start(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, s.cancel)
+	defer stop()
+
+	if err := s.CheckPorts(); err != nil {
+		return err
+	}
+
+	if s.admin != nil {
+		if err := s.admin.Start(); err != nil {
+			return err
+		}
+	}
+
+	if s.config.HealthListen != "" {
+		h, err := health.Start(s.config.HealthListen, "Server", nil, s.Readiness)
+		if err != nil {
+			return err
+		}
+		s.health = h
+	}
+
+	if s.pusher != nil {
+		s.pusher.Start()
+	}
+
+	if s.config.Clock.NTPServer != "" {
+		go clock.Check("Server", s.config.Clock)
+	}
+
+	if s.peers != nil {
+		if err := s.peers.Start(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.startPlainForwards(); err != nil {
+		return err
+	}
+
+	if s.config.DNSServer != "" {
+		log.Printf("[Server] 🔎 目标域名使用 DNS 服务器解析: %s", s.config.DNSServer)
+	}
+	if s.config.AllowDynamicTargets {
+		log.Printf("[Server] 🌐 允许动态目标 (内网放行: %v，端口: %s)", s.config.DynamicTargetAllow, portsOrAll(s.config.DynamicTargetPorts))
+	}
+	if s.config.Batch.Delay > 0 {
+		log.Printf("[Server] 📨 小包合并: 等待 %v，缓冲 %d 字节", s.config.Batch.Delay, s.config.Batch.Size)
+	}
+	if s.config.RelayEngine == netutil.RelayPooled {
+		log.Printf("[Server] ♻️ 转发引擎: pooled (空闲连接不占用读缓冲)")
+	}
+	if s.config.TargetMark.Enabled() {
+		log.Printf("[Server] 🏷️ 目标连接标记: DSCP %d，fwmark %d", s.config.TargetMark.DSCP, s.config.TargetMark.Mark)
+	}
+	if s.targetTLS != nil {
+		log.Printf("[Server] 🔐 以 TLS 连接默认目标: %s", s.config.TargetAddr)
+	}
+	if !s.config.ExpireAt.IsZero() {
+		log.Printf("[Server] ⏳ 到期时间: %s", s.config.ExpireAt.Format(time.RFC3339))
+		time.AfterFunc(time.Until(s.config.ExpireAt), func() {
+			log.Printf("[Server] ⏳ 已到期，停止服务")
+			s.Kill()
+		})
+	}
+	if len(s.schedule) > 0 {
+		log.Printf("[Server] 🕘 仅在服务时间窗口内接受会话: %v", s.config.Schedule)
+	}
+	if s.config.Usage.MaxSessions > 0 {
+		log.Printf("[Server] 🎟️ 会话次数上限: %d", s.config.Usage.MaxSessions)
+	}
+	if s.quota.enabled() {
+		log.Printf("[Server] 📦 流量配额: 单会话 %d 字节，单 IP 每日 %d 字节 (0 为不限)",
+			s.config.Quota.SessionBytes, s.config.Quota.DailyBytes)
+	}
+	if s.peerLimits.enabled() {
+		log.Printf("[Server] 🚦 单 IP 会话限制: 并发 %d 个，每分钟新建 %d 个 (0 为不限)",
+			s.config.PeerLimit.MaxSessions, s.config.PeerLimit.PerMinute)
+	}
+
+	if s.memory.enabled() {
+		go s.watchMemory()
+	}
+	go s.guard.run(s.ctx)
+
+	if s.config.DualProtocol {
+		return s.startDual()
+	}
 	if s.config.EnableWS {
 		return s.startWebSocket()
 	}
@@ -64,12 +448,45 @@ func (s *Server) startWebSocket() error {
 	log.Printf("[Server] 🌐 WebSocket 模式启动中...")
 	log.Printf("[Server] 🎯 目标地址: %s", s.config.TargetAddr)
 
-	wsServer := transport.NewWSServer(s.config.WSConfig, s.cipher, s.handleWSConnection)
+	server, cleanup, err := s.newHTTPServer()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := s.listen(); err != nil {
+		return err
+	}
+
+	if s.config.WSConfig.EnableTLS {
+		log.Printf("[Server] 🔒 启用 TLS，监听地址: %s%s", s.config.ListenAddr, s.config.WSConfig.Path)
+	} else {
+		log.Printf("[Server] 🚀 启动成功，监听地址: ws://%s%s", s.config.ListenAddr, s.config.WSConfig.Path)
+	}
+	return ignoreClosed(s.serveShards(server))
+}
+
+func (s *Server) newHTTPServer() (*http.Server, func(), error) {
+	wsServer := transport.NewWSServer(s.config.WSConfig, s.cipher, func(wsConn *transport.WSConn) {
+		s.handleWSConnection(wsConn.Request().Context(), wsConn)
+	})
+	wsServer.SetProbeHandler(func(r *http.Request, reason string) {
+		s.probes.LogRequest(s.clientIP(r), reason, r)
+	})
+	if s.config.EnablePoll {
+		poll := wsServer.SetPollHandler(func(conn net.Conn) {
+			s.handleTCPConnection(s.ctx, conn, transportPoll)
+		})
+		poll.SetRemoteResolver(s.clientIP)
+	}
+	if origins := s.config.WSConfig.AllowedOrigins; len(origins) > 0 {
+		log.Printf("[Server] 🌐 允许的 WebSocket Origin: %v", origins)
+	}
 
 	originalHandler := wsServer
 	wrappedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		clientIP := getClientIP(r)
-		if !s.acl.IsAllowed(clientIP) {
+		if !s.acl.IsRequestAllowed(s.aclRequest(r)) {
+			s.stats.ACLDenied.Add(1)
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
@@ -77,40 +494,222 @@ func (s *Server) startWebSocket() error {
 	})
 
 	server := &http.Server{
-		Addr:    s.config.ListenAddr,
-		Handler: wrappedHandler,
+		Addr:              s.config.ListenAddr,
+		Handler:           wrappedHandler,
+		ReadHeaderTimeout: s.config.HandshakeTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return s.ctx
+		},
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connContextKey{}, c)
+		},
+	}
+
+	if !s.config.WSConfig.EnableTLS {
+		return server, func() {}, nil
 	}
 
+	tlsConfig, err := transport.BuildServerTLSConfig(s.config.WSConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid tls config: %w", err)
+	}
+	server.TLSConfig = tlsConfig
+	transport.WatchFingerprints(tlsConfig, s.observeFingerprint)
+
+	reloader, err := transport.LoadServerCertificate(s.config.WSConfig, tlsConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return server, reloader.Close, nil
+}
+
+type connContextKey struct{}
+
+func (s *Server) aclRequest(r *http.Request) acl.Request {
+	req := acl.Request{
+		Addr:   s.clientIP(r),
+		Header: r.Header,
+		Path:   r.URL.Path,
+	}
+	if conn, ok := r.Context().Value(connContextKey{}).(net.Conn); ok {
+		if fp := transport.ConnFingerprint(conn); fp != nil {
+			req.JA3, req.JA4 = fp.JA3Hash, fp.JA4
+		}
+	}
+	return req
+}
+
+func (s *Server) serveShards(server *http.Server) error {
+	for _, ln := range s.shards[1:] {
+		go func(ln net.Listener) {
+			if err := ignoreClosed(s.serveHTTP(server, ln)); err != nil {
+				log.Printf("[Server] ⚠️ HTTP 服务异常退出: %v", err)
+			}
+		}(ln)
+	}
+	return s.serveHTTP(server, s.ln)
+}
+
+func (s *Server) serveHTTP(server *http.Server, ln net.Listener) error {
 	if s.config.WSConfig.EnableTLS {
-		log.Printf("[Server] 🔒 启用 TLS，监听地址: %s%s", s.config.ListenAddr, s.config.WSConfig.Path)
-		return server.ListenAndServeTLS(s.config.WSConfig.TLSCert, s.config.WSConfig.TLSKey)
+		return server.ServeTLS(transport.NewFingerprintListener(ln), "", "")
+	}
+	return server.Serve(ln)
+}
+
+func (s *Server) observeFingerprint(conn net.Conn, fp *transport.Fingerprint) {
+	s.stats.recordFingerprint(fp)
+	log.Printf("[Server] 🪪 TLS 指纹 %s: JA3=%s JA4=%s", conn.RemoteAddr(), fp.JA3Hash, fp.JA4)
+}
+
+func (s *Server) listen() error {
+	sockets, inherited, err := inheritedListeners()
+	if err != nil {
+		return err
+	}
+	if inherited {
+		log.Printf("[Server] ♻️ 已接管上一进程的监听: %s (%d 个 socket)", sockets[0].Addr(), len(sockets))
+	} else if sockets, err = s.openListeners(); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	if err := s.startPrefilter(sockets, inherited); err != nil {
+		closeAll(sockets)
+		return err
+	}
+
+	s.sockets = sockets
+	s.ln = netutil.NewLimitListener(s.hookListener(sockets[0]), s.config.MaxConnections, "Server")
+	s.shards = []*netutil.LimitListener{s.ln}
+	for _, sock := range sockets[1:] {
+		s.shards = append(s.shards, s.ln.Share(s.hookListener(sock)))
+	}
+	context.AfterFunc(s.ctx, s.closeListeners)
+	signalReady()
+	close(s.ready)
+
+	if s.config.MaxConnections > 0 {
+		log.Printf("[Server] 🚦 最大并发连接数: %d", s.config.MaxConnections)
+	}
+	return nil
+}
+
+func (s *Server) openListeners() ([]net.Listener, error) {
+	if s.config.ListenShards <= 1 {
+		ln, err := netutil.Listen(s.config.ListenAddr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{ln}, nil
 	}
 
-	log.Printf("[Server] 🚀 启动成功，监听地址: ws://%s%s", s.config.ListenAddr, s.config.WSConfig.Path)
-	return server.ListenAndServe()
+	sockets, err := netutil.ListenReusePort(s.config.ListenAddr, s.config.ListenShards)
+	if err != nil {
+		return nil, netutil.ExplainListenError(s.config.ListenAddr, err)
+	}
+	log.Printf("[Server] 🧩 SO_REUSEPORT 分片监听: %d 个 socket", len(sockets))
+	return sockets, nil
+}
+
+func (s *Server) CheckPorts() error {
+	if os.Getenv(listenFDEnv) != "" {
+		return nil
+	}
+
+	addrs := []string{s.config.ListenAddr, s.config.HealthListen}
+	if s.admin != nil {
+		addrs = append(addrs, s.config.AdminConfig.Listen)
+	}
+	if s.peers != nil {
+		addrs = append(addrs, s.config.Cluster.Listen)
+	}
+	for _, fwd := range s.config.PlainForwards {
+		addrs = append(addrs, fwd.Listen)
+	}
+	return netutil.CheckListen(addrs...)
+}
+
+func closeAll(listeners []net.Listener) {
+	for _, ln := range listeners {
+		ln.Close()
+	}
 }
 
-func (s *Server) handleWSConnection(wsConn *transport.WSConn) {
+func ignoreClosed(err error) error {
+	if err == nil || errors.Is(err, http.ErrServerClosed) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) handleWSConnection(ctx context.Context, wsConn *transport.WSConn) {
+	defer crash.Recover("server.ws")
 	defer wsConn.Close()
+	ctx, cancel := netutil.CloseOnDone(ctx, wsConn)
+	defer cancel()
 	clientAddr := wsConn.RemoteAddr().String()
+	clientIP := s.clientIP(wsConn.Request())
 	log.Printf("[Server] 📥 新 WebSocket 连接: %s", clientAddr)
 
+	s.stats.TotalConnections.Add(1)
+	s.stats.ActiveConnections.Add(1)
+	defer s.stats.ActiveConnections.Add(-1)
+
+	start := time.Now()
+	wsConn.SetReadDeadline(start.Add(s.config.HandshakeTimeout))
 	targetData, err := wsConn.ReadEncrypted()
 	if err != nil {
+		if netutil.IsTimeout(err) {
+			log.Printf("[Server] ⏱️ 握手超时 (%v): %s", s.config.HandshakeTimeout, clientAddr)
+			s.guard.recordTimeout(clientIP, time.Since(start))
+			return
+		}
 		log.Printf("[Server] ❌ 读取目标地址失败: %v", err)
+		if errors.Is(err, crypto.ErrDecrypt) {
+			s.stats.DecryptFailures.Add(1)
+		}
+		s.guard.recordFailure(clientIP)
+		s.hooks.OnError(ctx, SessionInfo{Peer: clientAddr, IP: clientIP, Transport: transportWebSocket}, err)
+		return
+	}
+	wsConn.SetReadDeadline(time.Time{})
+
+	targetAddr, ok := normalizeHandshake(string(targetData))
+	if !ok {
+		log.Printf("[Server] ❌ 握手校验失败: %s", clientAddr)
+		s.stats.DecryptFailures.Add(1)
+		s.guard.recordFailure(clientIP)
 		return
 	}
+	s.guard.recordSuccess(clientIP)
 
-	targetAddr := string(targetData)
-	if targetAddr == "USE_DEFAULT" {
-		targetAddr = s.config.TargetAddr
+	if targetAddr == controlTarget {
+		s.serveControl(wsConn, clientIP)
+		return
 	}
 
-	log.Printf("[Server] 🔗 连接目标: %s", targetAddr)
+	check := &aclCheck{req: s.aclRequest(wsConn.Request()), transport: acl.TransportHTTP}
+	_, rule := s.acl.Evaluate(check.req, check.transport)
+	sess := newSession(clientAddr, transportWebSocket, rule)
+	sess.ip = clientIP
+	sess.conn, sess.check = wsConn, check
+	defer s.finishSession(ctx, sess)
+
+	targetAddr = s.sessionTarget(targetAddr, clientAddr)
+	sess.target = targetAddr
+
+	if err := s.admitSession(ctx, sess); err != nil {
+		wsConn.WriteEncrypted([]byte("ERROR:" + err.Error()))
+		return
+	}
+
+	if targetAddr == udpAssociateTarget {
+		s.relayUDP(ctx, wsConn, sess)
+		return
+	}
 
-	targetConn, err := net.DialTimeout("tcp", targetAddr, 10*time.Second)
+	targetConn, err := s.openTarget(ctx, sess)
 	if err != nil {
-		log.Printf("[Server] ❌ 连接目标失败: %v", err)
 		wsConn.WriteEncrypted([]byte("ERROR:" + err.Error()))
 		return
 	}
@@ -118,75 +717,208 @@ func (s *Server) handleWSConnection(wsConn *transport.WSConn) {
 
 	if err := wsConn.WriteEncrypted([]byte("OK")); err != nil {
 		log.Printf("[Server] ❌ 发送响应失败: %v", err)
+		s.hooks.OnError(ctx, sess.info(), err)
 		return
 	}
 
-	log.Printf("[Server] ✅ WebSocket 隧道建立成功: %s <-> %s", clientAddr, targetAddr)
+	log.Printf("[Server] ✅ WebSocket 隧道建立成功: %s <-> %s", clientAddr, sess.target)
 
-	transport.BridgeWSToTCP(wsConn, targetConn)
+	transport.BridgeWSToTCP(wsConn, targetConn, s.config.Batch, s.config.RelayEngine, s.timeouts(), func(err error) {
+		sess.end(clientCloseReason(err))
+	})
 
-	log.Printf("[Server] 🔌 WebSocket 连接关闭: %s", clientAddr)
+	log.Printf("[Server] 🔌 WebSocket 连接关闭 (%s): %s", sess.closeReason(), clientAddr)
 }
 
 func (s *Server) startTCP() error {
-	ln, err := net.Listen("tcp", s.config.ListenAddr)
-	if err != nil {
-		return fmt.Errorf("failed to listen: %w", err)
+	var tlsConfig *tls.Config
+	if s.config.ListenTLS {
+		var err error
+		tlsConfig, err = transport.BuildServerTLSConfig(s.config.WSConfig)
+		if err != nil {
+			return fmt.Errorf("invalid tls config: %w", err)
+		}
+		reloader, err := transport.LoadServerCertificate(s.config.WSConfig, tlsConfig)
+		if err != nil {
+			return err
+		}
+		defer reloader.Close()
+		transport.WatchFingerprints(tlsConfig, s.observeFingerprint)
+		if s.config.RawTLS {
+			if err := loadClientCAs(tlsConfig, s.config.RawTLSClientCA); err != nil {
+				return err
+			}
+		}
 	}
-	s.ln = ln
 
-	log.Printf("[Server] 🚀 TCP 模式启动成功，监听地址: %s", s.config.ListenAddr)
+	if err := s.listen(); err != nil {
+		return err
+	}
+
+	if s.config.RawTLS {
+		log.Printf("[Server] 🔓 原始 TLS 模式启动成功 (无自定义分帧，需客户端证书)，监听地址: %s", s.config.ListenAddr)
+	} else if tlsConfig != nil {
+		log.Printf("[Server] 🔒 TCP 模式 (TLS) 启动成功，监听地址: %s", s.config.ListenAddr)
+	} else {
+		log.Printf("[Server] 🚀 TCP 模式启动成功，监听地址: %s", s.config.ListenAddr)
+	}
 	log.Printf("[Server] 🎯 目标地址: %s", s.config.TargetAddr)
+	if s.config.FrameDebug {
+		log.Printf("[Server] 🩺 帧校验调试模式已启用 (两端需同时启用)")
+	}
 
+	return s.acceptLoop(func(conn net.Conn) {
+		if !s.allowRaw(conn) {
+			return
+		}
+		if s.config.RawTLS {
+			go s.handleRawTLS(s.ctx, tls.Server(transport.NewFingerprintConn(conn), tlsConfig))
+			return
+		}
+		if tlsConfig != nil {
+			go s.handleTCPConnection(s.ctx, tls.Server(transport.NewFingerprintConn(conn), tlsConfig), transportTLS)
+			return
+		}
+		go s.handleTCPConnection(s.ctx, conn, transportTCP)
+	})
+}
+
+func (s *Server) acceptLoop(handle func(net.Conn)) error {
+	var wg sync.WaitGroup
+	for _, ln := range s.shards[1:] {
+		wg.Add(1)
+		go func(ln net.Listener) {
+			defer wg.Done()
+			defer crash.Recover("server.accept")
+			s.acceptShard(ln, handle)
+		}(ln)
+	}
+	s.acceptShard(s.ln, handle)
+	wg.Wait()
+	return nil
+}
+
+func (s *Server) acceptShard(ln net.Listener, handle func(net.Conn)) {
+	var backoff netutil.Backoff
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			if strings.Contains(err.Error(), "use of closed network connection") {
-				return nil
+			if netutil.IsClosed(err) {
+				return
 			}
-			log.Printf("[Server] ⚠️ Accept 错误: %v", err)
+			netutil.HandleAcceptError("Server", err, &backoff)
 			continue
 		}
+		backoff.Reset()
 
-		if !s.acl.IsAllowed(conn.RemoteAddr().String()) {
-			conn.Close()
-			continue
-		}
+		handle(conn)
+	}
+}
 
-		go s.handleTCPConnection(conn)
+func (s *Server) closeListeners() {
+	for _, ln := range s.shards {
+		ln.Close()
 	}
 }
 
-func (s *Server) Stop() error {
-	if s.ln != nil {
-		return s.ln.Close()
+func (s *Server) allowRaw(conn net.Conn) bool {
+	if !s.acl.IsAllowed(conn.RemoteAddr().String()) {
+		s.stats.ACLDenied.Add(1)
+		conn.Close()
+		return false
 	}
+	return true
+}
+
+func (s *Server) Stop() error {
+	defer s.probes.Close()
+	defer s.sessions.Close()
+	s.stopAuxiliary()
+	s.closeListeners()
 	return nil
 }
 
-func (s *Server) handleTCPConnection(clientConn net.Conn) {
+func (s *Server) handleTCPConnection(ctx context.Context, clientConn net.Conn, transportName string) {
+	defer crash.Recover("server.tcp")
 	defer clientConn.Close()
+	ctx, cancel := netutil.CloseOnDone(ctx, clientConn)
+	defer cancel()
 	clientAddr := clientConn.RemoteAddr().String()
 	log.Printf("[Server] 📥 新 TCP 连接来自: %s", clientAddr)
 
-	cryptoConn := crypto.NewCryptoConn(clientConn, s.cipher)
+	s.stats.TotalConnections.Add(1)
+	s.stats.ActiveConnections.Add(1)
+	defer s.stats.ActiveConnections.Add(-1)
+
+	start := time.Now()
+	clientConn.SetReadDeadline(start.Add(s.config.HandshakeTimeout))
+
+	conn, ok := s.guard.inspect(clientConn)
+	if !ok {
+		return
+	}
+
+	cryptoConn := crypto.NewCryptoConn(conn, s.cipher)
+	cryptoConn.SetDebug(s.config.FrameDebug)
 
+	cryptoConn.SetFrameTimeout(0)
 	targetData, err := cryptoConn.ReadEncrypted()
+	cryptoConn.SetFrameTimeout(crypto.DefaultFrameTimeout)
+	clientConn.SetReadDeadline(time.Time{})
 	if err != nil {
+		if netutil.IsTimeout(err) {
+			log.Printf("[Server] ⏱️ 握手超时 (%v): %s", s.config.HandshakeTimeout, clientAddr)
+			s.guard.recordTimeout(clientAddr, time.Since(start))
+			return
+		}
 		log.Printf("[Server] ❌ 读取目标地址失败: %v", err)
+		if errors.Is(err, crypto.ErrDecrypt) {
+			s.stats.DecryptFailures.Add(1)
+		}
+		s.guard.recordFailure(clientAddr)
+		s.hooks.OnError(ctx, SessionInfo{Peer: clientAddr, IP: netutil.NormalizeIP(clientAddr), Transport: transportName}, err)
 		return
 	}
 
-	targetAddr := string(targetData)
-	if targetAddr == "USE_DEFAULT" {
-		targetAddr = s.config.TargetAddr
+	targetAddr, ok := normalizeHandshake(string(targetData))
+	if !ok {
+		log.Printf("[Server] ❌ 握手校验失败: %s", clientAddr)
+		s.stats.DecryptFailures.Add(1)
+		s.guard.recordFailure(clientAddr)
+		return
 	}
+	s.guard.recordSuccess(clientAddr)
 
-	log.Printf("[Server] 🔗 连接目标: %s", targetAddr)
+	if targetAddr == controlTarget {
+		s.serveControl(cryptoConn, clientAddr)
+		return
+	}
+
+	var rule string
+	var check *aclCheck
+	if transportName != transportPoll {
+		check = &aclCheck{req: acl.Request{Addr: clientAddr}, transport: acl.TransportTCP}
+		_, rule = s.acl.Evaluate(check.req, check.transport)
+	}
+	sess := newSession(clientAddr, transportName, rule)
+	sess.conn, sess.check = clientConn, check
+	defer s.finishSession(ctx, sess)
+
+	targetAddr = s.sessionTarget(targetAddr, clientAddr)
+	sess.target = targetAddr
+
+	if err := s.admitSession(ctx, sess); err != nil {
+		cryptoConn.WriteEncrypted([]byte("ERROR:" + err.Error()))
+		return
+	}
 
-	targetConn, err := net.DialTimeout("tcp", targetAddr, 10*time.Second)
+	if targetAddr == udpAssociateTarget {
+		s.relayUDP(ctx, cryptoConn, sess)
+		return
+	}
+
+	targetConn, err := s.openTarget(ctx, sess)
 	if err != nil {
-		log.Printf("[Server] ❌ 连接目标失败: %v", err)
 		cryptoConn.WriteEncrypted([]byte("ERROR:" + err.Error()))
 		return
 	}
@@ -194,38 +926,65 @@ func (s *Server) handleTCPConnection(clientConn net.Conn) {
 
 	if err := cryptoConn.WriteEncrypted([]byte("OK")); err != nil {
 		log.Printf("[Server] ❌ 发送响应失败: %v", err)
+		s.hooks.OnError(ctx, sess.info(), err)
 		return
 	}
 
-	log.Printf("[Server] ✅ TCP 隧道建立成功: %s <-> %s", clientAddr, targetAddr)
+	log.Printf("[Server] ✅ TCP 隧道建立成功: %s <-> %s", clientAddr, sess.target)
+
+	timeouts := s.timeouts()
+	cryptoConn.SetWriteTimeout(timeouts.Write)
+	idle := timeouts.Idle(cryptoConn, targetConn)
 
 	var wg sync.WaitGroup
 	wg.Add(2)
 
+	closeBoth := func() {
+		clientConn.Close()
+		targetConn.Close()
+	}
+
 	go func() {
 		defer wg.Done()
-		s.forwardFromClient(cryptoConn, targetConn)
+		defer closeBoth()
+		defer crash.Recover("server.forward")
+		s.forwardFromClient(ctx, cryptoConn, targetConn, sess, idle)
 	}()
 
 	go func() {
 		defer wg.Done()
-		s.forwardToClient(targetConn, cryptoConn)
+		defer closeBoth()
+		defer crash.Recover("server.forward")
+		s.forwardToClient(targetConn, cryptoConn, idle)
 	}()
 
 	wg.Wait()
-	log.Printf("[Server] 🔌 TCP 连接关闭: %s", clientAddr)
+	log.Printf("[Server] 🔌 TCP 连接关闭 (%s): %s", sess.closeReason(), clientAddr)
+}
+
+func (s *Server) timeouts() netutil.Timeouts {
+	return netutil.Timeouts{Read: s.config.ReadTimeout, Write: s.config.WriteTimeout}
 }
 
-func (s *Server) forwardFromClient(src *crypto.CryptoConn, dst net.Conn) {
+func (s *Server) forwardFromClient(ctx context.Context, src *crypto.CryptoConn, dst net.Conn, sess *session, idle *netutil.Idle) {
 	for {
 		data, err := src.ReadEncrypted()
 		if err != nil {
-			if err != io.EOF {
+			sess.end(clientCloseReason(err))
+			if errors.Is(err, crypto.ErrFrameDesync) {
+				log.Printf("[Server] ❌ 帧失步，已关闭连接: %v", err)
+				s.hooks.OnError(ctx, sess.info(), err)
+				dst.Close()
+			} else if netutil.IsTimeout(err) {
+				log.Printf("[Server] ⏱️ 会话空闲超过 %v，关闭连接: %s", s.config.ReadTimeout, sess.peer)
+			} else if !netutil.IsClosed(err) {
 				log.Printf("[Server] 读取客户端数据错误: %v", err)
 			}
 			return
 		}
+		idle.Touch()
 
+		s.timeouts().ArmWrite(dst)
 		if _, err := dst.Write(data); err != nil {
 			log.Printf("[Server] 写入目标数据错误: %v", err)
 			return
@@ -233,18 +992,26 @@ func (s *Server) forwardFromClient(src *crypto.CryptoConn, dst net.Conn) {
 	}
 }
 
-func (s *Server) forwardToClient(src net.Conn, dst *crypto.CryptoConn) {
-	buf := make([]byte, 32*1024)
+func (s *Server) forwardToClient(src net.Conn, dst *crypto.CryptoConn, idle *netutil.Idle) {
+	out := crypto.NewBatcher(dst, s.config.Batch)
+	defer out.Close()
+
+	in := netutil.NewRelayReader(src, s.config.RelayEngine)
+	defer in.Close()
+
 	for {
-		n, err := src.Read(buf)
+		data, err := in.Next()
 		if err != nil {
-			if err != io.EOF {
+			if netutil.IsTimeout(err) {
+				log.Printf("[Server] ⏱️ 会话空闲超过 %v，关闭连接", s.config.ReadTimeout)
+			} else if !netutil.IsClosed(err) {
 				log.Printf("[Server] 读取目标数据错误: %v", err)
 			}
 			return
 		}
+		idle.Touch()
 
-		if err := dst.WriteEncrypted(buf[:n]); err != nil {
+		if err := out.WriteEncrypted(data); err != nil {
 			log.Printf("[Server] 写入客户端数据错误: %v", err)
 			return
 		}
@@ -255,21 +1022,29 @@ func (s *Server) GetACL() *acl.ACL {
 	return s.acl
 }
 
-func getClientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
+func (s *Server) Stats() map[string]interface{} {
+	stats := s.stats.Snapshot()
+	stats["acl"] = s.acl.Stats()
+	stats["maintenance"] = s.maintenance.Load()
+	if s.quota.enabled() {
+		stats["quota"] = s.quota.Stats()
 	}
-
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+	if s.peerLimits.enabled() {
+		stats["peer_limit"] = s.peerLimits.Stats()
 	}
-
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+	if s.usage.enabled() {
+		stats["usage"] = s.usage.Stats()
+	}
+	if s.peers != nil {
+		stats["cluster"] = s.peers.Stats()
+	}
+	if len(s.plain) > 0 {
+		stats["plain_forwards"] = s.plainStats()
+	}
+	if s.ln != nil {
+		stats["open_connections"] = s.ln.Open()
+		stats["max_connections"] = s.ln.Max()
+		stats["conn_limit_rejected"] = s.ln.Rejected()
 	}
-	return host
+	return stats
 }
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tunnel/pkg/acl"
+	"tunnel/pkg/control"
+	"tunnel/pkg/crypto"
+	"tunnel/pkg/netutil"
+	"tunnel/pkg/sessionlog"
+	"tunnel/pkg/transport"
+)
+
+const (
+	transportTCP       = "tcp"
+	transportTLS       = "tcp+tls"
+	transportRawTLS    = "raw+tls"
+	transportWebSocket = "websocket"
+	transportPoll      = "poll"
+)
+
+type session struct {
+	start     time.Time
+	peer      string
+	ip        string
+	target    string
+	transport string
+	rule      string
+
+	up   atomic.Int64
+	down atomic.Int64
+
+	conn  io.Closer
+	check *aclCheck
+
+	once   sync.Once
+	reason string
+
+	quotaOnce sync.Once
+	limited   bool
+	reserved  bool
+}
+
+func newSession(peer, transport, rule string) *session {
+	return &session{
+		start:     time.Now(),
+		peer:      peer,
+		ip:        netutil.NormalizeIP(peer),
+		transport: transport,
+		rule:      rule,
+	}
+}
+
+type aclCheck struct {
+	req       acl.Request
+	transport string
+}
+
+func (sess *session) end(reason string) {
+	sess.once.Do(func() {
+		sess.reason = reason
+	})
+}
+
+func (sess *session) closeReason() string {
+	sess.end("unknown")
+	return sess.reason
+}
+
+func (sess *session) kill(reason string) {
+	sess.end(reason)
+	if sess.conn != nil {
+		sess.conn.Close()
+	}
+}
+
+func clientCloseReason(err error) string {
+	switch {
+	case errors.Is(err, crypto.ErrFrameDesync):
+		return "frame_desync"
+	case errors.Is(err, crypto.ErrDecrypt):
+		return "decrypt_error"
+	case errors.Is(err, transport.ErrPongTimeout):
+		return "pong_timeout"
+	case netutil.IsTimeout(err):
+		return "idle_timeout"
+	}
+	return "client_closed"
+}
+
+func (sess *session) info() SessionInfo {
+	return SessionInfo{
+		Start:       sess.start,
+		Peer:        sess.peer,
+		IP:          sess.ip,
+		Transport:   sess.transport,
+		Target:      sess.target,
+		Rule:        sess.rule,
+		BytesUp:     sess.up.Load(),
+		BytesDown:   sess.down.Load(),
+		CloseReason: sess.reason,
+	}
+}
+
+func (s *Server) finishSession(ctx context.Context, sess *session) {
+	sess.end("unknown")
+	s.active.Delete(sess)
+	if sess.limited {
+		s.peerLimits.release(sess.ip)
+	}
+	if sess.reserved {
+		s.usage.release()
+	}
+	s.stats.recordClose(sess.reason)
+	if sess.reason == "decrypt_error" {
+		s.stats.DecryptFailures.Add(1)
+	}
+	s.notifyControl(control.EventSessionEnd, map[string]interface{}{
+		"peer":       sess.peer,
+		"target":     sess.target,
+		"transport":  sess.transport,
+		"reason":     sess.reason,
+		"bytes_up":   sess.up.Load(),
+		"bytes_down": sess.down.Load(),
+	})
+	s.sessions.Log(sessionlog.Record{
+		Start:       sess.start,
+		Peer:        sess.peer,
+		Target:      sess.target,
+		Transport:   sess.transport,
+		BytesUp:     sess.up.Load(),
+		BytesDown:   sess.down.Load(),
+		CloseReason: sess.reason,
+		Rule:        sess.rule,
+	})
+	s.hooks.OnClose(ctx, sess.info())
+}
+
+var ErrDynamicTargets = errors.New("dynamic targets disabled")
+
+func (s *Server) sessionTarget(requested, peer string) string {
+	switch {
+	case requested == "USE_DEFAULT":
+		return s.config.TargetAddr
+	case requested == udpAssociateTarget || s.config.AllowDynamicTargets:
+		return requested
+	}
+	log.Printf("[Server] ↪️ 未允许动态目标，忽略 %s 请求的 %s，改连默认目标", peer, requested)
+	return s.config.TargetAddr
+}
+
+func (s *Server) admitSession(ctx context.Context, sess *session) error {
+	if sess.target == udpAssociateTarget && !s.config.AllowDynamicTargets {
+		log.Printf("[Server] 🚫 未允许动态目标，拒绝 UDP 中继: %s", sess.peer)
+		sess.end("dynamic_target_denied")
+		return ErrDynamicTargets
+	}
+
+	if !s.memory.admit() {
+		log.Printf("[Server] 🧯 内存接近上限，拒绝会话: %s", sess.peer)
+		s.stats.MemoryRejected.Add(1)
+		sess.end("memory_limit")
+		return ErrMemoryLimit
+	}
+
+	if s.maintenance.Load() {
+		log.Printf("[Server] 🚧 维护模式，拒绝新会话: %s", sess.peer)
+		sess.end("maintenance")
+		return ErrMaintenance
+	}
+
+	if !s.inSchedule() {
+		log.Printf("[Server] 🕘 不在服务时间窗口内，拒绝会话: %s", sess.peer)
+		sess.end("outside_schedule")
+		return ErrOutsideSchedule
+	}
+
+	if err := s.peerLimits.admit(sess.ip); err != nil {
+		log.Printf("[Server] 🚦 拒绝会话 (%v): %s", err, sess.ip)
+		sess.end("peer_limit")
+		return err
+	}
+	sess.limited = s.peerLimits.enabled()
+
+	if !s.quota.admit(sess.ip) {
+		log.Printf("[Server] 📦 今日流量配额已用尽，拒绝会话: %s", sess.ip)
+		sess.end("quota_exceeded")
+		return ErrDailyQuota
+	}
+
+	if err := s.usage.admit(); err != nil {
+		log.Printf("[Server] 🔒 拒绝会话 (%v): %s", err, sess.ip)
+		sess.end("limit_reached")
+		return err
+	}
+	sess.reserved = s.usage.enabled()
+
+	if err := s.hooks.OnHandshake(ctx, sess.info()); err != nil {
+		log.Printf("[Server] 🪝 钩子拒绝会话 (%v): %s", err, sess.peer)
+		sess.end("hook_rejected")
+		return err
+	}
+
+	s.acl.PinClient(sess.ip)
+	s.active.Store(sess, struct{}{})
+	return nil
+}
+
+func (s *Server) killSessions(reason string, match func(*session) bool) int {
+	killed := 0
+	s.active.Range(func(key, _ interface{}) bool {
+		if sess := key.(*session); match(sess) {
+			sess.kill(reason)
+			killed++
+		}
+		return true
+	})
+	return killed
+}
+
+func (s *Server) Sessions() []SessionInfo {
+	infos := []SessionInfo{}
+	s.active.Range(func(key, _ interface{}) bool {
+		infos = append(infos, key.(*session).info())
+		return true
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Start.Before(infos[j].Start)
+	})
+	return infos
+}
+
+func (s *Server) enforceACL(reason string) {
+	killed := s.killSessions(reason, func(sess *session) bool {
+		if sess.check == nil {
+			return false
+		}
+		allowed, _ := s.acl.Evaluate(sess.check.req, sess.check.transport)
+		return !allowed
+	})
+	if killed > 0 {
+		log.Printf("[Server] 🚫 ACL 变更，断开 %d 个会话 (%s)", killed, reason)
+	}
+}
+
+func (s *Server) openTarget(ctx context.Context, sess *session) (*sessionConn, error) {
+	target, err := s.hooks.OnDialTarget(ctx, sess.info())
+	if err != nil {
+		log.Printf("[Server] 🪝 钩子拒绝连接目标 (%v): %s", err, sess.target)
+		sess.end("hook_rejected")
+		return nil, err
+	}
+	if target != sess.target {
+		log.Printf("[Server] 🪝 钩子改写目标: %s -> %s", sess.target, target)
+		sess.target = target
+	}
+
+	dialAddr := target
+	if target != s.config.TargetAddr {
+		vetted, err := s.vetTarget(ctx, target)
+		if err != nil {
+			log.Printf("[Server] 🚫 拒绝动态目标 %s (%v): %s", target, err, sess.peer)
+			sess.end("target_forbidden")
+			return nil, err
+		}
+		dialAddr = vetted
+	}
+
+	log.Printf("[Server] 🔗 连接目标: %s", target)
+
+	dialed, err := s.dialTarget(ctx, dialAddr)
+	if err != nil {
+		log.Printf("[Server] ❌ 连接目标失败: %v", err)
+		sess.end("dial_failed")
+		s.hooks.OnError(ctx, sess.info(), err)
+		return nil, err
+	}
+	s.commitUsage(sess)
+	return &sessionConn{Conn: dialed, sess: sess, quota: s.quota}, nil
+}
+
+func (s *Server) commitUsage(sess *session) {
+	if sess.reserved {
+		sess.reserved = false
+		s.usage.commit()
+	}
+}
+
+type sessionConn struct {
+	net.Conn
+	sess  *session
+	quota *quota
+}
+
+func (c *sessionConn) Unwrap() net.Conn {
+	return c.Conn
+}
+
+func (c *sessionConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if qerr := c.quota.charge(c.sess, n); qerr != nil {
+		return 0, qerr
+	}
+	c.sess.down.Add(int64(n))
+	if err != nil {
+		switch {
+		case errors.Is(err, io.EOF):
+			c.sess.end("target_closed")
+		case netutil.IsTimeout(err):
+			c.sess.end("idle_timeout")
+		case netutil.IsClosed(err):
+			c.sess.end("client_closed")
+		default:
+			c.sess.end("target_error")
+		}
+	}
+	return n, err
+}
+
+func (c *sessionConn) Write(p []byte) (int, error) {
+	if err := c.quota.charge(c.sess, len(p)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(p)
+	c.sess.up.Add(int64(n))
+	if err != nil {
+		c.sess.end("target_error")
+	}
+	return n, err
+}
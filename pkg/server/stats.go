@@ -0,0 +1,107 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"tunnel/pkg/transport"
+)
+
+const maxFingerprints = 1024
+
+type Stats struct {
+	TotalConnections  atomic.Int64
+	ActiveConnections atomic.Int64
+	ACLDenied         atomic.Int64
+
+	ProbesTLS         atomic.Int64
+	ProbesHTTP        atomic.Int64
+	ProbesOther       atomic.Int64
+	ProbesTimeout     atomic.Int64
+	HandshakeFailures atomic.Int64
+	DecryptFailures   atomic.Int64
+	Bans              atomic.Int64
+	MemoryRejected    atomic.Int64
+
+	fpMu sync.Mutex
+	ja3  map[string]int64
+	ja4  map[string]int64
+
+	closeMu sync.Mutex
+	closes  map[string]int64
+}
+
+func (s *Stats) recordClose(reason string) {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+
+	if s.closes == nil {
+		s.closes = make(map[string]int64)
+	}
+	s.closes[reason]++
+}
+
+func (s *Stats) closeReasons() map[string]int64 {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+
+	closes := make(map[string]int64, len(s.closes))
+	for k, v := range s.closes {
+		closes[k] = v
+	}
+	return closes
+}
+
+func (s *Stats) recordFingerprint(fp *transport.Fingerprint) {
+	s.fpMu.Lock()
+	defer s.fpMu.Unlock()
+
+	if s.ja3 == nil {
+		s.ja3 = make(map[string]int64)
+		s.ja4 = make(map[string]int64)
+	}
+	countFingerprint(s.ja3, fp.JA3Hash)
+	countFingerprint(s.ja4, fp.JA4)
+}
+
+func countFingerprint(counts map[string]int64, key string) {
+	if _, ok := counts[key]; !ok && len(counts) >= maxFingerprints {
+		key = "other"
+	}
+	counts[key]++
+}
+
+func (s *Stats) fingerprints() (map[string]int64, map[string]int64) {
+	s.fpMu.Lock()
+	defer s.fpMu.Unlock()
+
+	ja3 := make(map[string]int64, len(s.ja3))
+	for k, v := range s.ja3 {
+		ja3[k] = v
+	}
+	ja4 := make(map[string]int64, len(s.ja4))
+	for k, v := range s.ja4 {
+		ja4[k] = v
+	}
+	return ja3, ja4
+}
+
+func (s *Stats) Snapshot() map[string]interface{} {
+	ja3, ja4 := s.fingerprints()
+	return map[string]interface{}{
+		"total_connections":  s.TotalConnections.Load(),
+		"active_connections": s.ActiveConnections.Load(),
+		"acl_denied":         s.ACLDenied.Load(),
+		"probes_tls":         s.ProbesTLS.Load(),
+		"probes_http":        s.ProbesHTTP.Load(),
+		"probes_other":       s.ProbesOther.Load(),
+		"probes_timeout":     s.ProbesTimeout.Load(),
+		"handshake_failures": s.HandshakeFailures.Load(),
+		"decrypt_failures":   s.DecryptFailures.Load(),
+		"bans":               s.Bans.Load(),
+		"memory_rejected":    s.MemoryRejected.Load(),
+		"close_reasons":      s.closeReasons(),
+		"tls_ja3":            ja3,
+		"tls_ja4":            ja4,
+	}
+}
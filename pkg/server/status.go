@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"os"
+
+	"tunnel/pkg/status"
+	"tunnel/pkg/version"
+)
+
+var (
+	ErrStopped  = errors.New("stopped")
+	ErrStarting = errors.New("starting")
+	ErrDraining = errors.New("draining")
+)
+
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+func (s *Server) Readiness() error {
+	select {
+	case <-s.killed:
+		return ErrStopped
+	default:
+	}
+	select {
+	case <-s.ready:
+	default:
+		return ErrStarting
+	}
+	if s.draining.Load() {
+		return ErrDraining
+	}
+	if s.maintenance.Load() {
+		return ErrMaintenance
+	}
+	if !s.memory.admit() {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+func (s *Server) Status() status.Status {
+	st := status.Status{
+		Mode:       "server",
+		Version:    version.Version,
+		Transports: s.transports(),
+		PID:        os.Getpid(),
+	}
+
+	if s.ln != nil {
+		st.Listeners = append(st.Listeners, status.Listener{Name: "tunnel", Address: s.ln.Addr().String()})
+	}
+	if s.admin != nil {
+		st.Listeners = append(st.Listeners, status.Listener{Name: "admin", Address: s.admin.Addr()})
+	}
+	if s.health != nil {
+		st.Listeners = append(st.Listeners, status.Listener{Name: "health", Address: s.health.Addr()})
+	}
+	if s.peers != nil {
+		st.Listeners = append(st.Listeners, status.Listener{Name: "cluster", Address: s.peers.Addr()})
+	}
+	for _, fwd := range s.plain {
+		name := "plain"
+		if fwd.Name != "" {
+			name = "plain:" + fwd.Name
+		}
+		st.Listeners = append(st.Listeners, status.Listener{Name: name, Address: fwd.ln.Addr().String()})
+	}
+	return st
+}
+
+func (s *Server) Version() version.Info {
+	info := version.Get()
+	info.Transports = s.transports()
+	info.Ciphers = []string{s.cipher.Name()}
+	return info
+}
+
+func (s *Server) EffectiveConfig() Config {
+	cfg := s.config
+	cfg.Password = status.Mask(cfg.Password)
+	cfg.AdminConfig.Token = status.Mask(cfg.AdminConfig.Token)
+	cfg.Control.Token = status.Mask(cfg.Control.Token)
+	return cfg
+}
+
+func (s *Server) transports() []string {
+	var transports []string
+	if !s.config.EnableWS || s.config.DualProtocol {
+		if s.config.RawTLS {
+			transports = append(transports, transportRawTLS)
+		} else if s.config.ListenTLS {
+			transports = append(transports, transportTLS)
+		} else {
+			transports = append(transports, transportTCP)
+		}
+	}
+	if s.config.EnableWS {
+		if s.config.WSConfig.EnableTLS {
+			transports = append(transports, transportWebSocket+"+tls")
+		} else {
+			transports = append(transports, transportWebSocket)
+		}
+	}
+	if s.config.EnablePoll {
+		transports = append(transports, transportPoll)
+	}
+	return transports
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"tunnel/pkg/crash"
+	"tunnel/pkg/socks5"
+)
+
+const (
+	udpAssociateTarget = "UDP_ASSOCIATE"
+	udpMaxPeers        = 1024
+)
+
+type frameConn interface {
+	ReadEncrypted() ([]byte, error)
+	WriteEncrypted(data []byte) error
+	Close() error
+}
+
+func (s *Server) relayUDP(ctx context.Context, conn frameConn, sess *session) {
+	clientAddr := sess.peer
+	sess.target = udpAssociateTarget
+
+	lc := net.ListenConfig{Control: s.config.TargetMark.Control()}
+	packetConn, err := lc.ListenPacket(ctx, "udp", ":0")
+	if err != nil {
+		log.Printf("[Server] ❌ UDP 监听失败: %v", err)
+		sess.end("dial_failed")
+		conn.WriteEncrypted([]byte("ERROR:" + err.Error()))
+		return
+	}
+	udpConn := packetConn.(*net.UDPConn)
+	defer udpConn.Close()
+	s.commitUsage(sess)
+
+	if err := conn.WriteEncrypted([]byte("OK")); err != nil {
+		log.Printf("[Server] ❌ 发送响应失败: %v", err)
+		return
+	}
+
+	log.Printf("[Server] ✅ UDP 中继建立成功: %s (本地 %s)", clientAddr, udpConn.LocalAddr())
+
+	closeBoth := func() {
+		conn.Close()
+		udpConn.Close()
+	}
+
+	var peersMu sync.Mutex
+	peers := make(map[netip.AddrPort]bool)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("server.udp")
+
+		for {
+			data, err := conn.ReadEncrypted()
+			if err != nil {
+				sess.end("client_closed")
+				return
+			}
+
+			addr, payload, err := socks5.ParseDatagram(data)
+			if err != nil {
+				continue
+			}
+			udpAddr, err := s.resolveUDP(ctx, addr)
+			if err != nil {
+				log.Printf("[Server] ⚠️ UDP 目标解析失败: %v", err)
+				continue
+			}
+			if err := s.egress.permits(udpAddr.IP, udpAddr.Port); err != nil {
+				log.Printf("[Server] 🚫 丢弃 UDP 数据报 (%v): %s", err, addr)
+				continue
+			}
+
+			peer := udpPeer(udpAddr)
+			peersMu.Lock()
+			known := peers[peer]
+			if !known && len(peers) < udpMaxPeers {
+				peers[peer], known = true, true
+			}
+			peersMu.Unlock()
+			if !known {
+				log.Printf("[Server] 🚫 UDP 目标数已达上限 (%d)，丢弃数据报: %s", udpMaxPeers, addr)
+				continue
+			}
+
+			udpConn.SetReadDeadline(time.Now().Add(s.config.UDPIdleTimeout))
+			if err := s.quota.charge(sess, len(payload)); err != nil {
+				return
+			}
+			if n, err := udpConn.WriteToUDP(payload, udpAddr); err == nil {
+				sess.up.Add(int64(n))
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("server.udp")
+
+		buf := make([]byte, 64*1024)
+		for {
+			udpConn.SetReadDeadline(time.Now().Add(s.config.UDPIdleTimeout))
+			n, from, err := udpConn.ReadFromUDP(buf)
+			if err != nil {
+				sess.end("idle_timeout")
+				return
+			}
+			peersMu.Lock()
+			known := peers[udpPeer(from)]
+			peersMu.Unlock()
+			if !known {
+				continue
+			}
+			if err := s.quota.charge(sess, n); err != nil {
+				return
+			}
+			sess.down.Add(int64(n))
+
+			datagram, err := socks5.BuildDatagram(from.String(), buf[:n])
+			if err != nil {
+				continue
+			}
+			if err := conn.WriteEncrypted(datagram); err != nil {
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	log.Printf("[Server] 🔌 UDP 中继关闭: %s", clientAddr)
+}
+
+func udpPeer(addr *net.UDPAddr) netip.AddrPort {
+	ap := addr.AddrPort()
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"tunnel/pkg/control"
+	"tunnel/pkg/health"
+)
+
+const (
+	listenFDEnv  = "TUNNEL_LISTEN_FD"
+	readyFDEnv   = "TUNNEL_READY_FD"
+	readyTimeout = 15 * time.Second
+	drainPoll    = 500 * time.Millisecond
+)
+
+func inheritedListeners() ([]net.Listener, bool, error) {
+	value := os.Getenv(listenFDEnv)
+	if value == "" {
+		return nil, false, nil
+	}
+	os.Unsetenv(listenFDEnv)
+
+	var listeners []net.Listener
+	for _, item := range strings.Split(value, ",") {
+		fd, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid %s: %s", listenFDEnv, value)
+		}
+		file := os.NewFile(uintptr(fd), "listener")
+		ln, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to inherit listener: %w", err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, true, nil
+}
+
+func signalReady() {
+	value := os.Getenv(readyFDEnv)
+	if value == "" {
+		return
+	}
+	os.Unsetenv(readyFDEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	file := os.NewFile(uintptr(fd), "ready")
+	file.Write([]byte{1})
+	file.Close()
+}
+
+func (s *Server) Upgrade() (err error) {
+	if s.ln == nil {
+		return fmt.Errorf("server is not listening")
+	}
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, ln := range s.sockets {
+		tcpLn, ok := ln.(*net.TCPListener)
+		if !ok {
+			return fmt.Errorf("listener does not support handover")
+		}
+		file, err := tcpLn.File()
+		if err != nil {
+			return fmt.Errorf("failed to get listener fd: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	log.Printf("[Server] ♻️ 启动新进程接管监听: %s", exe)
+	s.releaseAuxiliary()
+	defer func() {
+		if err != nil {
+			s.restoreAuxiliary()
+		}
+	}()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	fds := make([]string, len(files))
+	for i := range files {
+		fds[i] = strconv.Itoa(3 + i)
+	}
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(), listenFDEnv+"="+strings.Join(fds, ","), readyFDEnv+"="+strconv.Itoa(3+len(files)))
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ready := make(chan bool, 1)
+	go func() {
+		buf := make([]byte, 1)
+		n, _ := readyR.Read(buf)
+		ready <- n == 1
+	}()
+
+	select {
+	case ok := <-ready:
+		if !ok {
+			return fmt.Errorf("new process exited before taking over: %v", <-exited)
+		}
+	case err := <-exited:
+		return fmt.Errorf("new process exited before taking over: %v", err)
+	case <-time.After(readyTimeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("new process not ready after %v", readyTimeout)
+	}
+
+	log.Printf("[Server] ✅ 新进程已就绪 (PID %d)，当前进程停止接受新连接", cmd.Process.Pid)
+	if s.pusher != nil {
+		s.pusher.Stop()
+	}
+	return nil
+}
+
+func (s *Server) Drain() {
+	timeout := s.config.DrainTimeout
+	s.draining.Store(true)
+	s.closeListeners()
+	s.notifyControl(control.EventShutdown, nil)
+	s.closeControls()
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+
+	log.Printf("[Server] ⏳ 等待 %d 个会话结束 (最长 %v)", s.stats.ActiveConnections.Load(), timeout)
+	for s.stats.ActiveConnections.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			log.Printf("[Server] ⏳ 等待超时，断开剩余 %d 个会话", s.stats.ActiveConnections.Load())
+			s.killSessions("drain_timeout", func(*session) bool { return true })
+			s.cancel()
+			return
+		}
+	}
+	log.Printf("[Server] ✅ 所有会话已结束")
+}
+
+func (s *Server) stopAuxiliary() {
+	if s.pusher != nil {
+		s.pusher.Stop()
+	}
+	s.releaseAuxiliary()
+}
+
+func (s *Server) releaseAuxiliary() {
+	if s.peers != nil {
+		s.peers.Stop()
+	}
+	if s.admin != nil {
+		s.admin.Stop()
+	}
+	if s.health != nil {
+		s.health.Close()
+	}
+	s.stopPlainForwards()
+}
+
+func (s *Server) restoreAuxiliary() {
+	log.Printf("[Server] ♻️ 热升级未完成，重新打开辅助端口")
+	if s.admin != nil {
+		if err := s.admin.Start(); err != nil {
+			log.Printf("[Server] ⚠️ 管理接口恢复失败: %v", err)
+		}
+	}
+	if s.health != nil {
+		h, err := health.Start(s.config.HealthListen, "Server", nil, s.Readiness)
+		if err != nil {
+			log.Printf("[Server] ⚠️ 健康检查恢复失败: %v", err)
+		} else {
+			s.health = h
+		}
+	}
+	if s.peers != nil {
+		if err := s.peers.Start(); err != nil {
+			log.Printf("[Server] ⚠️ 集群同步恢复失败: %v", err)
+		}
+	}
+	s.restartPlainForwards()
+}
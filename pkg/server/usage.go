@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"sync"
+)
+
+var ErrSessionsExhausted = errors.New("session limit reached")
+
+type UsageConfig struct {
+	MaxSessions int
+}
+
+type usage struct {
+	config UsageConfig
+
+	mu       sync.Mutex
+	sessions int
+	pending  int
+	peers    map[string]int
+}
+
+func newUsage(cfg UsageConfig) *usage {
+	return &usage{config: cfg, peers: make(map[string]int)}
+}
+
+func (u *usage) enabled() bool {
+	return u.config.MaxSessions > 0
+}
+
+func (u *usage) admit() error {
+	if !u.enabled() {
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.total()+u.pending >= u.config.MaxSessions {
+		return ErrSessionsExhausted
+	}
+	u.pending++
+	return nil
+}
+
+func (u *usage) commit() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.pending--
+	u.sessions++
+	if total := u.total(); total == u.config.MaxSessions {
+		log.Printf("[Server] 🔒 会话次数已用完 (%d/%d)，后续握手将被拒绝", total, u.config.MaxSessions)
+	}
+}
+
+func (u *usage) release() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.pending--
+}
+
+func (u *usage) total() int {
+	total := u.sessions
+	for _, n := range u.peers {
+		total += n
+	}
+	return total
+}
+
+func (u *usage) local() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.sessions
+}
+
+func (u *usage) merge(node string, sessions int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.peers[node] = sessions
+}
+
+func (u *usage) Stats() map[string]interface{} {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return map[string]interface{}{
+		"sessions":     u.total(),
+		"max_sessions": u.config.MaxSessions,
+	}
+}
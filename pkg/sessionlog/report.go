@@ -0,0 +1,169 @@
+package sessionlog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const dayLayout = "2006-01-02"
+
+type Usage struct {
+	Key        string `json:"key,omitempty"`
+	Sessions   int64  `json:"sessions"`
+	BytesUp    int64  `json:"bytes_up"`
+	BytesDown  int64  `json:"bytes_down"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type Report struct {
+	From    string  `json:"from,omitempty"`
+	To      string  `json:"to,omitempty"`
+	Total   Usage   `json:"total"`
+	Clients []Usage `json:"clients"`
+	Targets []Usage `json:"targets"`
+	Days    []Usage `json:"days"`
+	Skipped int     `json:"skipped_lines"`
+}
+
+type Aggregator struct {
+	from, to time.Time
+	loc      *time.Location
+
+	total   Usage
+	clients map[string]*Usage
+	targets map[string]*Usage
+	days    map[string]*Usage
+	skipped int
+}
+
+func NewAggregator(from, to time.Time, loc *time.Location) *Aggregator {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Aggregator{
+		from:    from,
+		to:      to,
+		loc:     loc,
+		clients: make(map[string]*Usage),
+		targets: make(map[string]*Usage),
+		days:    make(map[string]*Usage),
+	}
+}
+
+func (a *Aggregator) Add(rec Record) {
+	if !a.from.IsZero() && rec.Start.Before(a.from) {
+		return
+	}
+	if !a.to.IsZero() && !rec.Start.Before(a.to) {
+		return
+	}
+
+	client := rec.Peer
+	if host, _, err := net.SplitHostPort(rec.Peer); err == nil {
+		client = host
+	}
+	for _, u := range []*Usage{
+		&a.total,
+		bucket(a.clients, client),
+		bucket(a.targets, rec.Target),
+		bucket(a.days, rec.Start.In(a.loc).Format(dayLayout)),
+	} {
+		u.Sessions++
+		u.BytesUp += rec.BytesUp
+		u.BytesDown += rec.BytesDown
+		u.DurationMS += rec.DurationMS
+	}
+}
+
+func (a *Aggregator) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Start.IsZero() {
+			a.skipped++
+			continue
+		}
+		a.Add(rec)
+	}
+	return scanner.Err()
+}
+
+func (a *Aggregator) Report() Report {
+	rep := Report{
+		Total:   a.total,
+		Clients: byBytes(a.clients),
+		Targets: byBytes(a.targets),
+		Days:    byKey(a.days),
+		Skipped: a.skipped,
+	}
+	if !a.from.IsZero() {
+		rep.From = a.from.In(a.loc).Format(time.RFC3339)
+	}
+	if !a.to.IsZero() {
+		rep.To = a.to.In(a.loc).Format(time.RFC3339)
+	}
+	return rep
+}
+
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"dimension", "key", "sessions", "bytes_up", "bytes_down", "duration_ms"})
+	row := func(dimension string, u Usage) {
+		cw.Write([]string{
+			dimension, u.Key,
+			strconv.FormatInt(u.Sessions, 10),
+			strconv.FormatInt(u.BytesUp, 10),
+			strconv.FormatInt(u.BytesDown, 10),
+			strconv.FormatInt(u.DurationMS, 10),
+		})
+	}
+	row("total", r.Total)
+	for _, u := range r.Days {
+		row("day", u)
+	}
+	for _, u := range r.Clients {
+		row("client", u)
+	}
+	for _, u := range r.Targets {
+		row("target", u)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func bucket(m map[string]*Usage, key string) *Usage {
+	u, ok := m[key]
+	if !ok {
+		u = &Usage{Key: key}
+		m[key] = u
+	}
+	return u
+}
+
+func byKey(m map[string]*Usage) []Usage {
+	out := make([]Usage, 0, len(m))
+	for _, u := range m {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func byBytes(m map[string]*Usage) []Usage {
+	out := byKey(m)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].BytesUp+out[i].BytesDown > out[j].BytesUp+out[j].BytesDown
+	})
+	return out
+}
@@ -0,0 +1,129 @@
+package sessionlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const writeTimeout = 2 * time.Second
+
+type Config struct {
+	Enable bool
+	Output string
+}
+
+type Record struct {
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	DurationMS  int64     `json:"duration_ms"`
+	Peer        string    `json:"peer"`
+	Target      string    `json:"target"`
+	Transport   string    `json:"transport"`
+	BytesUp     int64     `json:"bytes_up"`
+	BytesDown   int64     `json:"bytes_down"`
+	CloseReason string    `json:"close_reason"`
+	Rule        string    `json:"rule,omitempty"`
+}
+
+type Logger struct {
+	mu      sync.Mutex
+	network string
+	address string
+	out     io.WriteCloser
+}
+
+func New(cfg Config) (*Logger, error) {
+	if !cfg.Enable {
+		return &Logger{}, nil
+	}
+	if cfg.Output == "" {
+		return nil, fmt.Errorf("session log output is required")
+	}
+
+	l := &Logger{}
+	if network, address, ok := strings.Cut(cfg.Output, "://"); ok {
+		switch network {
+		case "tcp", "udp", "unix", "unixgram":
+		default:
+			return nil, fmt.Errorf("unsupported session log scheme: %s", network)
+		}
+		l.network, l.address = network, address
+		return l, nil
+	}
+
+	file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session log: %w", err)
+	}
+	l.out = file
+	return l, nil
+}
+
+func (l *Logger) Enabled() bool {
+	return l != nil && (l.out != nil || l.network != "")
+}
+
+func (l *Logger) Log(rec Record) {
+	if !l.Enabled() {
+		return
+	}
+
+	if rec.End.IsZero() {
+		rec.End = time.Now()
+	}
+	rec.DurationMS = rec.End.Sub(rec.Start).Milliseconds()
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.network == "" {
+		l.out.Write(data)
+		return
+	}
+
+	if l.out == nil {
+		conn, err := net.DialTimeout(l.network, l.address, writeTimeout)
+		if err != nil {
+			log.Printf("[SessionLog] ⚠️ 连接 %s://%s 失败，丢弃记录: %v", l.network, l.address, err)
+			return
+		}
+		l.out = conn
+	}
+
+	conn := l.out.(net.Conn)
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.Write(data); err != nil {
+		log.Printf("[SessionLog] ⚠️ 写入失败，丢弃记录: %v", err)
+		conn.Close()
+		l.out = nil
+	}
+}
+
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.out == nil {
+		return nil
+	}
+	err := l.out.Close()
+	l.out = nil
+	return err
+}
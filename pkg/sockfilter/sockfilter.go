@@ -0,0 +1,149 @@
+package sockfilter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"tunnel/pkg/acl"
+)
+
+const (
+	maxInstructions = 4096
+
+	opLoadWord = 0x00 | 0x00 | 0x20
+	opLoadByte = 0x00 | 0x10 | 0x20
+	opAnd      = 0x04 | 0x50
+	opJump     = 0x05 | 0x00
+	opJumpEq   = 0x05 | 0x10
+	opReturn   = 0x06
+	opTAX      = 0x07 | 0x00
+	opTXA      = 0x07 | 0x80
+
+	netOffset = 0xfff00000
+)
+
+const (
+	labelNone = iota
+	labelDrop
+	labelAccept
+	labelIPv6
+)
+
+type instruction struct {
+	code  uint16
+	jt    uint8
+	jf    uint8
+	k     uint32
+	label int
+}
+
+type program struct {
+	insns  []instruction
+	labels map[int]int
+}
+
+func (p *program) emit(code uint16, jt, jf uint8, k uint32) {
+	p.insns = append(p.insns, instruction{code: code, jt: jt, jf: jf, k: k})
+}
+
+func (p *program) jump(label int) {
+	p.insns = append(p.insns, instruction{code: opJump, label: label})
+}
+
+func (p *program) mark(label int) {
+	p.labels[label] = len(p.insns)
+}
+
+func compile(f acl.Prefilter) ([]instruction, error) {
+	drop4, drop6 := split(f.Drop)
+	allow4, allow6 := split(f.Allow)
+
+	p := &program{labels: make(map[int]int)}
+	p.emit(opLoadByte, 0, 0, netOffset)
+	p.emit(opAnd, 0, 0, 0xf0)
+	p.emit(opJumpEq, 0, 1, 0x60)
+	p.jump(labelIPv6)
+
+	p.emit(opLoadWord, 0, 0, netOffset+12)
+	p.emit(opTAX, 0, 0, 0)
+	for _, n := range drop4 {
+		matchIPv4(p, n, labelDrop)
+	}
+	if f.DropOthers {
+		for _, n := range allow4 {
+			matchIPv4(p, n, labelAccept)
+		}
+		p.jump(labelDrop)
+	} else {
+		p.jump(labelAccept)
+	}
+
+	p.mark(labelIPv6)
+	for _, n := range drop6 {
+		matchIPv6(p, n, labelDrop)
+	}
+	if f.DropOthers {
+		for _, n := range allow6 {
+			matchIPv6(p, n, labelAccept)
+		}
+		p.jump(labelDrop)
+	} else {
+		p.jump(labelAccept)
+	}
+
+	p.mark(labelDrop)
+	p.emit(opReturn, 0, 0, 0)
+	p.mark(labelAccept)
+	p.emit(opReturn, 0, 0, 0xffffffff)
+
+	if len(p.insns) > maxInstructions {
+		return nil, fmt.Errorf("too many entries for socket filter (%d instructions, max %d)", len(p.insns), maxInstructions)
+	}
+	for i := range p.insns {
+		if label := p.insns[i].label; label != labelNone {
+			p.insns[i].k = uint32(p.labels[label] - i - 1)
+		}
+	}
+	return p.insns, nil
+}
+
+func matchIPv4(p *program, n *net.IPNet, label int) {
+	p.emit(opTXA, 0, 0, 0)
+	p.emit(opAnd, 0, 0, binary.BigEndian.Uint32(n.Mask))
+	p.emit(opJumpEq, 0, 1, binary.BigEndian.Uint32(n.IP))
+	p.jump(label)
+}
+
+func matchIPv6(p *program, n *net.IPNet, label int) {
+	var words []int
+	for i := 0; i < 4; i++ {
+		if binary.BigEndian.Uint32(n.Mask[i*4:]) != 0 {
+			words = append(words, i)
+		}
+	}
+	for j, i := range words {
+		remaining := (len(words)-j-1)*3 + 1
+		p.emit(opLoadWord, 0, 0, netOffset+8+uint32(i*4))
+		p.emit(opAnd, 0, 0, binary.BigEndian.Uint32(n.Mask[i*4:]))
+		p.emit(opJumpEq, 0, uint8(remaining), binary.BigEndian.Uint32(n.IP[i*4:]))
+	}
+	p.jump(label)
+}
+
+func split(nets []*net.IPNet) (v4, v6 []*net.IPNet) {
+	for _, n := range nets {
+		if ip4 := n.IP.To4(); ip4 != nil {
+			mask := n.Mask
+			if len(mask) == net.IPv6len {
+				mask = mask[12:]
+			}
+			v4 = append(v4, &net.IPNet{IP: ip4.Mask(mask), Mask: mask})
+			continue
+		}
+		if ip6 := n.IP.To16(); ip6 != nil && len(n.Mask) == net.IPv6len {
+			v6 = append(v6, &net.IPNet{IP: ip6.Mask(n.Mask), Mask: n.Mask})
+		}
+	}
+	return v4, v6
+}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package sockfilter
+
+import (
+	"syscall"
+
+	"tunnel/pkg/acl"
+)
+
+func Attach(conn syscall.Conn, f acl.Prefilter) error {
+	insns, err := compile(f)
+	if err != nil {
+		return err
+	}
+
+	filter := make([]syscall.SockFilter, len(insns))
+	for i, insn := range insns {
+		filter[i] = syscall.SockFilter{Code: insn.code, Jt: insn.jt, Jf: insn.jf, K: insn.k}
+	}
+	return control(conn, func(fd int) error {
+		return syscall.AttachLsf(fd, filter)
+	})
+}
+
+func Detach(conn syscall.Conn) error {
+	return control(conn, syscall.DetachLsf)
+}
+
+func control(conn syscall.Conn, fn func(fd int) error) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	if err := raw.Control(func(fd uintptr) {
+		opErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return opErr
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package sockfilter
+
+import (
+	"errors"
+	"syscall"
+
+	"tunnel/pkg/acl"
+)
+
+func Attach(conn syscall.Conn, f acl.Prefilter) error {
+	return errors.New("socket prefilter is only supported on linux")
+}
+
+func Detach(conn syscall.Conn) error {
+	return nil
+}
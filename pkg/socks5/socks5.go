@@ -0,0 +1,140 @@
+package socks5
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+const (
+	Version = 0x05
+
+	MethodNoAuth       = 0x00
+	MethodNoAcceptable = 0xff
+
+	CmdConnect      = 0x01
+	CmdBind         = 0x02
+	CmdUDPAssociate = 0x03
+
+	AtypIPv4   = 0x01
+	AtypDomain = 0x03
+	AtypIPv6   = 0x04
+
+	RepSuccess             = 0x00
+	RepGeneralFailure      = 0x01
+	RepCommandNotSupported = 0x07
+	RepAddrNotSupported    = 0x08
+)
+
+var ErrFragmented = errors.New("fragmented socks5 datagram")
+
+func ReadAddr(r io.Reader) (string, error) {
+	atyp := make([]byte, 1)
+	if _, err := io.ReadFull(r, atyp); err != nil {
+		return "", err
+	}
+
+	var host string
+	switch atyp[0] {
+	case AtypIPv4:
+		ip := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case AtypIPv6:
+		ip := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case AtypDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(r, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(r, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		return "", fmt.Errorf("unsupported address type: %d", atyp[0])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+func AppendAddr(b []byte, addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port: %s", portStr)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append(b, AtypIPv4)
+			b = append(b, ip4...)
+		} else {
+			b = append(b, AtypIPv6)
+			b = append(b, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("domain too long: %s", host)
+		}
+		b = append(b, AtypDomain, byte(len(host)))
+		b = append(b, host...)
+	}
+
+	return binary.BigEndian.AppendUint16(b, uint16(port)), nil
+}
+
+func Reply(w io.Writer, rep byte, bindAddr string) error {
+	if bindAddr == "" {
+		bindAddr = "0.0.0.0:0"
+	}
+	b, err := AppendAddr([]byte{Version, rep, 0x00}, bindAddr)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func ParseDatagram(b []byte) (string, []byte, error) {
+	if len(b) < 4 {
+		return "", nil, fmt.Errorf("short socks5 datagram")
+	}
+	if b[2] != 0 {
+		return "", nil, ErrFragmented
+	}
+
+	r := bytes.NewReader(b[3:])
+	addr, err := ReadAddr(r)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid socks5 datagram: %w", err)
+	}
+	return addr, b[len(b)-r.Len():], nil
+}
+
+func BuildDatagram(addr string, payload []byte) ([]byte, error) {
+	b, err := AppendAddr([]byte{0x00, 0x00, 0x00}, addr)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, payload...), nil
+}
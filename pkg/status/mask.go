@@ -0,0 +1,20 @@
+package status
+
+import "net/url"
+
+const masked = "******"
+
+func Mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return masked
+}
+
+func MaskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
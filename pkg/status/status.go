@@ -0,0 +1,30 @@
+package status
+
+import (
+	"encoding/json"
+	"os"
+)
+
+type Listener struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type Status struct {
+	Mode       string     `json:"mode"`
+	Version    string     `json:"version"`
+	Listeners  []Listener `json:"listeners"`
+	Transports []string   `json:"transports"`
+	PID        int        `json:"pid"`
+	ConfigHash string     `json:"config_hash"`
+}
+
+func Print(st Status) error {
+	if st.Listeners == nil {
+		st.Listeners = []Listener{}
+	}
+	if st.Transports == nil {
+		st.Transports = []string{}
+	}
+	return json.NewEncoder(os.Stdout).Encode(st)
+}
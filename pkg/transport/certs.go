@@ -0,0 +1,269 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const ocspRefreshInterval = 12 * time.Hour
+
+var oidSHA1 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+
+type CertReloader struct {
+	certFile   string
+	keyFile    string
+	enableOCSP bool
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	ocspFetch time.Time
+
+	done chan struct{}
+	once sync.Once
+}
+
+func NewCertReloader(certFile, keyFile string, enableOCSP bool) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile:   certFile,
+		keyFile:    keyFile,
+		enableOCSP: enableOCSP,
+		done:       make(chan struct{}),
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *CertReloader) Watch(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				r.check()
+			}
+		}
+	}()
+}
+
+func (r *CertReloader) Close() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+}
+
+func (r *CertReloader) check() {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		log.Printf("[TLS] ⚠️ 检查证书文件失败: %v", err)
+		return
+	}
+
+	r.mu.RLock()
+	changed := !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+	staleOCSP := r.enableOCSP && time.Since(r.ocspFetch) > ocspRefreshInterval
+	r.mu.RUnlock()
+
+	if changed {
+		if err := r.reload(); err != nil {
+			log.Printf("[TLS] ⚠️ 重新加载证书失败，继续使用旧证书: %v", err)
+			return
+		}
+		log.Printf("[TLS] 🔄 证书已重新加载: %s", r.certFile)
+		return
+	}
+
+	if staleOCSP {
+		r.mu.RLock()
+		cert := *r.cert
+		r.mu.RUnlock()
+
+		r.staple(&cert)
+
+		r.mu.Lock()
+		r.cert = &cert
+		r.mu.Unlock()
+	}
+}
+
+func (r *CertReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+func (r *CertReloader) reload() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return fmt.Errorf("failed to stat certificate: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	if r.enableOCSP {
+		r.staple(&cert)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *CertReloader) staple(cert *tls.Certificate) {
+	r.mu.Lock()
+	r.ocspFetch = time.Now()
+	r.mu.Unlock()
+
+	response, err := fetchOCSPResponse(cert)
+	if err != nil {
+		log.Printf("[TLS] ⚠️ 获取 OCSP 响应失败: %v", err)
+		return
+	}
+
+	cert.OCSPStaple = response
+	log.Printf("[TLS] 📎 OCSP 响应已装订 (%d 字节)", len(response))
+}
+
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspSingleRequest struct {
+	Cert ocspCertID
+}
+
+type ocspTBSRequest struct {
+	RequestList []ocspSingleRequest
+}
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspResponse struct {
+	Status asn1.Enumerated
+}
+
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+func fetchOCSPResponse(cert *tls.Certificate) ([]byte, error) {
+	if len(cert.Certificate) < 2 {
+		return nil, errors.New("certificate chain has no issuer")
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, errors.New("certificate has no OCSP responder")
+	}
+
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var spki subjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+
+	request, err := asn1.Marshal(ocspRequest{
+		TBSRequest: ocspTBSRequest{
+			RequestList: []ocspSingleRequest{{
+				Cert: ocspCertID{
+					HashAlgorithm: pkix.AlgorithmIdentifier{
+						Algorithm:  oidSHA1,
+						Parameters: asn1.RawValue{Tag: asn1.TagNull},
+					},
+					IssuerNameHash: nameHash[:],
+					IssuerKeyHash:  keyHash[:],
+					SerialNumber:   leaf.SerialNumber,
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed ocspResponse
+	if _, err := asn1.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid OCSP response: %w", err)
+	}
+	if parsed.Status != 0 {
+		return nil, fmt.Errorf("OCSP responder status %d", parsed.Status)
+	}
+
+	return body, nil
+}
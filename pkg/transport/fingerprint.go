@@ -0,0 +1,422 @@
+package transport
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const maxHelloCapture = 16 * 1024
+
+var errShortHello = errors.New("truncated client hello")
+
+type Fingerprint struct {
+	JA3     string `json:"ja3"`
+	JA3Hash string `json:"ja3_hash"`
+	JA4     string `json:"ja4"`
+}
+
+type FingerprintConn struct {
+	net.Conn
+
+	mu       sync.Mutex
+	captured []byte
+	done     bool
+	fp       *Fingerprint
+}
+
+func NewFingerprintConn(conn net.Conn) *FingerprintConn {
+	return &FingerprintConn{Conn: conn}
+}
+
+func (c *FingerprintConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.mu.Lock()
+		if !c.done {
+			c.captured = append(c.captured, p[:n]...)
+			if len(c.captured) > maxHelloCapture {
+				c.done = true
+				c.captured = nil
+			}
+		}
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *FingerprintConn) Fingerprint() *Fingerprint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.done {
+		c.done = true
+		if fp, err := fingerprintHello(c.captured); err == nil {
+			c.fp = fp
+		}
+		c.captured = nil
+	}
+	return c.fp
+}
+
+type fingerprintListener struct {
+	net.Listener
+}
+
+func NewFingerprintListener(ln net.Listener) net.Listener {
+	return &fingerprintListener{Listener: ln}
+}
+
+func (l *fingerprintListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewFingerprintConn(conn), nil
+}
+
+func WatchFingerprints(tlsConfig *tls.Config, observe func(conn net.Conn, fp *Fingerprint)) {
+	next := tlsConfig.GetConfigForClient
+	tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if fc, ok := hello.Conn.(*FingerprintConn); ok {
+			if fp := fc.Fingerprint(); fp != nil {
+				observe(fc, fp)
+			}
+		}
+		if next != nil {
+			return next(hello)
+		}
+		return nil, nil
+	}
+}
+
+func ConnFingerprint(conn net.Conn) *Fingerprint {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *FingerprintConn:
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.fp
+		case *tls.Conn:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+type clientHello struct {
+	version    uint16
+	ciphers    []uint16
+	extensions []uint16
+	groups     []uint16
+	points     []uint8
+	sigAlgs    []uint16
+	versions   []uint16
+	alpn       string
+	hasSNI     bool
+}
+
+func fingerprintHello(data []byte) (*Fingerprint, error) {
+	body, err := readHandshake(data)
+	if err != nil {
+		return nil, err
+	}
+	hello, err := parseClientHello(body)
+	if err != nil {
+		return nil, err
+	}
+
+	ja3 := hello.ja3()
+	sum := md5.Sum([]byte(ja3))
+	return &Fingerprint{
+		JA3:     ja3,
+		JA3Hash: hex.EncodeToString(sum[:]),
+		JA4:     hello.ja4(),
+	}, nil
+}
+
+func readHandshake(data []byte) ([]byte, error) {
+	var payload []byte
+	for len(data) >= 5 {
+		if data[0] != 0x16 {
+			return nil, fmt.Errorf("not a handshake record: %d", data[0])
+		}
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+length {
+			break
+		}
+		payload = append(payload, data[5:5+length]...)
+		data = data[5+length:]
+
+		if len(payload) >= 4 {
+			if payload[0] != 0x01 {
+				return nil, fmt.Errorf("not a client hello: %d", payload[0])
+			}
+			size := int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3])
+			if len(payload) >= 4+size {
+				return payload[4 : 4+size], nil
+			}
+		}
+	}
+	return nil, errShortHello
+}
+
+func parseClientHello(b []byte) (*clientHello, error) {
+	r := helloReader(b)
+	hello := &clientHello{}
+
+	var ok bool
+	if hello.version, ok = r.uint16(); !ok {
+		return nil, errShortHello
+	}
+	if !r.skip(32) || !r.skipVector8() {
+		return nil, errShortHello
+	}
+
+	ciphers, ok := r.vector16()
+	if !ok {
+		return nil, errShortHello
+	}
+	hello.ciphers = ciphers.uint16s()
+
+	if !r.skipVector8() {
+		return nil, errShortHello
+	}
+
+	extensions, ok := r.vector16()
+	if !ok {
+		return hello, nil
+	}
+	for len(extensions) > 0 {
+		typ, ok := extensions.uint16()
+		if !ok {
+			return nil, errShortHello
+		}
+		data, ok := extensions.vector16()
+		if !ok {
+			return nil, errShortHello
+		}
+		hello.extensions = append(hello.extensions, typ)
+
+		switch typ {
+		case 0x0000:
+			hello.hasSNI = true
+		case 0x000a:
+			if groups, ok := data.vector16(); ok {
+				hello.groups = groups.uint16s()
+			}
+		case 0x000b:
+			if points, ok := data.vector8(); ok {
+				hello.points = []uint8(points)
+			}
+		case 0x000d:
+			if algs, ok := data.vector16(); ok {
+				hello.sigAlgs = algs.uint16s()
+			}
+		case 0x0010:
+			if protos, ok := data.vector16(); ok {
+				if first, ok := protos.vector8(); ok {
+					hello.alpn = string(first)
+				}
+			}
+		case 0x002b:
+			if versions, ok := data.vector8(); ok {
+				hello.versions = versions.uint16s()
+			}
+		}
+	}
+	return hello, nil
+}
+
+func (h *clientHello) ja3() string {
+	points := make([]uint16, len(h.points))
+	for i, p := range h.points {
+		points[i] = uint16(p)
+	}
+	return strings.Join([]string{
+		strconv.Itoa(int(h.version)),
+		joinDecimal(h.ciphers),
+		joinDecimal(h.extensions),
+		joinDecimal(h.groups),
+		joinDecimal(points),
+	}, ",")
+}
+
+func (h *clientHello) ja4() string {
+	ciphers := withoutGrease(h.ciphers)
+	extensions := withoutGrease(h.extensions)
+
+	version := h.version
+	for _, v := range withoutGrease(h.versions) {
+		if v > version {
+			version = v
+		}
+	}
+
+	sni := "i"
+	if h.hasSNI {
+		sni = "d"
+	}
+
+	a := fmt.Sprintf("t%s%s%02d%02d%s", ja4Version(version), sni, min(len(ciphers), 99), min(len(extensions), 99), ja4ALPN(h.alpn))
+
+	sortedCiphers := append([]uint16{}, ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+
+	var sortedExtensions []uint16
+	for _, ext := range extensions {
+		if ext != 0x0000 && ext != 0x0010 {
+			sortedExtensions = append(sortedExtensions, ext)
+		}
+	}
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+
+	c := joinHex(sortedExtensions)
+	if len(h.sigAlgs) > 0 {
+		c += "_" + joinHex(withoutGrease(h.sigAlgs))
+	}
+	if len(sortedExtensions) == 0 {
+		c = ""
+	}
+
+	return a + "_" + truncatedHash(joinHex(sortedCiphers)) + "_" + truncatedHash(c)
+}
+
+func ja4Version(v uint16) string {
+	switch v {
+	case tls.VersionTLS13:
+		return "13"
+	case tls.VersionTLS12:
+		return "12"
+	case tls.VersionTLS11:
+		return "11"
+	case tls.VersionTLS10:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0200:
+		return "s2"
+	}
+	return "00"
+}
+
+func ja4ALPN(alpn string) string {
+	if alpn == "" {
+		return "00"
+	}
+	first, last := alpn[0], alpn[len(alpn)-1]
+	if isAlnum(first) && isAlnum(last) {
+		return string([]byte{first, last})
+	}
+	h := hex.EncodeToString([]byte(alpn))
+	return string([]byte{h[0], h[len(h)-1]})
+}
+
+func isAlnum(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func truncatedHash(s string) string {
+	if s == "" {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func isGrease(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func withoutGrease(values []uint16) []uint16 {
+	var out []uint16
+	for _, v := range values {
+		if !isGrease(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func joinDecimal(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range withoutGrease(values) {
+		parts = append(parts, strconv.Itoa(int(v)))
+	}
+	return strings.Join(parts, "-")
+}
+
+func joinHex(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, fmt.Sprintf("%04x", v))
+	}
+	return strings.Join(parts, ",")
+}
+
+type helloReader []byte
+
+func (r *helloReader) skip(n int) bool {
+	if len(*r) < n {
+		return false
+	}
+	*r = (*r)[n:]
+	return true
+}
+
+func (r *helloReader) uint16() (uint16, bool) {
+	if len(*r) < 2 {
+		return 0, false
+	}
+	v := binary.BigEndian.Uint16(*r)
+	*r = (*r)[2:]
+	return v, true
+}
+
+func (r *helloReader) vector8() (helloReader, bool) {
+	if len(*r) < 1 {
+		return nil, false
+	}
+	n := int((*r)[0])
+	if len(*r) < 1+n {
+		return nil, false
+	}
+	v := (*r)[1 : 1+n]
+	*r = (*r)[1+n:]
+	return v, true
+}
+
+func (r *helloReader) vector16() (helloReader, bool) {
+	n, ok := r.uint16()
+	if !ok || len(*r) < int(n) {
+		return nil, false
+	}
+	v := (*r)[:n]
+	*r = (*r)[n:]
+	return v, true
+}
+
+func (r *helloReader) skipVector8() bool {
+	_, ok := r.vector8()
+	return ok
+}
+
+func (r helloReader) uint16s() []uint16 {
+	values := make([]uint16, 0, len(r)/2)
+	for len(r) >= 2 {
+		values = append(values, binary.BigEndian.Uint16(r))
+		r = r[2:]
+	}
+	return values
+}
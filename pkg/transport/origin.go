@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const AnyOrigin = "*"
+
+func (s *WSServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if len(s.config.AllowedOrigins) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range s.config.AllowedOrigins {
+		switch {
+		case allowed == AnyOrigin:
+			return true
+		case strings.HasPrefix(allowed, "*."):
+			if strings.HasSuffix(strings.ToLower(u.Hostname()), strings.ToLower(allowed[1:])) {
+				return true
+			}
+		case strings.EqualFold(strings.TrimSuffix(allowed, "/"), u.Scheme+"://"+u.Host):
+			return true
+		}
+	}
+	return false
+}
+
+func defaultOrigin(tls bool, serverAddr string) string {
+	if tls {
+		return "https://" + serverAddr
+	}
+	return "http://" + serverAddr
+}
@@ -0,0 +1,416 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tunnel/pkg/crash"
+)
+
+const (
+	pollWait        = 20 * time.Second
+	pollLinger      = 100 * time.Millisecond
+	pollSessionIdle = 60 * time.Second
+	pollBufferSize  = 32 * 1024
+	pollMaxBody     = 16 * 1024 * 1024
+)
+
+type pollAddr string
+
+func (a pollAddr) Network() string {
+	return "tcp"
+}
+
+func (a pollAddr) String() string {
+	return string(a)
+}
+
+type pollConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *pollConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+type pollSession struct {
+	id       string
+	pipe     net.Conn
+	getMu    sync.Mutex
+	lastSeen atomic.Int64
+}
+
+func (s *pollSession) touch() {
+	s.lastSeen.Store(time.Now().UnixNano())
+}
+
+type PollServer struct {
+	handler  func(net.Conn)
+	remote   func(*http.Request) string
+	mu       sync.Mutex
+	sessions map[string]*pollSession
+	reaping  bool
+}
+
+func NewPollServer(handler func(net.Conn)) *PollServer {
+	return &PollServer{
+		handler:  handler,
+		sessions: make(map[string]*pollSession),
+	}
+}
+
+func (p *PollServer) SetRemoteResolver(resolve func(*http.Request) string) {
+	p.remote = resolve
+}
+
+func (p *PollServer) remoteAddr(r *http.Request) string {
+	if p.remote != nil {
+		return p.remote(r)
+	}
+	return r.RemoteAddr
+}
+
+func (p *PollServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sid := r.URL.Query().Get("sid")
+	if !isValidSessionID(sid) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		switch r.URL.Query().Get("op") {
+		case "open":
+			p.open(w, r, sid)
+		case "close":
+			p.close(sid)
+			w.WriteHeader(http.StatusOK)
+		default:
+			p.upstream(w, r, sid)
+		}
+	case http.MethodGet:
+		p.downstream(w, r, sid)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (p *PollServer) open(w http.ResponseWriter, r *http.Request, sid string) {
+	app, pipe := net.Pipe()
+	session := &pollSession{id: sid, pipe: pipe}
+	session.touch()
+
+	p.mu.Lock()
+	if _, exists := p.sessions[sid]; exists {
+		p.mu.Unlock()
+		app.Close()
+		pipe.Close()
+		http.Error(w, "Conflict", http.StatusConflict)
+		return
+	}
+	p.sessions[sid] = session
+	if !p.reaping {
+		p.reaping = true
+		go p.reap()
+	}
+	p.mu.Unlock()
+
+	remote := p.remoteAddr(r)
+	log.Printf("[Poll-Server] 📥 新长轮询会话: %s (%s)", remote, sid[:8])
+
+	go p.handler(&pollConn{Conn: app, remote: pollAddr(remote)})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (p *PollServer) upstream(w http.ResponseWriter, r *http.Request, sid string) {
+	session := p.get(sid)
+	if session == nil {
+		http.Error(w, "Gone", http.StatusGone)
+		return
+	}
+	session.touch()
+
+	if _, err := io.Copy(session.pipe, io.LimitReader(r.Body, pollMaxBody)); err != nil {
+		p.close(sid)
+		http.Error(w, "Gone", http.StatusGone)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (p *PollServer) downstream(w http.ResponseWriter, r *http.Request, sid string) {
+	session := p.get(sid)
+	if session == nil {
+		http.Error(w, "Gone", http.StatusGone)
+		return
+	}
+
+	session.getMu.Lock()
+	defer session.getMu.Unlock()
+	session.touch()
+	defer session.touch()
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	buf := make([]byte, pollBufferSize)
+	wrote := false
+	for {
+		wait := pollWait
+		if wrote {
+			wait = pollLinger
+		}
+		session.pipe.SetReadDeadline(time.Now().Add(wait))
+
+		n, err := session.pipe.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				p.close(sid)
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			wrote = true
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if !wrote {
+					w.WriteHeader(http.StatusOK)
+				}
+				return
+			}
+			p.close(sid)
+			if !wrote {
+				http.Error(w, "Gone", http.StatusGone)
+			}
+			return
+		}
+	}
+}
+
+func (p *PollServer) get(sid string) *pollSession {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sessions[sid]
+}
+
+func (p *PollServer) close(sid string) {
+	p.mu.Lock()
+	session, ok := p.sessions[sid]
+	delete(p.sessions, sid)
+	p.mu.Unlock()
+
+	if ok {
+		session.pipe.Close()
+	}
+}
+
+func (p *PollServer) reap() {
+	ticker := time.NewTicker(pollSessionIdle / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-pollSessionIdle).UnixNano()
+
+		p.mu.Lock()
+		var expired []*pollSession
+		for sid, session := range p.sessions {
+			if session.lastSeen.Load() < cutoff {
+				expired = append(expired, session)
+				delete(p.sessions, sid)
+			}
+		}
+		remaining := len(p.sessions)
+		if remaining == 0 {
+			p.reaping = false
+		}
+		p.mu.Unlock()
+
+		for _, session := range expired {
+			log.Printf("[Poll-Server] ⏱️ 长轮询会话超时: %s", session.id[:8])
+			session.pipe.Close()
+		}
+
+		if remaining == 0 {
+			return
+		}
+	}
+}
+
+type PollClient struct {
+	config    WSConfig
+	client    *http.Client
+	transport *http.Transport
+}
+
+func NewPollClient(config WSConfig) *PollClient {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: config.DialTimeout,
+	}
+	if config.EnableTLS && config.SkipVerify {
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	}
+
+	return &PollClient{
+		config:    config,
+		client:    &http.Client{Transport: transport, Jar: newAffinityJar(config)},
+		transport: transport,
+	}
+}
+
+func (c *PollClient) SetDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	c.transport.Proxy = nil
+	c.transport.DialContext = dial
+}
+
+func (c *PollClient) Dial(ctx context.Context, serverAddr string) (net.Conn, error) {
+	scheme := "http"
+	if c.config.EnableTLS {
+		scheme = "https"
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	sid := hex.EncodeToString(id)
+	url := fmt.Sprintf("%s://%s%s?sid=%s", scheme, serverAddr, c.config.Path, sid)
+
+	if err := c.post(ctx, url+"&op=open", nil, 10*time.Second); err != nil {
+		return nil, fmt.Errorf("poll open failed: %w", err)
+	}
+
+	app, pipe := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		defer crash.Recover("poll.upstream")
+		defer pipe.Close()
+		defer cancel()
+
+		buf := make([]byte, pollBufferSize)
+		for {
+			n, err := pipe.Read(buf)
+			if err != nil {
+				break
+			}
+			if err := c.post(ctx, url, buf[:n], 30*time.Second); err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[Poll-Client] ⚠️ 上行请求失败: %v", err)
+				}
+				break
+			}
+		}
+
+		c.post(context.Background(), url+"&op=close", nil, 5*time.Second)
+	}()
+
+	go func() {
+		defer crash.Recover("poll.downstream")
+		defer pipe.Close()
+		defer cancel()
+
+		for {
+			if err := c.poll(ctx, url, pipe); err != nil {
+				if ctx.Err() == nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrClosedPipe) {
+					log.Printf("[Poll-Client] ⚠️ 下行请求失败: %v", err)
+				}
+				return
+			}
+		}
+	}()
+
+	log.Printf("[Poll-Client] ✅ 长轮询会话建立: %s://%s%s", scheme, serverAddr, c.config.Path)
+
+	return app, nil
+}
+
+func (c *PollClient) post(ctx context.Context, url string, body []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	c.setHeaders(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (c *PollClient) poll(ctx context.Context, url string, dst net.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, pollWait+30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		_, err = io.Copy(dst, resp.Body)
+		return err
+	case http.StatusGone:
+		return io.EOF
+	default:
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+}
+
+func (c *PollClient) setHeaders(req *http.Request) {
+	req.Header.Set("Cache-Control", "no-cache")
+	if c.config.Origin != "" {
+		req.Header.Set("Origin", c.config.Origin)
+	}
+}
+
+func isValidSessionID(sid string) bool {
+	if len(sid) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(sid)
+	return err == nil
+}
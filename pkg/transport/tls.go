@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P-256":  tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P-384":  tls.CurveP384,
+	"P521":   tls.CurveP521,
+	"P-521":  tls.CurveP521,
+}
+
+func BuildServerTLSConfig(config WSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if config.TLSMinVersion != "" {
+		version, err := parseTLSVersion(config.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if config.TLSMaxVersion != "" {
+		version, err := parseTLSVersion(config.TLSMaxVersion)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.MaxVersion = version
+	}
+
+	if tlsConfig.MinVersion != 0 && tlsConfig.MaxVersion != 0 && tlsConfig.MinVersion > tlsConfig.MaxVersion {
+		return nil, fmt.Errorf("tls min version %s is greater than max version %s", config.TLSMinVersion, config.TLSMaxVersion)
+	}
+
+	for _, name := range config.TLSCipherSuites {
+		id, err := parseCipherSuite(name)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
+
+	for _, name := range config.TLSCurves {
+		curve, ok := tlsCurves[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls curve: %s", name)
+		}
+		tlsConfig.CurvePreferences = append(tlsConfig.CurvePreferences, curve)
+	}
+
+	for _, proto := range config.TLSALPN {
+		if proto = strings.TrimSpace(proto); proto != "" {
+			tlsConfig.NextProtos = append(tlsConfig.NextProtos, proto)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+func LoadServerCertificate(config WSConfig, tlsConfig *tls.Config) (*CertReloader, error) {
+	reloader, err := NewCertReloader(config.TLSCert, config.TLSKey, config.TLSOCSPStapling)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig.GetCertificate = reloader.GetCertificate
+	reloader.Watch(config.TLSReloadInterval)
+	return reloader, nil
+}
+
+func parseTLSVersion(name string) (uint16, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "TLS")
+	name = strings.TrimPrefix(name, "v")
+	version, ok := tlsVersions[strings.TrimSpace(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls version: %s", name)
+	}
+	return version, nil
+}
+
+func parseCipherSuite(name string) (uint16, error) {
+	name = strings.TrimSpace(name)
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, nil
+		}
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.Name == name {
+			return 0, fmt.Errorf("insecure tls cipher suite not allowed: %s", name)
+		}
+	}
+	return 0, fmt.Errorf("unknown tls cipher suite: %s", name)
+}
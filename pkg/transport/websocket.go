@@ -1,38 +1,60 @@
 package transport
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"tunnel/pkg/crash"
 	"tunnel/pkg/crypto"
+	"tunnel/pkg/netutil"
 )
 
 type WSConfig struct {
-	Path            string
-	Origin          string
-	EnableTLS       bool
-	TLSCert         string
-	TLSKey          string
-	SkipVerify      bool
-	PingInterval    time.Duration
-	ReadBufferSize  int
-	WriteBufferSize int
+	Path              string
+	Origin            string
+	AllowedOrigins    []string
+	EnableTLS         bool
+	TLSCert           string
+	TLSKey            string
+	TLSMinVersion     string
+	TLSMaxVersion     string
+	TLSCipherSuites   []string
+	TLSCurves         []string
+	TLSALPN           []string
+	TLSOCSPStapling   bool
+	TLSReloadInterval time.Duration
+	SkipVerify        bool
+	DialTimeout       time.Duration
+	PingInterval      time.Duration
+	PongMisses        int
+	ReadBufferSize    int
+	WriteBufferSize   int
+	AffinityCookie    string
+	AffinityValue     string
+	Affinity          bool
 }
 
 func DefaultWSConfig() WSConfig {
 	return WSConfig{
-		Path:            "/ws",
-		PingInterval:    30 * time.Second,
-		ReadBufferSize:  32 * 1024,
-		WriteBufferSize: 32 * 1024,
+		Path:              "/ws",
+		DialTimeout:       10 * time.Second,
+		PingInterval:      30 * time.Second,
+		PongMisses:        3,
+		TLSReloadInterval: time.Minute,
+		ReadBufferSize:    32 * 1024,
+		WriteBufferSize:   32 * 1024,
 	}
 }
 
@@ -40,8 +62,19 @@ type WSConn struct {
 	conn   *websocket.Conn
 	cipher *crypto.AESCipher
 	mu     sync.Mutex
+	req    *http.Request
+	once   sync.Once
+
+	writeTimeout time.Duration
+
+	deadlineMu   sync.Mutex
+	readDeadline time.Time
+	pongDeadline atomic.Int64
+	pongLost     atomic.Bool
 }
 
+var ErrPongTimeout = errors.New("websocket pong timeout")
+
 func NewWSConn(conn *websocket.Conn, cipher *crypto.AESCipher) *WSConn {
 	return &WSConn{
 		conn:   conn,
@@ -52,15 +85,19 @@ func NewWSConn(conn *websocket.Conn, cipher *crypto.AESCipher) *WSConn {
 func (w *WSConn) ReadEncrypted() ([]byte, error) {
 	_, message, err := w.conn.ReadMessage()
 	if err != nil {
+		if w.pongExpired(err) {
+			return nil, fmt.Errorf("%w: %w", ErrPongTimeout, err)
+		}
 		return nil, err
 	}
 
-	encrypted, err := base64.StdEncoding.DecodeString(string(message))
+	encrypted := make([]byte, base64.StdEncoding.DecodedLen(len(message)))
+	n, err := base64.StdEncoding.Decode(encrypted, message)
 	if err != nil {
-		return nil, fmt.Errorf("base64 decode failed: %w", err)
+		return nil, fmt.Errorf("%w: base64 decode failed: %v", crypto.ErrDecrypt, err)
 	}
 
-	return w.cipher.Decrypt(encrypted)
+	return w.cipher.DecryptInPlace(encrypted[:n])
 }
 
 func (w *WSConn) WriteEncrypted(data []byte) error {
@@ -69,31 +106,100 @@ func (w *WSConn) WriteEncrypted(data []byte) error {
 		return err
 	}
 
-	encoded := base64.StdEncoding.EncodeToString(encrypted)
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(encrypted)))
+	base64.StdEncoding.Encode(encoded, encrypted)
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	return w.conn.WriteMessage(websocket.TextMessage, []byte(encoded))
+	if w.writeTimeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	}
+	return w.conn.WriteMessage(websocket.TextMessage, encoded)
 }
 
 func (w *WSConn) Close() error {
+	w.once.Do(func() {
+		w.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	})
 	return w.conn.Close()
 }
 
+func IsNormalClose(err error) bool {
+	return netutil.IsClosed(err) || websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway)
+}
+
 func (w *WSConn) RemoteAddr() net.Addr {
 	return w.conn.RemoteAddr()
 }
 
-func (w *WSConn) StartPing(interval time.Duration) {
+func (w *WSConn) SetWriteTimeout(timeout time.Duration) {
+	w.writeTimeout = timeout
+}
+
+func (w *WSConn) SetReadDeadline(t time.Time) error {
+	w.deadlineMu.Lock()
+	defer w.deadlineMu.Unlock()
+	w.readDeadline = t
+	return w.applyReadDeadline()
+}
+
+func (w *WSConn) applyReadDeadline() error {
+	deadline := w.readDeadline
+	if pong := w.pongDeadline.Load(); pong != 0 {
+		if t := time.Unix(0, pong); deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+	return w.conn.SetReadDeadline(deadline)
+}
+
+func (w *WSConn) extendPongDeadline(wait time.Duration) {
+	w.deadlineMu.Lock()
+	defer w.deadlineMu.Unlock()
+	w.pongDeadline.Store(time.Now().Add(wait).UnixNano())
+	w.applyReadDeadline()
+}
+
+func (w *WSConn) pongExpired(err error) bool {
+	if w.pongLost.Load() {
+		return true
+	}
+	pong := w.pongDeadline.Load()
+	return pong != 0 && netutil.IsTimeout(err) && time.Now().UnixNano() >= pong
+}
+
+func (w *WSConn) Request() *http.Request {
+	return w.req
+}
+
+func (w *WSConn) StartPing(interval time.Duration, misses int) {
+	if interval <= 0 {
+		return
+	}
+	if misses > 0 {
+		wait := interval * time.Duration(misses)
+		w.extendPongDeadline(wait)
+		w.conn.SetPongHandler(func(string) error {
+			w.extendPongDeadline(wait)
+			return nil
+		})
+	}
+
 	go func() {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
 		for range ticker.C {
-			w.mu.Lock()
+			if pong := w.pongDeadline.Load(); pong != 0 && time.Now().UnixNano() >= pong {
+				log.Printf("[WS] 💔 连续 %d 次未收到 Pong，关闭连接: %s", misses, w.conn.RemoteAddr())
+				w.pongLost.Store(true)
+				w.conn.Close()
+				return
+			}
+
 			err := w.conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second))
-			w.mu.Unlock()
 
 			if err != nil {
 				return
@@ -107,47 +213,105 @@ type WSServer struct {
 	cipher   *crypto.AESCipher
 	upgrader websocket.Upgrader
 	handler  func(*WSConn)
+	onProbe  func(*http.Request, string)
+	poll     *PollServer
 }
 
 func NewWSServer(config WSConfig, cipher *crypto.AESCipher, handler func(*WSConn)) *WSServer {
-	return &WSServer{
-		config: config,
-		cipher: cipher,
-		upgrader: websocket.Upgrader{
-			ReadBufferSize:  config.ReadBufferSize,
-			WriteBufferSize: config.WriteBufferSize,
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
-		},
+	if config.AffinityCookie != "" && config.AffinityValue == "" {
+		config.AffinityValue, _ = os.Hostname()
+	}
+
+	s := &WSServer{
+		config:  config,
+		cipher:  cipher,
 		handler: handler,
 	}
+	s.upgrader = websocket.Upgrader{
+		ReadBufferSize:  config.ReadBufferSize,
+		WriteBufferSize: config.WriteBufferSize,
+		CheckOrigin:     s.checkOrigin,
+	}
+	return s
+}
+
+func (s *WSServer) SetProbeHandler(handler func(r *http.Request, reason string)) {
+	s.onProbe = handler
+}
+
+func (s *WSServer) SetPollHandler(handler func(net.Conn)) *PollServer {
+	s.poll = NewPollServer(handler)
+	return s.poll
+}
+
+func (s *WSServer) reportProbe(r *http.Request, reason string) {
+	if s.onProbe != nil {
+		s.onProbe(r, reason)
+	}
 }
 
 func (s *WSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != s.config.Path {
+		s.reportProbe(r, "bad_path")
 		s.serveFakePage(w, r)
 		return
 	}
 
-	conn, err := s.upgrader.Upgrade(w, r, nil)
+	affinity := s.affinityHeader(r)
+
+	if s.poll != nil && !websocket.IsWebSocketUpgrade(r) && r.URL.Query().Get("sid") != "" {
+		for _, cookie := range affinity.Values("Set-Cookie") {
+			w.Header().Add("Set-Cookie", cookie)
+		}
+		s.poll.ServeHTTP(w, r)
+		return
+	}
+
+	if !s.checkOrigin(r) {
+		log.Printf("[WS-Server] 🚫 拒绝来源 %s: %s", r.Header.Get("Origin"), r.RemoteAddr)
+		s.reportProbe(r, "bad_origin")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, affinity)
 	if err != nil {
 		log.Printf("[WS-Server] ⚠️ 升级 WebSocket 失败: %v", err)
+		s.reportProbe(r, "upgrade_failed")
 		return
 	}
 
 	wsConn := NewWSConn(conn, s.cipher)
-	wsConn.StartPing(s.config.PingInterval)
+	wsConn.req = r
+	wsConn.StartPing(s.config.PingInterval, s.config.PongMisses)
 
 	log.Printf("[WS-Server] 📥 新 WebSocket 连接: %s", conn.RemoteAddr())
 
 	s.handler(wsConn)
 }
 
+func (s *WSServer) affinityHeader(r *http.Request) http.Header {
+	if s.config.AffinityCookie == "" {
+		return nil
+	}
+	if cookie, err := r.Cookie(s.config.AffinityCookie); err == nil && cookie.Value == s.config.AffinityValue {
+		return nil
+	}
+
+	cookie := &http.Cookie{
+		Name:     s.config.AffinityCookie,
+		Value:    s.config.AffinityValue,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.config.EnableTLS,
+	}
+	return http.Header{"Set-Cookie": {cookie.String()}}
+}
+
 func (s *WSServer) serveFakePage(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	
+
 	html := `<!DOCTYPE html>
 <html>
 <head>
@@ -171,9 +335,25 @@ func (s *WSServer) Start(addr string) error {
 		Handler: s,
 	}
 
+	if s.config.AffinityCookie != "" {
+		log.Printf("[WS-Server] 🍪 会话亲和 Cookie: %s=%s", s.config.AffinityCookie, s.config.AffinityValue)
+	}
+
 	if s.config.EnableTLS {
+		tlsConfig, err := BuildServerTLSConfig(s.config)
+		if err != nil {
+			return fmt.Errorf("invalid tls config: %w", err)
+		}
+		server.TLSConfig = tlsConfig
+
+		reloader, err := LoadServerCertificate(s.config, tlsConfig)
+		if err != nil {
+			return err
+		}
+		defer reloader.Close()
+
 		log.Printf("[WS-Server] 🔒 启用 TLS，监听地址: %s%s", addr, s.config.Path)
-		return server.ListenAndServeTLS(s.config.TLSCert, s.config.TLSKey)
+		return server.ListenAndServeTLS("", "")
 	}
 
 	log.Printf("[WS-Server] 🚀 启动成功，监听地址: ws://%s%s", addr, s.config.Path)
@@ -183,16 +363,31 @@ func (s *WSServer) Start(addr string) error {
 type WSClient struct {
 	config WSConfig
 	cipher *crypto.AESCipher
+	dial   func(network, addr string) (net.Conn, error)
+	jar    http.CookieJar
 }
 
 func NewWSClient(config WSConfig, cipher *crypto.AESCipher) *WSClient {
 	return &WSClient{
 		config: config,
 		cipher: cipher,
+		jar:    newAffinityJar(config),
+	}
+}
+
+func newAffinityJar(config WSConfig) http.CookieJar {
+	if !config.Affinity {
+		return nil
 	}
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
+
+func (c *WSClient) SetDialer(dial func(network, addr string) (net.Conn, error)) {
+	c.dial = dial
 }
 
-func (c *WSClient) Connect(serverAddr string) (*WSConn, error) {
+func (c *WSClient) Connect(ctx context.Context, serverAddr string) (*WSConn, error) {
 	var scheme string
 	if c.config.EnableTLS {
 		scheme = "wss"
@@ -205,7 +400,9 @@ func (c *WSClient) Connect(serverAddr string) (*WSConn, error) {
 	dialer := websocket.Dialer{
 		ReadBufferSize:   c.config.ReadBufferSize,
 		WriteBufferSize:  c.config.WriteBufferSize,
-		HandshakeTimeout: 10 * time.Second,
+		HandshakeTimeout: c.config.DialTimeout,
+		NetDial:          c.dial,
+		Jar:              c.jar,
 	}
 
 	if c.config.EnableTLS && c.config.SkipVerify {
@@ -214,38 +411,57 @@ func (c *WSClient) Connect(serverAddr string) (*WSConn, error) {
 		}
 	}
 
-	headers := http.Header{}
-	if c.config.Origin != "" {
-		headers.Set("Origin", c.config.Origin)
+	origin := c.config.Origin
+	if origin == "" {
+		origin = defaultOrigin(c.config.EnableTLS, serverAddr)
 	}
+	headers := http.Header{}
+	headers.Set("Origin", origin)
 
-	conn, _, err := dialer.Dial(url, headers)
+	conn, _, err := dialer.DialContext(ctx, url, headers)
 	if err != nil {
 		return nil, fmt.Errorf("websocket dial failed: %w", err)
 	}
 
 	wsConn := NewWSConn(conn, c.cipher)
-	wsConn.StartPing(c.config.PingInterval)
+	wsConn.StartPing(c.config.PingInterval, c.config.PongMisses)
 
 	log.Printf("[WS-Client] ✅ 连接成功: %s", url)
 
 	return wsConn, nil
 }
 
-func BridgeWSToTCP(ws *WSConn, tcp net.Conn) {
+func BridgeWSToTCP(ws *WSConn, tcp net.Conn, batch crypto.BatchConfig, engine string, timeouts netutil.Timeouts, onReadError func(error)) {
+	ws.SetWriteTimeout(timeouts.Write)
+	idle := timeouts.Idle(ws, tcp)
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
+	closeBoth := func() {
+		ws.Close()
+		tcp.Close()
+	}
+
 	go func() {
 		defer wg.Done()
+		defer closeBoth()
+		defer crash.Recover("bridge.ws-tcp")
 		for {
 			data, err := ws.ReadEncrypted()
 			if err != nil {
-				if err != io.EOF {
+				if onReadError != nil {
+					onReadError(err)
+				}
+				if netutil.IsTimeout(err) {
+					log.Printf("[Bridge] ⏱️ 会话空闲超过 %v，关闭连接", timeouts.Read)
+				} else if !IsNormalClose(err) {
 					log.Printf("[Bridge] WS->TCP 读取错误: %v", err)
 				}
 				return
 			}
+			idle.Touch()
+			timeouts.ArmWrite(tcp)
 			if _, err := tcp.Write(data); err != nil {
 				log.Printf("[Bridge] WS->TCP 写入错误: %v", err)
 				return
@@ -255,16 +471,24 @@ func BridgeWSToTCP(ws *WSConn, tcp net.Conn) {
 
 	go func() {
 		defer wg.Done()
-		buf := make([]byte, 32*1024)
+		defer closeBoth()
+		defer crash.Recover("bridge.tcp-ws")
+		out := crypto.NewBatcher(ws, batch)
+		defer out.Close()
+		in := netutil.NewRelayReader(tcp, engine)
+		defer in.Close()
 		for {
-			n, err := tcp.Read(buf)
+			data, err := in.Next()
 			if err != nil {
-				if err != io.EOF {
+				if netutil.IsTimeout(err) {
+					log.Printf("[Bridge] ⏱️ 会话空闲超过 %v，关闭连接", timeouts.Read)
+				} else if !netutil.IsClosed(err) {
 					log.Printf("[Bridge] TCP->WS 读取错误: %v", err)
 				}
 				return
 			}
-			if err := ws.WriteEncrypted(buf[:n]); err != nil {
+			idle.Touch()
+			if err := out.WriteEncrypted(data); err != nil {
 				log.Printf("[Bridge] TCP->WS 写入错误: %v", err)
 				return
 			}
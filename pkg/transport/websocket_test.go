@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"tunnel/pkg/crypto"
+)
+
+func BenchmarkWSConnParallel(b *testing.B) {
+	for _, size := range []int{64, 1024, 16 * 1024} {
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			key, err := crypto.GenerateKey()
+			if err != nil {
+				b.Fatal(err)
+			}
+			cipher, err := crypto.NewAESCipherFromKey(key)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			upgrader := websocket.Upgrader{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				echo := NewWSConn(conn, cipher)
+				defer echo.Close()
+				for {
+					data, err := echo.ReadEncrypted()
+					if err != nil {
+						return
+					}
+					if err := echo.WriteEncrypted(data); err != nil {
+						return
+					}
+				}
+			}))
+			defer server.Close()
+			url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+			payload := make([]byte, size)
+			b.SetBytes(int64(2 * size))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				ws := NewWSConn(conn, cipher)
+				defer ws.Close()
+
+				for pb.Next() {
+					if err := ws.WriteEncrypted(payload); err != nil {
+						b.Error(err)
+						return
+					}
+					if _, err := ws.ReadEncrypted(); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
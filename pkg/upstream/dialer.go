@@ -0,0 +1,245 @@
+package upstream
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const dialTimeout = 10 * time.Second
+
+type authenticator interface {
+	scheme() string
+	next(challenge []byte) ([]byte, error)
+	close()
+}
+
+type Dialer struct {
+	proxy    *url.URL
+	user     string
+	password string
+	hasCreds bool
+	control  func(network, address string, c syscall.RawConn) error
+}
+
+func NewDialer(proxyURL string) (*Dialer, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "8080")
+	}
+
+	d := &Dialer{proxy: u}
+	if u.User != nil {
+		d.user = u.User.Username()
+		d.password, _ = u.User.Password()
+		d.hasCreds = true
+	}
+	return d, nil
+}
+
+func (d *Dialer) SetControl(control func(network, address string, c syscall.RawConn) error) {
+	d.control = control
+}
+
+func (d *Dialer) String() string {
+	return d.proxy.Host
+}
+
+func (d *Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, br, resp, err := d.connect(ctx, addr, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return &bufferedConn{Conn: conn, reader: br}, nil
+	}
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %s", resp.Status)
+	}
+
+	offered := resp.Header.Values("Proxy-Authenticate")
+	auth := d.authenticator(offered)
+	if auth == nil {
+		if hasScheme(offered, "Basic") && d.hasCreds {
+			conn.Close()
+			return d.basic(ctx, addr)
+		}
+		conn.Close()
+		return nil, fmt.Errorf("proxy requires unsupported authentication: %s", strings.Join(offered, ", "))
+	}
+	defer auth.close()
+
+	if resp.Close {
+		conn.Close()
+		conn, br = nil, nil
+	}
+
+	var challenge []byte
+	for {
+		token, err := auth.next(challenge)
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return nil, err
+		}
+
+		header := auth.scheme() + " " + base64.StdEncoding.EncodeToString(token)
+		if conn == nil {
+			conn, br, resp, err = d.connect(ctx, addr, header)
+		} else {
+			resp, err = d.send(conn, br, addr, header)
+		}
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return &bufferedConn{Conn: conn, reader: br}, nil
+		case http.StatusProxyAuthRequired:
+			challenge = findChallenge(resp.Header.Values("Proxy-Authenticate"), auth.scheme())
+			if challenge == nil || resp.Close {
+				conn.Close()
+				return nil, fmt.Errorf("proxy rejected %s authentication", auth.scheme())
+			}
+		default:
+			conn.Close()
+			return nil, fmt.Errorf("proxy CONNECT failed: %s", resp.Status)
+		}
+	}
+}
+
+func (d *Dialer) authenticator(offered []string) authenticator {
+	if !d.hasCreds {
+		if hasScheme(offered, "Negotiate") {
+			if auth := newSSPIAuth("Negotiate", d.proxy.Hostname()); auth != nil {
+				return auth
+			}
+		}
+		if hasScheme(offered, "NTLM") {
+			if auth := newSSPIAuth("NTLM", d.proxy.Hostname()); auth != nil {
+				return auth
+			}
+		}
+		return nil
+	}
+
+	if hasScheme(offered, "NTLM") {
+		return newNTLMAuth(d.user, d.password)
+	}
+	return nil
+}
+
+func (d *Dialer) basic(ctx context.Context, addr string) (net.Conn, error) {
+	credentials := base64.StdEncoding.EncodeToString([]byte(d.user + ":" + d.password))
+	conn, br, resp, err := d.connect(ctx, addr, "Basic "+credentials)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %s", resp.Status)
+	}
+	return &bufferedConn{Conn: conn, reader: br}, nil
+}
+
+func (d *Dialer) connect(ctx context.Context, addr, auth string) (net.Conn, *bufio.Reader, *http.Response, error) {
+	dialer := net.Dialer{Timeout: dialTimeout, Control: d.control}
+	conn, err := dialer.DialContext(ctx, "tcp", d.proxy.Host)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to connect proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := d.send(conn, br, addr, auth)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	return conn, br, resp, nil
+}
+
+func (d *Dialer) send(conn net.Conn, br *bufio.Reader, addr, auth string) (*http.Response, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	req.Header.Set("Proxy-Connection", "Keep-Alive")
+	if auth != "" {
+		req.Header.Set("Proxy-Authorization", auth)
+	}
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("failed to send CONNECT: %w", err)
+	}
+
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proxy response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return resp, nil
+}
+
+func hasScheme(offered []string, scheme string) bool {
+	for _, value := range offered {
+		if strings.EqualFold(strings.SplitN(value, " ", 2)[0], scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func findChallenge(offered []string, scheme string) []byte {
+	for _, value := range offered {
+		parts := strings.SplitN(value, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], scheme) {
+			continue
+		}
+		challenge, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+		if err == nil {
+			return challenge
+		}
+	}
+	return nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,181 @@
+package upstream
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	ntlmNegotiateUnicode      = 0x00000001
+	ntlmRequestTarget         = 0x00000004
+	ntlmNegotiateNTLM         = 0x00000200
+	ntlmNegotiateAlwaysSign   = 0x00008000
+	ntlmNegotiateExtendedSess = 0x00080000
+	ntlmNegotiateTargetInfo   = 0x00800000
+	ntlmNegotiate128          = 0x20000000
+	ntlmNegotiate56           = 0x80000000
+
+	ntlmDefaultFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM |
+		ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSess | ntlmNegotiate128 | ntlmNegotiate56
+
+	ntlmAvEOL       = 0
+	ntlmAvTimestamp = 7
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+type ntlmAuth struct {
+	user     string
+	domain   string
+	password string
+	step     int
+}
+
+func newNTLMAuth(user, password string) *ntlmAuth {
+	domain := ""
+	if i := strings.IndexAny(user, `\/`); i >= 0 {
+		domain, user = user[:i], user[i+1:]
+	} else if i := strings.LastIndex(user, "@"); i >= 0 {
+		user, domain = user[:i], user[i+1:]
+	}
+	return &ntlmAuth{user: user, domain: domain, password: password}
+}
+
+func (a *ntlmAuth) scheme() string {
+	return "NTLM"
+}
+
+func (a *ntlmAuth) next(challenge []byte) ([]byte, error) {
+	a.step++
+	switch a.step {
+	case 1:
+		return ntlmNegotiateMessage(), nil
+	case 2:
+		return a.authenticateMessage(challenge)
+	default:
+		return nil, fmt.Errorf("ntlm authentication rejected")
+	}
+}
+
+func (a *ntlmAuth) close() {}
+
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmDefaultFlags)
+	return msg
+}
+
+func (a *ntlmAuth) authenticateMessage(challenge []byte) ([]byte, error) {
+	if len(challenge) < 32 || !bytes.Equal(challenge[:8], ntlmSignature) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, fmt.Errorf("invalid ntlm challenge")
+	}
+
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+
+	var targetInfo []byte
+	if flags&ntlmNegotiateTargetInfo != 0 && len(challenge) >= 48 {
+		info, err := ntlmSecurityBuffer(challenge, 40)
+		if err != nil {
+			return nil, err
+		}
+		targetInfo = info
+	}
+
+	timestamp := ntlmTimestamp(targetInfo)
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+
+	ntHash := md4Sum(encodeUTF16(a.password))
+	v2Hash := hmacMD5(ntHash[:], encodeUTF16(strings.ToUpper(a.user)+a.domain))
+
+	blob := new(bytes.Buffer)
+	blob.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	binary.Write(blob, binary.LittleEndian, timestamp)
+	blob.Write(clientChallenge)
+	blob.Write([]byte{0, 0, 0, 0})
+	blob.Write(targetInfo)
+	blob.Write([]byte{0, 0, 0, 0})
+
+	ntProof := hmacMD5(v2Hash, append(append([]byte{}, serverChallenge...), blob.Bytes()...))
+	ntResponse := append(ntProof, blob.Bytes()...)
+	lmResponse := append(hmacMD5(v2Hash, append(append([]byte{}, serverChallenge...), clientChallenge...)), clientChallenge...)
+
+	domain := encodeUTF16(a.domain)
+	user := encodeUTF16(a.user)
+
+	const headerSize = 64
+	payload := [][]byte{lmResponse, ntResponse, domain, user, nil, nil}
+
+	msg := make([]byte, headerSize)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+
+	offset := headerSize
+	for i, field := range payload {
+		pos := 12 + i*8
+		binary.LittleEndian.PutUint16(msg[pos:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(msg[pos+2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(msg[pos+4:], uint32(offset))
+		offset += len(field)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags&ntlmDefaultFlags|ntlmNegotiateUnicode)
+
+	for _, field := range payload {
+		msg = append(msg, field...)
+	}
+	return msg, nil
+}
+
+func ntlmSecurityBuffer(msg []byte, pos int) ([]byte, error) {
+	length := int(binary.LittleEndian.Uint16(msg[pos:]))
+	offset := int(binary.LittleEndian.Uint32(msg[pos+4:]))
+	if offset+length > len(msg) {
+		return nil, fmt.Errorf("invalid ntlm security buffer")
+	}
+	return msg[offset : offset+length], nil
+}
+
+func ntlmTimestamp(targetInfo []byte) uint64 {
+	for len(targetInfo) >= 4 {
+		id := binary.LittleEndian.Uint16(targetInfo)
+		length := int(binary.LittleEndian.Uint16(targetInfo[2:]))
+		if id == ntlmAvEOL || len(targetInfo) < 4+length {
+			break
+		}
+		if id == ntlmAvTimestamp && length == 8 {
+			return binary.LittleEndian.Uint64(targetInfo[4:])
+		}
+		targetInfo = targetInfo[4+length:]
+	}
+
+	// FILETIME: 100ns intervals since 1601-01-01.
+	return uint64(time.Now().UnixNano()/100) + 116444736000000000
+}
+
+func hmacMD5(key, data []byte) []byte {
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func encodeUTF16(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(out[i*2:], u)
+	}
+	return out
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package upstream
+
+func newSSPIAuth(pkg, host string) authenticator {
+	return nil
+}
@@ -0,0 +1,145 @@
+//go:build windows
+
+package upstream
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	secpkgCredOutbound    = 2
+	securityNativeDrep    = 0x10
+	secbufferToken        = 2
+	iscReqAllocateMemory  = 0x100
+	iscReqConnection      = 0x800
+	secEOK                = 0
+	secIContinueNeeded    = 0x00090312
+	secbufferVersion      = 0
+	sspiTargetServiceName = "HTTP/"
+)
+
+var (
+	secur32                        = syscall.NewLazyDLL("secur32.dll")
+	procAcquireCredentialsHandleW  = secur32.NewProc("AcquireCredentialsHandleW")
+	procInitializeSecurityContextW = secur32.NewProc("InitializeSecurityContextW")
+	procDeleteSecurityContext      = secur32.NewProc("DeleteSecurityContext")
+	procFreeCredentialsHandle      = secur32.NewProc("FreeCredentialsHandle")
+	procFreeContextBuffer          = secur32.NewProc("FreeContextBuffer")
+)
+
+type secHandle struct {
+	lower uintptr
+	upper uintptr
+}
+
+type secBuffer struct {
+	size       uint32
+	bufferType uint32
+	buffer     *byte
+}
+
+type secBufferDesc struct {
+	version uint32
+	count   uint32
+	buffers *secBuffer
+}
+
+type sspiAuth struct {
+	pkg    string
+	target *uint16
+	cred   secHandle
+	ctx    secHandle
+	hasCtx bool
+}
+
+func newSSPIAuth(pkg, host string) authenticator {
+	if secur32.Load() != nil {
+		return nil
+	}
+
+	pkgName, err := syscall.UTF16PtrFromString(pkg)
+	if err != nil {
+		return nil
+	}
+	target, err := syscall.UTF16PtrFromString(sspiTargetServiceName + host)
+	if err != nil {
+		return nil
+	}
+
+	a := &sspiAuth{pkg: pkg, target: target}
+	var expiry int64
+	ret, _, _ := procAcquireCredentialsHandleW.Call(
+		0,
+		uintptr(unsafe.Pointer(pkgName)),
+		secpkgCredOutbound,
+		0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&a.cred)),
+		uintptr(unsafe.Pointer(&expiry)),
+	)
+	if ret != secEOK {
+		return nil
+	}
+	return a
+}
+
+func (a *sspiAuth) scheme() string {
+	return a.pkg
+}
+
+func (a *sspiAuth) next(challenge []byte) ([]byte, error) {
+	var input *secBufferDesc
+	var inBuf secBuffer
+	if len(challenge) > 0 {
+		inBuf = secBuffer{size: uint32(len(challenge)), bufferType: secbufferToken, buffer: &challenge[0]}
+		input = &secBufferDesc{version: secbufferVersion, count: 1, buffers: &inBuf}
+	} else if a.hasCtx {
+		return nil, fmt.Errorf("%s authentication rejected", a.pkg)
+	}
+
+	outBuf := secBuffer{bufferType: secbufferToken}
+	output := secBufferDesc{version: secbufferVersion, count: 1, buffers: &outBuf}
+
+	var ctxIn uintptr
+	if a.hasCtx {
+		ctxIn = uintptr(unsafe.Pointer(&a.ctx))
+	}
+
+	var attrs uint32
+	var expiry int64
+	ret, _, _ := procInitializeSecurityContextW.Call(
+		uintptr(unsafe.Pointer(&a.cred)),
+		ctxIn,
+		uintptr(unsafe.Pointer(a.target)),
+		iscReqAllocateMemory|iscReqConnection,
+		0,
+		securityNativeDrep,
+		uintptr(unsafe.Pointer(input)),
+		0,
+		uintptr(unsafe.Pointer(&a.ctx)),
+		uintptr(unsafe.Pointer(&output)),
+		uintptr(unsafe.Pointer(&attrs)),
+		uintptr(unsafe.Pointer(&expiry)),
+	)
+	if ret != secEOK && ret != secIContinueNeeded {
+		return nil, fmt.Errorf("InitializeSecurityContext failed: 0x%x", ret)
+	}
+	a.hasCtx = true
+
+	if outBuf.buffer == nil || outBuf.size == 0 {
+		return nil, fmt.Errorf("%s produced no token", a.pkg)
+	}
+	defer procFreeContextBuffer.Call(uintptr(unsafe.Pointer(outBuf.buffer)))
+
+	token := make([]byte, outBuf.size)
+	copy(token, unsafe.Slice(outBuf.buffer, outBuf.size))
+	return token, nil
+}
+
+func (a *sspiAuth) close() {
+	if a.hasCtx {
+		procDeleteSecurityContext.Call(uintptr(unsafe.Pointer(&a.ctx)))
+	}
+	procFreeCredentialsHandle.Call(uintptr(unsafe.Pointer(&a.cred)))
+}
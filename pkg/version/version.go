@@ -0,0 +1,76 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+var (
+	Version   = "1.2.0"
+	Commit    = ""
+	BuildDate = ""
+)
+
+type Info struct {
+	Version    string   `json:"version"`
+	Commit     string   `json:"commit"`
+	BuildDate  string   `json:"build_date"`
+	Go         string   `json:"go"`
+	Platform   string   `json:"platform"`
+	Transports []string `json:"transports"`
+	Ciphers    []string `json:"ciphers"`
+	Features   []string `json:"features"`
+}
+
+func Get() Info {
+	info := Info{
+		Version:    Version,
+		Commit:     Commit,
+		BuildDate:  BuildDate,
+		Go:         runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		Transports: []string{"tcp", "tcp+tls", "raw+tls", "websocket", "websocket+tls", "poll"},
+		Ciphers:    []string{"AES-256-CFB", "AES-256-GCM"},
+		Features:   []string{"dynamic_targets", "udp_associate", "control_channel", "frame_crc32", "batching", "fips"},
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok && Commit == "" {
+		dirty := false
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				dirty = setting.Value == "true"
+			}
+		}
+		if dirty && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+func (i Info) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "版本:     v%s\n", i.Version)
+	fmt.Fprintf(&b, "提交:     %s\n", i.Commit)
+	fmt.Fprintf(&b, "构建时间: %s\n", i.BuildDate)
+	fmt.Fprintf(&b, "Go:       %s (%s)\n", i.Go, i.Platform)
+	fmt.Fprintf(&b, "传输:     %s\n", strings.Join(i.Transports, ", "))
+	fmt.Fprintf(&b, "加密:     %s\n", strings.Join(i.Ciphers, ", "))
+	fmt.Fprintf(&b, "功能:     %s\n", strings.Join(i.Features, ", "))
+	return b.String()
+}